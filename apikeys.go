@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ProviderConfig holds the settings for one AI provider.
+type ProviderConfig struct {
+	// APIKeys are used in turn so that busy flows spread their calls over
+	// several keys and their quotas. Empty means GEMINI_API_KEY or ~/.fast_key.
+	APIKeys []string `json:"api_keys,omitempty"`
+	// Strategy picks the next key: round_robin (the default) or
+	// least_recently_used.
+	Strategy string `json:"strategy,omitempty"`
+}
+
+// keyRateLimitPause is how long a key that got a 429 is skipped.
+const keyRateLimitPause = 60 * time.Second
+
+// validateProviderConfig checks the strategy of a provider section.
+func validateProviderConfig(name string, p ProviderConfig) error {
+	switch p.Strategy {
+	case "", "round_robin", "least_recently_used":
+		return nil
+	}
+	return fmt.Errorf("unknown %s.strategy %q in config.json (expected round_robin or least_recently_used)", name, p.Strategy)
+}
+
+// keyPool hands out API keys and remembers which ones were rate limited.
+type keyPool struct {
+	next atomic.Uint64
+
+	mu           sync.Mutex
+	lastUsed     map[string]time.Time
+	limitedUntil map[string]time.Time
+	now          func() time.Time
+}
+
+func newKeyPool() *keyPool {
+	return &keyPool{
+		lastUsed:     make(map[string]time.Time),
+		limitedUntil: make(map[string]time.Time),
+		now:          time.Now,
+	}
+}
+
+var apiKeys = newKeyPool()
+
+// pick returns the key to use for the next call. Rate limited keys are
+// skipped; if every key is rate limited, the one that recovers first is used.
+func (p *keyPool) pick(keys []string, strategy string) string {
+	if len(keys) == 0 {
+		return ""
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+
+	var key string
+	if strategy == "least_recently_used" {
+		for _, k := range keys {
+			if p.limitedUntil[k].After(now) {
+				continue
+			}
+			if key == "" || p.lastUsed[k].Before(p.lastUsed[key]) {
+				key = k
+			}
+		}
+	} else {
+		start := p.next.Add(1) - 1
+		for i := range uint64(len(keys)) {
+			k := keys[(start+i)%uint64(len(keys))]
+			if !p.limitedUntil[k].After(now) {
+				key = k
+				break
+			}
+		}
+	}
+	if key == "" {
+		for _, k := range keys {
+			if key == "" || p.limitedUntil[k].Before(p.limitedUntil[key]) {
+				key = k
+			}
+		}
+	}
+	p.lastUsed[key] = now
+	return key
+}
+
+// markRateLimited skips key for keyRateLimitPause.
+func (p *keyPool) markRateLimited(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.limitedUntil[key] = p.now().Add(keyRateLimitPause)
+}
+
+// nextAPIKey returns the Gemini API key for the next call: one of the keys
+// from config.json, or getAPIKey if none are configured.
+func nextAPIKey() string {
+	gemini := globalConfig.Gemini
+	if len(gemini.APIKeys) == 0 {
+		return getAPIKey()
+	}
+	return apiKeys.pick(gemini.APIKeys, gemini.Strategy)
+}
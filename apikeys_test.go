@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestKeyPoolRoundRobin(t *testing.T) {
+	p := newKeyPool()
+	keys := []string{"a", "b", "c"}
+
+	counts := map[string]int{}
+	var order []string
+	for range 9 {
+		k := p.pick(keys, "round_robin")
+		counts[k]++
+		order = append(order, k)
+	}
+	for _, k := range keys {
+		if counts[k] != 3 {
+			t.Errorf("Expected key %s to be used 3 times, got %d (order %v)", k, counts[k], order)
+		}
+	}
+	if order[0] != "a" || order[1] != "b" || order[2] != "c" || order[3] != "a" {
+		t.Errorf("Expected keys in turn, got %v", order)
+	}
+}
+
+func TestKeyPoolSkipsRateLimitedKeys(t *testing.T) {
+	now := time.Unix(1000, 0)
+	p := newKeyPool()
+	p.now = func() time.Time { return now }
+	keys := []string{"a", "b"}
+
+	p.markRateLimited("a")
+	for range 3 {
+		if k := p.pick(keys, ""); k != "b" {
+			t.Errorf("Expected rate limited key to be skipped, got %s", k)
+		}
+	}
+
+	// Every key limited: use the one that recovers first
+	now = now.Add(time.Second)
+	p.markRateLimited("b")
+	if k := p.pick(keys, ""); k != "a" {
+		t.Errorf("Expected the key that recovers first, got %s", k)
+	}
+
+	now = now.Add(keyRateLimitPause)
+	counts := map[string]int{}
+	for range 4 {
+		counts[p.pick(keys, "")]++
+	}
+	if counts["a"] != 2 || counts["b"] != 2 {
+		t.Errorf("Expected both keys to be used again after the pause, got %v", counts)
+	}
+}
+
+func TestKeyPoolLeastRecentlyUsed(t *testing.T) {
+	now := time.Unix(1000, 0)
+	p := newKeyPool()
+	p.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	keys := []string{"a", "b", "c"}
+
+	var order []string
+	for range 3 {
+		order = append(order, p.pick(keys, "least_recently_used"))
+	}
+	if order[0] != "a" || order[1] != "b" || order[2] != "c" {
+		t.Errorf("Expected unused keys first, got %v", order)
+	}
+	p.markRateLimited("a")
+	if k := p.pick(keys, "least_recently_used"); k != "b" {
+		t.Errorf("Expected oldest key that isn't rate limited, got %s", k)
+	}
+}
+
+func TestNextAPIKey(t *testing.T) {
+	original := globalConfig
+	originalPool := apiKeys
+	defer func() {
+		globalConfig = original
+		apiKeys = originalPool
+	}()
+	t.Setenv("GEMINI_API_KEY", "env-key")
+
+	globalConfig.Gemini = ProviderConfig{}
+	if k := nextAPIKey(); k != "env-key" {
+		t.Errorf("Expected GEMINI_API_KEY without configured keys, got %s", k)
+	}
+
+	apiKeys = newKeyPool()
+	globalConfig.Gemini = ProviderConfig{APIKeys: []string{"k1", "k2"}}
+	if a, b := nextAPIKey(), nextAPIKey(); a != "k1" || b != "k2" {
+		t.Errorf("Expected configured keys in turn, got %s, %s", a, b)
+	}
+}
+
+func TestValidateProviderConfig(t *testing.T) {
+	for _, s := range []string{"", "round_robin", "least_recently_used"} {
+		if err := validateProviderConfig("gemini", ProviderConfig{Strategy: s}); err != nil {
+			t.Errorf("Expected strategy %q to be valid, got %v", s, err)
+		}
+	}
+	if err := validateProviderConfig("gemini", ProviderConfig{Strategy: "random"}); err == nil {
+		t.Error("Expected an unknown strategy to fail")
+	}
+}
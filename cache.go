@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// minCachedPromptTokens is the estimated size from which a system prompt is
+// sent as cached content. Gemini rejects caches smaller than about 1024
+// tokens, and for short prompts the extra request isn't worth it.
+const minCachedPromptTokens = 1024
+
+// promptCacheTTL is how long a cached system prompt lives on Gemini's side.
+// It's reused until promptCacheMargin before it expires, so a call never
+// refers to a cache that is gone by the time the request arrives.
+const (
+	promptCacheTTL    = 10 * time.Minute
+	promptCacheMargin = time.Minute
+)
+
+// promptCacheRetry is how long a prompt whose cache couldn't be created is
+// sent inline before caching is tried again.
+const promptCacheRetry = 5 * time.Minute
+
+// cachedPrompt is the cachedContents entry of one system prompt.
+type cachedPrompt struct {
+	mu      sync.Mutex
+	uses    int
+	name    string
+	expires time.Time
+	// retryAt is set when the cache couldn't be created, e.g. because of a
+	// network error or a model without caching; until then the prompt is
+	// sent inline.
+	retryAt time.Time
+}
+
+// promptCache remembers the cachedContents created for long system prompts,
+// keyed by a hash of the model, API key and prompt, so that the steps of a
+// flow sharing a system prompt upload it only once.
+type promptCache struct {
+	mu      sync.Mutex
+	entries map[string]*cachedPrompt
+	create  func(ctx context.Context, model, sys, apiKey string) (string, error)
+	now     func() time.Time
+}
+
+func newPromptCache() *promptCache {
+	return &promptCache{
+		entries: make(map[string]*cachedPrompt),
+		create:  createCachedContent,
+		now:     time.Now,
+	}
+}
+
+var promptCaches = newPromptCache()
+
+// promptCacheKey hashes everything a cachedContents entry is tied to: caches
+// belong to one model and to the project of the key that created them.
+func promptCacheKey(model, sys, apiKey string) string {
+	sum := sha256.Sum256([]byte(model + "\x00" + apiKey + "\x00" + sys))
+	return hex.EncodeToString(sum[:])
+}
+
+// use returns the system prompt to send inline and the cachedContents name
+// to send instead; at most one of them is non-empty. A long prompt is cached
+// the second time it's used, since a cache only pays off when it's reused,
+// and the cache is reused until it's about to expire.
+func (c *promptCache) use(ctx context.Context, model, sys, apiKey string) (string, string) {
+	if estimateTokens(sys) < minCachedPromptTokens {
+		return sys, ""
+	}
+	key := promptCacheKey(model, sys, apiKey)
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &cachedPrompt{}
+		c.entries[key] = entry
+	}
+	c.mu.Unlock()
+
+	// Parallel steps with the same prompt wait here for the first one to
+	// create the cache instead of creating one each.
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+	entry.uses++
+	start := c.now()
+	if entry.uses < 2 || start.Before(entry.retryAt) {
+		return sys, ""
+	}
+	if entry.name != "" && start.Before(entry.expires.Add(-promptCacheMargin)) {
+		return "", entry.name
+	}
+	name, err := c.create(ctx, model, sys, apiKey)
+	if err != nil {
+		if ctx.Err() == nil {
+			entry.retryAt = start.Add(promptCacheRetry)
+		}
+		return sys, ""
+	}
+	entry.name = name
+	entry.expires = start.Add(promptCacheTTL)
+	return "", name
+}
+
+// createCachedContent uploads a system prompt with the cachedContents API
+// and returns the name to pass as cachedContent.
+func createCachedContent(ctx context.Context, model, sys, apiKey string) (string, error) {
+	url := "https://generativelanguage.googleapis.com/v1beta/cachedContents?key=" + apiKey
+	payload, _ := json.Marshal(map[string]interface{}{
+		"model":             "models/" + model,
+		"systemInstruction": map[string]interface{}{"parts": []map[string]string{{"text": sys}}},
+		"ttl":               fmt.Sprintf("%ds", int(promptCacheTTL.Seconds())),
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("creating cached content failed: %s: %s", resp.Status, body)
+	}
+	var res struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return "", err
+	}
+	if res.Name == "" {
+		return "", fmt.Errorf("creating cached content returned no name: %s", body)
+	}
+	return res.Name, nil
+}
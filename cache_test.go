@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPromptCache(t *testing.T) {
+	now := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+	created := 0
+	c := newPromptCache()
+	c.now = func() time.Time { return now }
+	c.create = func(ctx context.Context, model, sys, apiKey string) (string, error) {
+		created++
+		return "cachedContents/" + model, nil
+	}
+	ctx := context.Background()
+	long := strings.Repeat("word ", minCachedPromptTokens)
+
+	if sys, cached := c.use(ctx, "gemini-2.5-flash", "Be brief.", "key"); sys != "Be brief." || cached != "" || created != 0 {
+		t.Errorf("Expected short prompts to be sent inline, got %q, %q", sys, cached)
+	}
+	if sys, cached := c.use(ctx, "gemini-2.5-flash", long, "key"); sys != long || cached != "" || created != 0 {
+		t.Errorf("Expected a long prompt to be sent inline the first time, got cached %q", cached)
+	}
+	for i := 0; i < 2; i++ {
+		if sys, cached := c.use(ctx, "gemini-2.5-flash", long, "key"); sys != "" || cached != "cachedContents/gemini-2.5-flash" {
+			t.Errorf("Expected the cached prompt once it repeats, got %q, %q", sys, cached)
+		}
+	}
+	if created != 1 {
+		t.Errorf("Expected the cache to be created once, got %d", created)
+	}
+	c.use(ctx, "gemini-2.5-pro", long, "key")
+	c.use(ctx, "gemini-2.5-pro", long, "key")
+	if created != 2 {
+		t.Errorf("Expected a separate cache per model, got %d creations", created)
+	}
+
+	now = now.Add(promptCacheTTL - promptCacheMargin)
+	c.use(ctx, "gemini-2.5-flash", long, "key")
+	if created != 3 {
+		t.Errorf("Expected the cache to be recreated before it expires, got %d creations", created)
+	}
+}
+
+func TestPromptCacheRetriesAfterFailure(t *testing.T) {
+	now := time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)
+	created := 0
+	fail := true
+	c := newPromptCache()
+	c.now = func() time.Time { return now }
+	c.create = func(ctx context.Context, model, sys, apiKey string) (string, error) {
+		created++
+		if fail {
+			return "", errors.New("network is unreachable")
+		}
+		return "cachedContents/abc", nil
+	}
+	ctx := context.Background()
+	long := strings.Repeat("word ", minCachedPromptTokens)
+
+	for i := 0; i < 3; i++ {
+		if sys, cached := c.use(ctx, "gemini-2.5-flash", long, "key"); sys != long || cached != "" {
+			t.Errorf("Expected the prompt inline after a failed cache, got cached %q", cached)
+		}
+	}
+	if created != 1 {
+		t.Errorf("Expected no new attempt during the cool-down, got %d creations", created)
+	}
+
+	fail = false
+	now = now.Add(promptCacheRetry)
+	if _, cached := c.use(ctx, "gemini-2.5-flash", long, "key"); cached != "cachedContents/abc" || created != 2 {
+		t.Errorf("Expected caching to be retried after the cool-down, got %q after %d creations", cached, created)
+	}
+}
+
+func TestGeminiPayloadCachedContent(t *testing.T) {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(geminiPayload("", "hi", nil, nil, "cachedContents/abc"), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload["cachedContent"] != "cachedContents/abc" || payload["system_instruction"] != nil {
+		t.Errorf("Expected cachedContent instead of system_instruction, got %v", payload)
+	}
+}
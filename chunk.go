@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// defaultChunkSeparator joins the results of a chunked step.
+const defaultChunkSeparator = "\n\n"
+
+// validateChunking checks the chunk_size and overlap of a step.
+func validateChunking(s Step) error {
+	switch {
+	case s.ChunkSize < 0:
+		return fmt.Errorf("step '%s': chunk_size must not be negative", s.ID)
+	case s.ChunkSize == 0 && (s.Overlap != 0 || s.MergePrompt != ""):
+		return fmt.Errorf("step '%s': overlap and merge_prompt need a chunk_size", s.ID)
+	case s.Overlap < 0 || (s.ChunkSize > 0 && s.Overlap >= s.ChunkSize):
+		return fmt.Errorf("step '%s': overlap must be between 0 and chunk_size", s.ID)
+	case s.ChunkSize > 0 && s.StreamingToFile:
+		return fmt.Errorf("step '%s': chunk_size can't be combined with streaming_to_file", s.ID)
+	}
+	return nil
+}
+
+// splitChunks cuts text into pieces of at most size characters, each one
+// repeating the last overlap characters of the one before.
+func splitChunks(text string, size, overlap int) []string {
+	runes := []rune(text)
+	if len(runes) <= size {
+		return []string{text}
+	}
+	var chunks []string
+	for start := 0; ; start += size - overlap {
+		end := min(start+size, len(runes))
+		chunks = append(chunks, string(runes[start:end]))
+		if end == len(runes) {
+			return chunks
+		}
+	}
+}
+
+// runChunked sends each chunk of prompt to the model and joins the answers
+// with the step's separator. With a merge_prompt, one more call combines
+// them; {{chunks}} in the merge prompt is replaced by the joined answers,
+// which are appended if the tag is missing.
+func runChunked(ctx context.Context, run *FlowRun, model, sys, prompt string, s Step, images []InlineImage, progress func(string)) (string, int, error) {
+	chunks := splitChunks(prompt, s.ChunkSize, s.Overlap)
+	results := make([]string, len(chunks))
+	tokens := 0
+	for i, chunk := range chunks {
+		if err := waitForGemini(ctx, progress); err != nil {
+			return "", tokens, err
+		}
+		progress(fmt.Sprintf("Processing chunk %d/%d…", i+1, len(chunks)))
+		res, used, err := callGemini(ctx, model, sys, chunk, images)
+		if ctx.Err() != nil {
+			return "", tokens, ctx.Err()
+		}
+		if err != nil {
+			return "", tokens + used, fmt.Errorf("chunk %d/%d: %w", i+1, len(chunks), err)
+		}
+		if res == "" {
+			return "", tokens, fmt.Errorf("chunk %d/%d: %w", i+1, len(chunks), errNoResult)
+		}
+		run.streamLog.Event("token", s.ID, res)
+		results[i] = res
+		tokens += used
+	}
+
+	sep := s.Separator
+	if sep == "" {
+		sep = defaultChunkSeparator
+	}
+	joined := strings.Join(results, sep)
+	if s.MergePrompt == "" {
+		return joined, tokens, nil
+	}
+
+	if err := waitForGemini(ctx, progress); err != nil {
+		return "", tokens, err
+	}
+	progress("Merging chunks…")
+	merge := run.fillStepTags(s.MergePrompt, s, model)
+	if strings.Contains(merge, "{{chunks}}") {
+		merge = strings.ReplaceAll(merge, "{{chunks}}", joined)
+	} else {
+		merge += "\n\n" + joined
+	}
+	res, used, err := callGemini(ctx, model, sys, merge, nil)
+	return res, tokens + used, err
+}
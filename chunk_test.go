@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitChunks(t *testing.T) {
+	tests := []struct {
+		text          string
+		size, overlap int
+		want          []string
+	}{
+		{"short", 10, 2, []string{"short"}},
+		{"abcdefghij", 4, 0, []string{"abcd", "efgh", "ij"}},
+		{"abcdefghij", 4, 1, []string{"abcd", "defg", "ghij"}},
+		{"äöüäöü", 4, 2, []string{"äöüä", "üäöü"}},
+	}
+	for _, tt := range tests {
+		if got := splitChunks(tt.text, tt.size, tt.overlap); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitChunks(%q, %d, %d) = %q, want %q", tt.text, tt.size, tt.overlap, got, tt.want)
+		}
+	}
+}
+
+func TestValidateChunking(t *testing.T) {
+	valid := []Step{
+		{ID: "a"},
+		{ID: "a", ChunkSize: 100, Overlap: 10, MergePrompt: "Combine {{chunks}}"},
+	}
+	for _, s := range valid {
+		if err := validateChunking(s); err != nil {
+			t.Errorf("Expected %+v to be valid, got %v", s, err)
+		}
+	}
+	invalid := []Step{
+		{ID: "a", ChunkSize: -1},
+		{ID: "a", Overlap: 5},
+		{ID: "a", ChunkSize: 10, Overlap: 10},
+		{ID: "a", ChunkSize: 10, Overlap: -1},
+		{ID: "a", ChunkSize: 10, StreamingToFile: true},
+	}
+	for _, s := range invalid {
+		if err := validateChunking(s); err == nil {
+			t.Errorf("Expected %+v to be rejected", s)
+		}
+	}
+}
+
+func TestRunStepChunked(t *testing.T) {
+	originalCallGemini := callGemini
+	defer func() { callGemini = originalCallGemini }()
+
+	var prompts []string
+	callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+		prompts = append(prompts, prompt)
+		if strings.HasPrefix(prompt, "Merge") {
+			return "merged", 1, nil
+		}
+		return strings.ToUpper(prompt), 1, nil
+	}
+
+	run := newFlowRun("abcdefgh")
+	var progress []string
+	step := Step{ID: "s", Prompt: "{{input}}", ChunkSize: 3, Overlap: 1, Separator: "|"}
+
+	res, log, err := runStep(context.Background(), run, Config{}, step, func(p string) { progress = append(progress, p) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "ABC|CDE|EFG|GH" || log.TokensUsed != 4 {
+		t.Errorf("Unexpected result %q (%d tokens)", res, log.TokensUsed)
+	}
+	if len(progress) != 4 || progress[1] != "Processing chunk 2/4…" {
+		t.Errorf("Unexpected progress %q", progress)
+	}
+
+	prompts = nil
+	step.MergePrompt = "Merge for {{input}}: {{chunks}}"
+	res, _, err = runStep(context.Background(), run, Config{}, step, func(string) {})
+	if err != nil || res != "merged" {
+		t.Fatalf("Expected merged result, got %q (%v)", res, err)
+	}
+	if last := prompts[len(prompts)-1]; last != "Merge for abcdefgh: ABC|CDE|EFG|GH" {
+		t.Errorf("Unexpected merge prompt %q", last)
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Options holds everything parsed from the command line.
+type Options struct {
+	FlowName    string
+	FlowNames   []string
+	Input       string
+	InputFile   string
+	InputFormat string
+	Watch       bool
+	Step        string
+	Set         setFlags
+	Output      string
+	Force       bool
+	Format      string
+	OnlyTags    string
+	Theme       string
+	NoTUI       bool
+	Stdin       bool
+
+	// Quiet is --no-tui that also hides notices such as log cleanup, so
+	// stdout and stderr carry nothing but the result and errors.
+	Quiet bool
+
+	// JSONOutput is --quiet that prints the outcome as a JSON object.
+	JSONOutput bool
+
+	// StreamLog is an NDJSON file step events and tokens are appended to.
+	StreamLog string
+
+	// EnvFile is a .env file loaded into the environment before the run.
+	EnvFile string
+
+	// Record also writes the TUI session to NAME.cast (asciicast v2).
+	Record string
+
+	// Export writes a report of the run: markdown, html or pdf.
+	Export string
+
+	// StepOrder prints the phases the steps run in instead of running them.
+	StepOrder bool
+
+	// Explain prints what each step would do instead of running the flow.
+	Explain bool
+
+	// Trace logs every tag substitution, to stderr or with the TUI to a
+	// trace log in the logs folder.
+	Trace bool
+
+	// NoPreview hides the result preview under done steps in the tree.
+	NoPreview bool
+
+	// ParallelLimit overrides parallel_limit from config.json when > 0.
+	ParallelLimit int
+	Resume        string
+	Timeout       time.Duration
+	Profile       string
+
+	// Mock holds --mock-step results, which replace a step's AI call.
+	Mock setFlags
+}
+
+// setFlags collects repeated --set KEY=VALUE flags.
+type setFlags map[string]string
+
+func (s setFlags) String() string { return "" }
+
+// Keys returns the names of all values set, sorted for stable output.
+func (s setFlags) Keys() []string {
+	keys := make([]string, 0, len(s))
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s setFlags) Set(v string) error {
+	key, value, ok := strings.Cut(v, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("expected KEY=VALUE, got %q", v)
+	}
+	s[key] = value
+	return nil
+}
+
+// parseArgs parses `fast <name> [input] [flags]`. Flags may appear anywhere
+// after the flow name; everything after a bare `--` is treated as input.
+func parseArgs(args []string) (Options, error) {
+	opts := Options{Set: make(setFlags), Mock: make(setFlags)}
+	fs := flag.NewFlagSet("fast", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	fs.StringVar(&opts.InputFile, "input-file", "", "read {{input}} from a file ('-' for stdin)")
+	fs.StringVar(&opts.InputFormat, "input-format", "text", "encoding of the input file: text or base64")
+	fs.BoolVar(&opts.Watch, "watch", false, "re-run the flow when the flow or input file changes")
+	fs.StringVar(&opts.Step, "step", "", "run a single step in isolation and print its result")
+	fs.Var(opts.Set, "set", "provide a step result as KEY=VALUE (repeatable)")
+	fs.Var(opts.Mock, "mock-step", "pretend step ID ran and returned VALUE, as ID=VALUE (repeatable)")
+	fs.StringVar(&opts.Output, "output", "", "also write the final result to this file")
+	fs.BoolVar(&opts.Force, "force", false, "overwrite the --output file without asking")
+	fs.StringVar(&opts.Format, "format", "raw", "final output format: raw, json-pretty or markdown-strip")
+	fs.StringVar(&opts.Theme, "theme", "", "TUI color theme: dark, light or monokai")
+	fs.DurationVar(&opts.Timeout, "timeout", 0, "stop the flow if it runs longer than this (e.g. 5m)")
+	fs.StringVar(&opts.Resume, "resume", "", "reuse the results of a session log and run only the remaining steps")
+	fs.IntVar(&opts.ParallelLimit, "parallel-limit", 0, "run at most N steps at the same time")
+	fs.BoolVar(&opts.NoTUI, "no-tui", false, "print only the final result to stdout, without the TUI")
+	fs.BoolVar(&opts.Quiet, "quiet", false, "like --no-tui, and print nothing to stderr but errors")
+	fs.BoolVar(&opts.JSONOutput, "json-output", false, "like --quiet, and print the result or error as JSON")
+	fs.StringVar(&opts.StreamLog, "stream-log", "", "append step events and tokens to this file as NDJSON")
+	fs.StringVar(&opts.EnvFile, "env-file", "", "load environment variables from a .env file (set variables win)")
+	fs.StringVar(&opts.Record, "record", "", "record the TUI session to NAME.cast for asciinema")
+	fs.StringVar(&opts.Export, "export", "", "also write a report of the run: markdown, html or pdf")
+	fs.BoolVar(&opts.StepOrder, "step-order", false, "show the order the steps run in, without running them")
+	fs.BoolVar(&opts.Explain, "explain", false, "show what each step would do, without calling the AI")
+	fs.BoolVar(&opts.Trace, "trace", false, "log every tag substitution (to stderr, or a trace log with the TUI)")
+	fs.BoolVar(&opts.NoPreview, "no-preview", false, "don't show a result preview under finished steps")
+	fs.BoolVar(&opts.Stdin, "stdin", false, "read {{input}} from stdin (same as --input-file -)")
+	fs.StringVar(&opts.Profile, "profile", "", "write a cpu or mem profile of the run")
+	fs.StringVar(&opts.OnlyTags, "only-tags", "", "run only steps with these comma-separated tags (and their dependencies)")
+
+	var positional []string
+	for len(args) > 0 {
+		if err := fs.Parse(args); err != nil {
+			return opts, err
+		}
+		rest := fs.Args()
+		consumed := len(args) - len(rest)
+		if consumed > 0 && args[consumed-1] == "--" {
+			positional = append(positional, rest...)
+			break
+		}
+		if len(rest) == 0 {
+			break
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+
+	if len(positional) == 0 {
+		return opts, errors.New("missing flow name")
+	}
+	if positional[0] == "run" {
+		// `fast run a b c` runs several flows side by side; every
+		// positional argument is a flow name.
+		opts.FlowNames = positional[1:]
+		if len(opts.FlowNames) == 0 {
+			return opts, errors.New("missing flow name")
+		}
+		opts.FlowName = opts.FlowNames[0]
+	} else {
+		opts.FlowName = positional[0]
+		opts.Input = strings.Join(positional[1:], " ")
+	}
+
+	if opts.InputFormat != "text" && opts.InputFormat != "base64" {
+		return opts, fmt.Errorf("unknown --input-format %q (expected text or base64)", opts.InputFormat)
+	}
+	switch opts.Format {
+	case "raw", "json-pretty", "markdown-strip":
+	default:
+		return opts, fmt.Errorf("unknown --format %q (expected raw, json-pretty or markdown-strip)", opts.Format)
+	}
+	if _, ok := exportExtensions[opts.Export]; opts.Export != "" && !ok {
+		return opts, fmt.Errorf("unknown --export %q (expected markdown, html or pdf)", opts.Export)
+	}
+	if opts.JSONOutput {
+		opts.Quiet = true
+	}
+	if opts.Quiet {
+		opts.NoTUI = true
+	}
+	if len(opts.FlowNames) > 1 && (opts.Step != "" || opts.Output != "" || opts.Watch || opts.NoTUI || opts.Resume != "" || opts.Explain || opts.StepOrder || opts.Export != "" || opts.Trace || opts.Format != "raw") {
+		return opts, errors.New("--step, --output, --format, --watch, --no-tui, --quiet, --json-output, --resume, --explain, --step-order, --export and --trace only work with a single flow")
+	}
+	if opts.ParallelLimit < 0 {
+		return opts, fmt.Errorf("--parallel-limit must be at least 1, got %d", opts.ParallelLimit)
+	}
+	if opts.Stdin {
+		if opts.InputFile != "" {
+			return opts, errors.New("--stdin cannot be combined with --input-file")
+		}
+		opts.InputFile = "-"
+	}
+	for id, value := range opts.Mock {
+		if value == "" {
+			return opts, fmt.Errorf("--mock-step %s needs a non-empty value", id)
+		}
+		if _, ok := opts.Set[id]; ok {
+			return opts, fmt.Errorf("step '%s' is given both with --set and --mock-step", id)
+		}
+	}
+	if opts.Profile != "" && opts.Profile != "cpu" && opts.Profile != "mem" {
+		return opts, fmt.Errorf("unknown --profile %q (expected cpu or mem)", opts.Profile)
+	}
+	if opts.Timeout < 0 {
+		return opts, fmt.Errorf("--timeout must be positive, got %s", opts.Timeout)
+	}
+	if opts.Timeout > 0 && opts.Watch {
+		return opts, errors.New("--timeout cannot be combined with --watch")
+	}
+	if opts.Record != "" && (opts.NoTUI || opts.Step != "" || opts.Explain) {
+		return opts, errors.New("--record records the TUI and cannot be combined with --no-tui, --quiet, --step or --explain")
+	}
+	if opts.NoTUI && opts.Watch {
+		return opts, errors.New("--watch needs the TUI and cannot be combined with --no-tui or --quiet")
+	}
+	if opts.InputFile != "" && opts.Input != "" {
+		return opts, errors.New("--input-file cannot be combined with positional input")
+	}
+	if _, ok := opts.Set["input"]; ok && (opts.Input != "" || opts.InputFile != "") {
+		return opts, errors.New("--set input cannot be combined with positional input or --input-file")
+	}
+	return opts, nil
+}
+
+// resolveInput works out the {{input}} value from positional args, --set
+// input or --input-file. A --set input is removed from opts.Set.
+func resolveInput(opts *Options) (string, error) {
+	if v, ok := opts.Set["input"]; ok {
+		delete(opts.Set, "input")
+		return v, nil
+	}
+	if opts.InputFile != "" {
+		input, err := readInputFile(opts.InputFile, opts.InputFormat)
+		if err != nil {
+			return "", fmt.Errorf("Failed to read input file: %v", err)
+		}
+		return input, nil
+	}
+	return opts.Input, nil
+}
+
+// readInputFile loads the {{input}} value from path, or from stdin when path is "-".
+func readInputFile(path, format string) (string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(expandHome(path))
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if format == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil {
+			return "", fmt.Errorf("invalid base64 input: %w", err)
+		}
+		data = decoded
+	}
+	return string(data), nil
+}
+
+// confirmOverwrite asks on stdin whether an existing file may be replaced.
+func confirmOverwrite(path string) bool {
+	fmt.Printf("⚠️  %s already exists. Overwrite? [y/N] ", path)
+	var answer string
+	fmt.Scanln(&answer)
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// writeOutput writes the final result to path, creating parent directories.
+func writeOutput(path, result string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(result), 0644)
+}
+
+// expandHome replaces a leading ~ with the user's home directory.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
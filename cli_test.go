@@ -0,0 +1,136 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseArgs(t *testing.T) {
+	opts, err := parseArgs([]string{"reply", "I", "am", "sick"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.FlowName != "reply" || opts.Input != "I am sick" {
+		t.Errorf("Expected reply / 'I am sick', got %s / '%s'", opts.FlowName, opts.Input)
+	}
+
+	opts, err = parseArgs([]string{"scope", "--input-file", "notes.txt"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.InputFile != "notes.txt" || opts.Input != "" {
+		t.Errorf("Expected input file notes.txt, got %+v", opts)
+	}
+
+	opts, err = parseArgs([]string{"reply", "--", "--not-a-flag"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.Input != "--not-a-flag" {
+		t.Errorf("Expected input after --, got '%s'", opts.Input)
+	}
+
+	if _, err := parseArgs([]string{"reply", "hello", "--input-file", "notes.txt"}); err == nil {
+		t.Error("Expected error when combining --input-file with positional input")
+	}
+	if _, err := parseArgs([]string{"reply", "--input-format", "hex"}); err == nil {
+		t.Error("Expected error for unknown --input-format")
+	}
+
+	opts, err = parseArgs([]string{"run", "scope", "reply", "--theme", "light"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(opts.FlowNames) != 2 || opts.FlowNames[1] != "reply" || opts.Input != "" {
+		t.Errorf("Expected flows scope and reply, got %+v", opts)
+	}
+	if _, err := parseArgs([]string{"run", "scope", "reply", "--watch"}); err == nil {
+		t.Error("Expected error when combining --watch with several flows")
+	}
+	if _, err := parseArgs([]string{"run", "scope", "reply", "--format", "json-pretty"}); err == nil {
+		t.Error("Expected error when combining --format with several flows")
+	}
+
+	opts, err = parseArgs([]string{"sum", "--stdin", "--no-tui"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if opts.InputFile != "-" || !opts.NoTUI {
+		t.Errorf("Expected --stdin to read input from stdin, got %+v", opts)
+	}
+	if _, err := parseArgs([]string{"sum", "--stdin", "--input-file", "notes.txt"}); err == nil {
+		t.Error("Expected error when combining --stdin with --input-file")
+	}
+
+	if opts, err = parseArgs([]string{"sum", "--quiet"}); err != nil || !opts.Quiet || !opts.NoTUI {
+		t.Errorf("Expected --quiet to imply --no-tui, got %+v (%v)", opts, err)
+	}
+	if opts, err = parseArgs([]string{"sum", "--json-output"}); err != nil || !opts.JSONOutput || !opts.Quiet || !opts.NoTUI {
+		t.Errorf("Expected --json-output to imply --quiet, got %+v (%v)", opts, err)
+	}
+	if _, err := parseArgs([]string{"run", "scope", "reply", "--trace"}); err == nil {
+		t.Error("Expected error when combining --trace with several flows")
+	}
+	if _, err := parseArgs([]string{"run", "scope", "reply", "--quiet"}); err == nil {
+		t.Error("Expected error when combining --quiet with several flows")
+	}
+	if opts, err = parseArgs([]string{"sum", "--explain"}); err != nil || !opts.Explain {
+		t.Errorf("Expected --explain, got %+v (%v)", opts, err)
+	}
+	if opts, err = parseArgs([]string{"sum", "--record", "demo"}); err != nil || opts.Record != "demo" {
+		t.Errorf("Expected --record demo, got %+v (%v)", opts, err)
+	}
+	if _, err := parseArgs([]string{"sum", "--record", "demo", "--no-tui"}); err == nil {
+		t.Error("Expected error when combining --record with --no-tui")
+	}
+	if opts, err = parseArgs([]string{"sum", "--step-order"}); err != nil || !opts.StepOrder {
+		t.Errorf("Expected --step-order, got %+v (%v)", opts, err)
+	}
+	if _, err := parseArgs([]string{"sum", "--export", "docx"}); err == nil {
+		t.Error("Expected error for unknown --export")
+	}
+	if _, err := parseArgs([]string{"run", "scope", "reply", "--explain"}); err == nil {
+		t.Error("Expected error when combining --explain with several flows")
+	}
+
+	if opts, err = parseArgs([]string{"sum", "--profile", "cpu"}); err != nil || opts.Profile != "cpu" {
+		t.Errorf("Expected --profile cpu, got %+v (%v)", opts, err)
+	}
+	if _, err := parseArgs([]string{"sum", "--profile", "gpu"}); err == nil {
+		t.Error("Expected error for unknown --profile")
+	}
+
+	opts, err = parseArgs([]string{"sum", "--mock-step", "draft=A test draft", "--mock-step", "notes=n"})
+	if err != nil || opts.Mock["draft"] != "A test draft" || opts.Mock["notes"] != "n" {
+		t.Errorf("Expected two mocked steps, got %+v (%v)", opts.Mock, err)
+	}
+	if _, err := parseArgs([]string{"sum", "--mock-step", "draft=x", "--set", "draft=y"}); err == nil {
+		t.Error("Expected error when a step is both mocked and set")
+	}
+	if _, err := parseArgs([]string{"sum", "--mock-step", "draft="}); err == nil {
+		t.Error("Expected error for an empty mock value")
+	}
+}
+
+func TestReadInputFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+
+	if err := os.WriteFile(filepath.Join(dir, "plain.txt"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "encoded.txt"), []byte("aGVsbG8=\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := readInputFile("~/plain.txt", "text")
+	if err != nil || got != "hello" {
+		t.Errorf("Expected 'hello', got '%s' (err %v)", got, err)
+	}
+
+	got, err = readInputFile(filepath.Join(dir, "encoded.txt"), "base64")
+	if err != nil || got != "hello" {
+		t.Errorf("Expected decoded 'hello', got '%s' (err %v)", got, err)
+	}
+}
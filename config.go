@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GlobalConfig holds user-wide settings from ~/fast-flows/config.json.
+type GlobalConfig struct {
+	MaxLogAge   string `json:"max_log_age"`
+	MaxLogCount int    `json:"max_log_count"`
+	Theme       string `json:"theme"`
+
+	// ParallelLimit caps how many steps of a flow run at once (0 = no limit).
+	ParallelLimit int `json:"parallel_limit"`
+
+	// WebhookToken is the bearer token `fast serve` requires on every request.
+	WebhookToken string `json:"webhook_token,omitempty"`
+
+	RateLimit RateLimitConfig `json:"rate_limit"`
+
+	// Gemini configures the Gemini API, e.g. several keys to spread calls over.
+	Gemini ProviderConfig `json:"gemini"`
+
+	// Personas maps short names usable in a step's persona to full
+	// descriptions, e.g. "editor": "meticulous copy editor".
+	Personas map[string]string `json:"personas,omitempty"`
+
+	TUI TUIConfig `json:"tui"`
+
+	// NotifyOnComplete and NotifyOnFailure are the defaults for flows that
+	// don't set notify_on_complete / notify_on_failure themselves.
+	NotifyOnComplete bool `json:"notify_on_complete,omitempty"`
+	NotifyOnFailure  bool `json:"notify_on_failure,omitempty"`
+
+	// StreamingLogFile is where step events and tokens are appended as
+	// NDJSON while flows run (see --stream-log).
+	StreamingLogFile string `json:"streaming_log_file,omitempty"`
+}
+
+// TUIConfig tweaks how the TUI looks.
+type TUIConfig struct {
+	// SpinnerStyle is one of dot, line, globe, moon or bounce.
+	SpinnerStyle string `json:"spinner_style"`
+	// SpinnerFPS is how many frames per second the spinner shows.
+	SpinnerFPS int `json:"spinner_fps"`
+	// Layout shows the steps as a "tree", a numbered "flat" list, or
+	// "auto": flat unless some steps run in parallel.
+	Layout string `json:"layout"`
+	// ColorByDuration colors finished steps green, yellow, orange or red by
+	// how long they took, split at DurationThresholds (in seconds).
+	ColorByDuration    bool      `json:"color_by_duration,omitempty"`
+	DurationThresholds []float64 `json:"duration_thresholds,omitempty"`
+}
+
+var globalConfig = defaultGlobalConfig()
+
+func defaultGlobalConfig() GlobalConfig {
+	return GlobalConfig{
+		MaxLogAge:     "30d",
+		MaxLogCount:   500,
+		Theme:         "dark",
+		ParallelLimit: 5,
+		RateLimit:     RateLimitConfig{RequestsPerMinute: 60},
+		TUI:           TUIConfig{SpinnerStyle: "dot", SpinnerFPS: 10, Layout: "auto", DurationThresholds: []float64{2, 10, 30}},
+	}
+}
+
+// resolveFastFlowsDir returns the directory holding global flows, logs and
+// config.json: $FAST_FLOWS_DIR if set, otherwise ~/fast-flows.
+func resolveFastFlowsDir() string {
+	if dir := os.Getenv("FAST_FLOWS_DIR"); dir != "" {
+		return expandHome(dir)
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, "fast-flows")
+}
+
+// loadGlobalConfig reads config.json from the fast-flows directory on top
+// of the defaults. A missing file is not an error.
+func loadGlobalConfig() (GlobalConfig, error) {
+	conf := defaultGlobalConfig()
+	data, err := os.ReadFile(filepath.Join(resolveFastFlowsDir(), "config.json"))
+	if os.IsNotExist(err) {
+		return conf, nil
+	}
+	if err != nil {
+		return conf, err
+	}
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return conf, fmt.Errorf("failed to parse config.json: %w", err)
+	}
+	if err := validateProviderConfig("gemini", conf.Gemini); err != nil {
+		return conf, err
+	}
+	if _, ok := spinnerStyles[conf.TUI.SpinnerStyle]; !ok {
+		return conf, fmt.Errorf("unknown tui.spinner_style %q in config.json (expected dot, line, globe, moon or bounce)", conf.TUI.SpinnerStyle)
+	}
+	if conf.TUI.SpinnerFPS <= 0 {
+		return conf, fmt.Errorf("tui.spinner_fps in config.json must be positive, got %d", conf.TUI.SpinnerFPS)
+	}
+	if err := validateDurationThresholds(conf.TUI.DurationThresholds); err != nil {
+		return conf, err
+	}
+	switch conf.TUI.Layout {
+	case "tree", "flat", "auto":
+	default:
+		return conf, fmt.Errorf("unknown tui.layout %q in config.json (expected tree, flat or auto)", conf.TUI.Layout)
+	}
+	return conf, nil
+}
+
+// validateDurationThresholds checks tui.duration_thresholds: three
+// increasing, positive numbers of seconds.
+func validateDurationThresholds(t []float64) error {
+	if len(t) != 3 || t[0] <= 0 || t[1] <= t[0] || t[2] <= t[1] {
+		return fmt.Errorf("tui.duration_thresholds in config.json must be three increasing positive numbers of seconds, got %v", t)
+	}
+	return nil
+}
+
+const projectConfigFile = "fast.json"
+
+// ProjectConfig holds defaults from ./fast.json that apply to every flow run
+// from that directory unless the flow sets them itself.
+type ProjectConfig struct {
+	Model        string `json:"model"`
+	SystemPrompt string `json:"system_prompt"`
+}
+
+// applyProjectConfig fills in the model and system prompt from ./fast.json.
+func applyProjectConfig(conf *Config) error {
+	data, err := os.ReadFile(projectConfigFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var project ProjectConfig
+	if err := json.Unmarshal(data, &project); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", projectConfigFile, err)
+	}
+	if conf.Model == "" {
+		conf.Model = project.Model
+	}
+	if conf.SystemPrompt == "" {
+		conf.SystemPrompt = project.SystemPrompt
+	}
+	return nil
+}
+
+// parseAge parses a duration that may also use a day suffix, e.g. "30d".
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
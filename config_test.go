@@ -0,0 +1,64 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+)
+
+func TestResolveFastFlowsDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("FAST_FLOWS_DIR", "")
+	if got := resolveFastFlowsDir(); got != filepath.Join(home, "fast-flows") {
+		t.Errorf("Expected ~/fast-flows, got %s", got)
+	}
+
+	shared := t.TempDir()
+	t.Setenv("FAST_FLOWS_DIR", shared)
+	if got := resolveFastFlowsDir(); got != shared {
+		t.Errorf("Expected %s, got %s", shared, got)
+	}
+
+	// Flows, logs and config.json all come from the override
+	os.MkdirAll(filepath.Join(shared, "flows"), 0755)
+	os.WriteFile(filepath.Join(shared, "flows", "team.json"), []byte(`{"steps": []}`), 0644)
+	os.WriteFile(filepath.Join(shared, "config.json"), []byte(`{"theme": "light"}`), 0644)
+	t.Chdir(t.TempDir())
+
+	if path, _, err := findFlow("team"); err != nil || path != filepath.Join(shared, "flows", "team.json") {
+		t.Errorf("Expected the shared flow, got %s (%v)", path, err)
+	}
+	if dir := logsDir(); dir != filepath.Join(shared, "logs") {
+		t.Errorf("Expected shared logs, got %s", dir)
+	}
+	if conf, err := loadGlobalConfig(); err != nil || conf.Theme != "light" {
+		t.Errorf("Expected the shared config, got %+v (%v)", conf, err)
+	}
+}
+
+func TestSpinnerConfig(t *testing.T) {
+	sp := newSpinner(TUIConfig{SpinnerStyle: "moon", SpinnerFPS: 4})
+	if sp.Frames[0] != spinner.Moon.Frames[0] || sp.FPS != 250*time.Millisecond {
+		t.Errorf("Expected the moon spinner at 4 fps, got %v at %s", sp.Frames, sp.FPS)
+	}
+	if sp := newSpinner(TUIConfig{SpinnerStyle: "nope"}); sp.Frames[0] != spinner.Dot.Frames[0] {
+		t.Errorf("Expected the dot spinner for an unknown style, got %v", sp.Frames)
+	}
+
+	dir := t.TempDir()
+	t.Setenv("FAST_FLOWS_DIR", dir)
+	for _, bad := range []string{`{"tui": {"spinner_style": "square"}}`, `{"tui": {"spinner_fps": -1}}`, `{"tui": {"layout": "grid"}}`} {
+		os.WriteFile(filepath.Join(dir, "config.json"), []byte(bad), 0644)
+		if _, err := loadGlobalConfig(); err == nil {
+			t.Errorf("Expected error for %s", bad)
+		}
+	}
+	os.WriteFile(filepath.Join(dir, "config.json"), []byte(`{"tui": {"spinner_style": "line"}}`), 0644)
+	if conf, err := loadGlobalConfig(); err != nil || conf.TUI.SpinnerStyle != "line" || conf.TUI.SpinnerFPS != 10 || conf.TUI.Layout != "auto" {
+		t.Errorf("Expected line at the default 10 fps and auto layout, got %+v (%v)", conf.TUI, err)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// scheduledFlow is a flow with a `schedule` that `fast daemon` runs.
+type scheduledFlow struct {
+	Name     string
+	Schedule cron.Schedule
+	Spec     string
+}
+
+// runDaemonCommand implements `fast daemon [--list] [--once <flow>]`.
+func runDaemonCommand(args []string) error {
+	fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	list := fs.Bool("list", false, "show scheduled flows and their next run")
+	once := fs.String("once", "", "run this flow immediately and exit")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *once != "" {
+		return runScheduled(context.Background(), *once)
+	}
+
+	flows, err := scheduledFlows()
+	if err != nil {
+		return err
+	}
+	if *list {
+		if len(flows) == 0 {
+			fmt.Println("No flows have a schedule.")
+		}
+		now := time.Now()
+		for _, f := range flows {
+			fmt.Printf("  - %-20s %-15s next run %s\n", f.Name, f.Spec, f.Schedule.Next(now).Format("2006-01-02 15:04"))
+		}
+		return nil
+	}
+	if len(flows) == 0 {
+		return fmt.Errorf("no flows have a schedule")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	c := cron.New()
+	for _, f := range flows {
+		name := f.Name
+		c.Schedule(f.Schedule, cron.FuncJob(func() {
+			if err := runScheduled(ctx, name); err != nil {
+				fmt.Printf("❌ %s: %v\n", name, err)
+			}
+		}))
+		fmt.Printf("⏰ %s scheduled at %q\n", f.Name, f.Spec)
+	}
+	c.Start()
+	fmt.Println("Daemon running, press ctrl+c to stop.")
+
+	<-ctx.Done()
+	// Let running flows finish their session logs
+	<-c.Stop().Done()
+	return nil
+}
+
+// scheduledFlows finds every flow with a valid schedule. Local flows take
+// precedence over global ones with the same name, as in findFlow.
+func scheduledFlows() ([]scheduledFlow, error) {
+	var files []string
+	for _, dir := range []string{"./flows", filepath.Join(resolveFastFlowsDir(), "flows")} {
+		matches, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+		files = append(files, matches...)
+	}
+
+	seen := make(map[string]bool)
+	var flows []scheduledFlow
+	for _, f := range files {
+		name := strings.TrimSuffix(filepath.Base(f), ".json")
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		data, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		var conf Config
+		if err := json.Unmarshal(data, &conf); err != nil || conf.Schedule == "" {
+			continue
+		}
+		sched, err := cron.ParseStandard(conf.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("flow '%s' has an invalid schedule %q: %w", name, conf.Schedule, err)
+		}
+		flows = append(flows, scheduledFlow{Name: name, Schedule: sched, Spec: conf.Schedule})
+	}
+	sort.Slice(flows, func(i, j int) bool { return flows[i].Name < flows[j].Name })
+	return flows, nil
+}
+
+// runScheduled runs a flow headlessly and posts a desktop notification.
+func runScheduled(ctx context.Context, flowName string) error {
+	result, err := runHeadless(ctx, flowName, "")
+	if err != nil {
+		notify("fast: "+flowName+" failed", err.Error())
+		return err
+	}
+	notify("fast: "+flowName+" finished", resultPreview(result))
+	return nil
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScheduledFlows(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	t.Setenv("HOME", dir)
+
+	writeFlow := func(path, body string) {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFlow("flows/daily.json", `{"schedule": "0 9 * * *", "steps": [{"id": "a", "prompt": "hi"}]}`)
+	writeFlow("flows/manual.json", `{"steps": [{"id": "a", "prompt": "hi"}]}`)
+	// A local flow overrides the global one with the same name
+	writeFlow(filepath.Join(dir, "fast-flows", "flows", "daily.json"), `{"schedule": "not a cron"}`)
+
+	flows, err := scheduledFlows()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(flows) != 1 || flows[0].Name != "daily" || flows[0].Spec != "0 9 * * *" {
+		t.Errorf("Expected only the local daily flow, got %+v", flows)
+	}
+
+	writeFlow("flows/broken.json", `{"schedule": "every day"}`)
+	if _, err := scheduledFlows(); err == nil {
+		t.Error("Expected error for an invalid schedule")
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+var (
+	diffAddStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+	diffRemoveStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+)
+
+// DiffLine is a single line of a step output diff, prefixed by Op ("+", "-" or " ").
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// StepDiff describes how one step's output changed between two runs.
+type StepDiff struct {
+	StepID string     `json:"step"`
+	Status string     `json:"status"` // added, removed, changed or unchanged
+	Lines  []DiffLine `json:"lines,omitempty"`
+}
+
+// runDiffCommand implements `fast diff <log1> <log2> [--format json]`.
+func runDiffCommand(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	format := fs.String("format", "text", "output format: text or json")
+
+	var paths []string
+	for len(args) > 0 {
+		if err := fs.Parse(args); err != nil {
+			return err
+		}
+		if fs.NArg() == 0 {
+			break
+		}
+		paths = append(paths, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(paths) != 2 {
+		return fmt.Errorf("usage: fast diff <log1> <log2> [--format json]")
+	}
+
+	a, err := loadSessionLog(paths[0])
+	if err != nil {
+		return err
+	}
+	b, err := loadSessionLog(paths[1])
+	if err != nil {
+		return err
+	}
+
+	diffs := diffSessionLogs(a, b)
+	if *format == "json" {
+		data, err := json.MarshalIndent(diffs, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	for _, d := range diffs {
+		fmt.Println(titleStyle.Render(fmt.Sprintf("%s (%s)", d.StepID, d.Status)))
+		for _, l := range d.Lines {
+			line := l.Op + " " + l.Text
+			switch l.Op {
+			case "+":
+				line = diffAddStyle.Render(line)
+			case "-":
+				line = diffRemoveStyle.Render(line)
+			}
+			fmt.Println(line)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// loadSessionLog reads a session log by path, falling back to the logs folder
+// so that bare filenames like `2024-01-01_10-00-00_scope.json` work.
+func loadSessionLog(path string) (SessionLog, error) {
+	var log SessionLog
+	data, err := os.ReadFile(path)
+	if err != nil {
+		data, err = os.ReadFile(filepath.Join(logsDir(), path))
+	}
+	if err != nil {
+		return log, fmt.Errorf("failed to read log '%s': %w", path, err)
+	}
+	if err := json.Unmarshal(data, &log); err != nil {
+		return log, fmt.Errorf("failed to parse log '%s': %w", path, err)
+	}
+	return log, nil
+}
+
+// diffSessionLogs compares the step results of two runs, ordered by step ID.
+func diffSessionLogs(a, b SessionLog) []StepDiff {
+	ids := make(map[string]bool)
+	for id := range a.Results {
+		ids[id] = true
+	}
+	for id := range b.Results {
+		ids[id] = true
+	}
+	sorted := make([]string, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Strings(sorted)
+
+	var diffs []StepDiff
+	for _, id := range sorted {
+		before, inA := a.Results[id]
+		after, inB := b.Results[id]
+		d := StepDiff{StepID: id, Lines: diffLines(before, after)}
+		switch {
+		case !inA:
+			d.Status = "added"
+		case !inB:
+			d.Status = "removed"
+		case before == after:
+			d.Status = "unchanged"
+			d.Lines = nil
+		default:
+			d.Status = "changed"
+		}
+		diffs = append(diffs, d)
+	}
+	return diffs
+}
+
+// diffLines produces a line-level diff of two texts.
+func diffLines(before, after string) []DiffLine {
+	dmp := diffmatchpatch.New()
+	chars1, chars2, lineArray := dmp.DiffLinesToChars(before, after)
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(chars1, chars2, false), lineArray)
+
+	var lines []DiffLine
+	for _, d := range diffs {
+		op := " "
+		switch d.Type {
+		case diffmatchpatch.DiffInsert:
+			op = "+"
+		case diffmatchpatch.DiffDelete:
+			op = "-"
+		}
+		for _, text := range strings.Split(strings.TrimSuffix(d.Text, "\n"), "\n") {
+			lines = append(lines, DiffLine{Op: op, Text: text})
+		}
+	}
+	return lines
+}
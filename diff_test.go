@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestDiffSessionLogs(t *testing.T) {
+	a := SessionLog{Results: map[string]string{
+		"same":    "hello",
+		"changed": "line1\nline2\n",
+		"gone":    "bye",
+	}}
+	b := SessionLog{Results: map[string]string{
+		"same":    "hello",
+		"changed": "line1\nline3\n",
+		"new":     "hi",
+	}}
+
+	diffs := diffSessionLogs(a, b)
+	status := make(map[string]string)
+	for _, d := range diffs {
+		status[d.StepID] = d.Status
+	}
+
+	expected := map[string]string{"same": "unchanged", "changed": "changed", "gone": "removed", "new": "added"}
+	for id, want := range expected {
+		if status[id] != want {
+			t.Errorf("Expected %s to be %s, got %s", id, want, status[id])
+		}
+	}
+
+	for _, d := range diffs {
+		if d.StepID != "changed" {
+			continue
+		}
+		want := []DiffLine{{" ", "line1"}, {"-", "line2"}, {"+", "line3"}}
+		if len(d.Lines) != len(want) {
+			t.Fatalf("Expected %d diff lines, got %+v", len(want), d.Lines)
+		}
+		for i := range want {
+			if d.Lines[i] != want[i] {
+				t.Errorf("Line %d: expected %+v, got %+v", i, want[i], d.Lines[i])
+			}
+		}
+	}
+}
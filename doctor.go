@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
+)
+
+// doctorCheck is the outcome of one `fast doctor` check.
+type doctorCheck struct {
+	Name   string
+	OK     bool
+	Detail string
+	// Hint tells the user how to fix a failed check.
+	Hint string
+}
+
+var errDoctorFailed = errors.New("some checks failed")
+
+// runDoctorChecks checks everything `fast` needs to run flows.
+func runDoctorChecks() []doctorCheck {
+	var checks []doctorCheck
+
+	key := doctorCheck{Name: "API key"}
+	switch {
+	case len(globalConfig.Gemini.APIKeys) > 0:
+		key.OK = true
+		key.Detail = fmt.Sprintf("%d keys in config.json", len(globalConfig.Gemini.APIKeys))
+	case os.Getenv("GEMINI_API_KEY") != "":
+		key.OK = true
+		key.Detail = "GEMINI_API_KEY is set"
+	case getAPIKey() != "":
+		key.OK = true
+		key.Detail = "read from ~/.fast_key"
+	default:
+		key.Detail = "GEMINI_API_KEY is not set and ~/.fast_key is empty"
+		key.Hint = "export GEMINI_API_KEY=... or run the installer again"
+	}
+	checks = append(checks, key)
+
+	root := resolveFastFlowsDir()
+	dir := doctorCheck{Name: "fast-flows folder", Detail: root}
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		dir.Detail = root + " does not exist"
+		dir.Hint = "run `fast init --global`"
+	} else if f, err := os.CreateTemp(root, ".doctor"); err != nil {
+		dir.Detail = root + " is not writable"
+		dir.Hint = "check the permissions of " + root
+	} else {
+		f.Close()
+		os.Remove(f.Name())
+		dir.OK = true
+	}
+	checks = append(checks, dir)
+
+	conf := doctorCheck{Name: "config.json", OK: true, Detail: "valid"}
+	if _, err := os.Stat(filepath.Join(root, "config.json")); os.IsNotExist(err) {
+		conf.Detail = "not present, using defaults"
+	} else if _, err := loadGlobalConfig(); err != nil {
+		conf.OK = false
+		conf.Detail = err.Error()
+		conf.Hint = "fix or remove " + filepath.Join(root, "config.json")
+	}
+	checks = append(checks, conf)
+
+	clip := doctorCheck{Name: "Clipboard", OK: true, Detail: "pbpaste and pbcopy found"}
+	for _, tool := range []string{"pbpaste", "pbcopy"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			clip.OK = false
+			clip.Detail = tool + " not found"
+			clip.Hint = "pbpaste/pbcopy ship with macOS; elsewhere use --input-file or --stdin, and --no-tui to get the result"
+			break
+		}
+	}
+	checks = append(checks, clip)
+
+	local, _ := filepath.Glob(filepath.Join("flows", "*.json"))
+	global, _ := filepath.Glob(filepath.Join(root, "flows", "*.json"))
+	flows := doctorCheck{Name: "Flows", OK: len(local)+len(global) > 0}
+	flows.Detail = fmt.Sprintf("%d in ./flows, %d in %s", len(local), len(global), filepath.Join(root, "flows"))
+	if !flows.OK {
+		flows.Hint = "run `fast init` or `fast templates --copy summarize`"
+	}
+	checks = append(checks, flows)
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		checks = append(checks, doctorCheck{Name: "Go", OK: true, Detail: "built with " + info.GoVersion})
+	}
+	return checks
+}
+
+// runDoctorCommand implements `fast doctor`.
+func runDoctorCommand() error {
+	failed := false
+	for _, c := range runDoctorChecks() {
+		mark := checkMark.String()
+		if !c.OK {
+			mark = crossMark.String()
+			failed = true
+		}
+		fmt.Printf("%s %s: %s\n", mark, c.Name, c.Detail)
+		if c.Hint != "" {
+			fmt.Printf("  👉 %s\n", c.Hint)
+		}
+	}
+	if failed {
+		return errDoctorFailed
+	}
+	return nil
+}
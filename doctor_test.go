@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func doctorResults() map[string]doctorCheck {
+	results := make(map[string]doctorCheck)
+	for _, c := range runDoctorChecks() {
+		results[c.Name] = c
+	}
+	return results
+}
+
+func TestRunDoctorChecks(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("FAST_FLOWS_DIR", filepath.Join(root, "missing"))
+	t.Setenv("GEMINI_API_KEY", "")
+	t.Setenv("PATH", t.TempDir())
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	checks := doctorResults()
+	for _, name := range []string{"API key", "fast-flows folder", "Clipboard", "Flows"} {
+		if c := checks[name]; c.OK || c.Hint == "" {
+			t.Errorf("Expected %s to fail with a hint, got %+v", name, c)
+		}
+	}
+	if !checks["config.json"].OK {
+		t.Errorf("Expected a missing config.json to pass, got %+v", checks["config.json"])
+	}
+
+	t.Setenv("FAST_FLOWS_DIR", root)
+	t.Setenv("GEMINI_API_KEY", "key")
+	if err := os.MkdirAll(filepath.Join(root, "flows"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "flows", "sum.json"), []byte(`{"steps":[]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "config.json"), []byte(`{"theme":`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	checks = doctorResults()
+	for _, name := range []string{"API key", "fast-flows folder", "Flows"} {
+		if !checks[name].OK {
+			t.Errorf("Expected %s to pass, got %+v", name, checks[name])
+		}
+	}
+	if c := checks["config.json"]; c.OK || c.Hint == "" {
+		t.Errorf("Expected a broken config.json to fail, got %+v", c)
+	}
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// pdfInfoKeys are the document info entries reported in metadata mode.
+var pdfInfoKeys = []string{"Title", "Author", "Subject", "Keywords", "Creator", "Producer", "CreationDate", "ModDate"}
+
+// extractDocument implements the `document` step type. The parser is picked
+// from the file extension; mode is "text" (the default, also "auto") or
+// "metadata". pageRange like "1-10" limits which PDF pages are read.
+func extractDocument(path, mode, pageRange string) (string, error) {
+	if mode == "" || mode == "auto" {
+		mode = "text"
+	}
+	if mode != "text" && mode != "metadata" {
+		return "", fmt.Errorf("unknown extract_mode %q (expected auto, text or metadata)", mode)
+	}
+
+	var res string
+	var err error
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".pdf":
+		res, err = extractPDF(path, mode, pageRange)
+	case ".docx":
+		res, err = extractDOCX(path, mode)
+	default:
+		return "", fmt.Errorf("unsupported document type %q (expected .pdf or .docx)", filepath.Ext(path))
+	}
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(res) == "" {
+		return "", fmt.Errorf("no %s found in %s", mode, path)
+	}
+	return res, nil
+}
+
+func extractPDF(path, mode, pageRange string) (string, error) {
+	f, r, err := pdf.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open PDF: %w", err)
+	}
+	defer f.Close()
+
+	total := r.NumPage()
+	if mode == "metadata" {
+		var b strings.Builder
+		info := r.Trailer().Key("Info")
+		for _, key := range pdfInfoKeys {
+			if v := info.Key(key).Text(); v != "" {
+				fmt.Fprintf(&b, "%s: %s\n", key, v)
+			}
+		}
+		fmt.Fprintf(&b, "Pages: %d\n", total)
+		return b.String(), nil
+	}
+
+	from, to, err := parsePageRange(pageRange, total)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	for i := from; i <= to; i++ {
+		text, err := r.Page(i).GetPlainText(nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to read page %d: %w", i, err)
+		}
+		b.WriteString(text)
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
+
+// parsePageRange turns "3", "1-10" or "5-" into an inclusive, 1-based page
+// range clamped to the document. An empty range means every page.
+func parsePageRange(s string, total int) (int, int, error) {
+	if s == "" {
+		return 1, total, nil
+	}
+	start, end, isRange := strings.Cut(s, "-")
+	from, err := strconv.Atoi(strings.TrimSpace(start))
+	if err != nil || from < 1 {
+		return 0, 0, fmt.Errorf("invalid page_range %q", s)
+	}
+	to := from
+	if isRange {
+		to = total
+		if end = strings.TrimSpace(end); end != "" {
+			if to, err = strconv.Atoi(end); err != nil || to < from {
+				return 0, 0, fmt.Errorf("invalid page_range %q", s)
+			}
+		}
+	}
+	if from > total {
+		return 0, 0, fmt.Errorf("page_range %q is past the last page (%d)", s, total)
+	}
+	return from, min(to, total), nil
+}
+
+func extractDOCX(path, mode string) (string, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open DOCX: %w", err)
+	}
+	defer zr.Close()
+
+	name := "word/document.xml"
+	if mode == "metadata" {
+		name = "docProps/core.xml"
+	}
+	f, err := zr.Open(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	defer f.Close()
+
+	if mode == "metadata" {
+		return docxMetadata(f)
+	}
+	return docxText(f)
+}
+
+// docxText collects the text runs of a document.xml, one line per paragraph.
+func docxText(r io.Reader) (string, error) {
+	var b strings.Builder
+	dec := xml.NewDecoder(r)
+	inText := false
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to parse DOCX: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "t":
+				inText = true
+			case "tab":
+				b.WriteString("\t")
+			case "br":
+				b.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "t":
+				inText = false
+			case "p":
+				b.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				b.Write(t)
+			}
+		}
+	}
+	return b.String(), nil
+}
+
+// docxMetadata lists the non-empty properties of a docProps/core.xml.
+func docxMetadata(r io.Reader) (string, error) {
+	var b strings.Builder
+	dec := xml.NewDecoder(r)
+	var current string
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to parse DOCX metadata: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			current = t.Name.Local
+		case xml.EndElement:
+			current = ""
+		case xml.CharData:
+			if v := strings.TrimSpace(string(t)); current != "" && v != "" {
+				fmt.Fprintf(&b, "%s: %s\n", current, v)
+			}
+		}
+	}
+	return b.String(), nil
+}
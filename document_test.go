@@ -0,0 +1,160 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeTestPDF writes a minimal PDF with one line of text per page.
+func writeTestPDF(t *testing.T, path string, pages ...string) {
+	t.Helper()
+	var objs []string
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 4+2*i)
+	}
+	objs = append(objs,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	)
+	for i, text := range pages {
+		content := fmt.Sprintf("BT /F1 12 Tf 72 712 Td (%s) Tj ET", text)
+		objs = append(objs,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", 5+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content),
+		)
+	}
+	objs = append(objs, "<< /Title (Test Report) /Author (Flow) >>")
+
+	var b strings.Builder
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objs))
+	for i, o := range objs {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, o)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objs)+1, len(objs), xref)
+
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTestDOCX(t *testing.T, path string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	files := map[string]string{
+		"word/document.xml": `<w:document xmlns:w="w"><w:body>` +
+			`<w:p><w:r><w:t>First</w:t></w:r><w:r><w:tab/><w:t>para</w:t></w:r></w:p>` +
+			`<w:p><w:r><w:t>Second</w:t></w:r></w:p></w:body></w:document>`,
+		"docProps/core.xml": `<cp:coreProperties xmlns:cp="cp" xmlns:dc="dc">` +
+			`<dc:title>Spec</dc:title><dc:creator>Ana</dc:creator></cp:coreProperties>`,
+	}
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractDocument(t *testing.T) {
+	dir := t.TempDir()
+	pdfPath := filepath.Join(dir, "report.pdf")
+	writeTestPDF(t, pdfPath, "Page one", "Page two", "Page three")
+
+	got, err := extractDocument(pdfPath, "auto", "2-3")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Contains(got, "Page one") || !strings.Contains(got, "Page two") || !strings.Contains(got, "Page three") {
+		t.Errorf("Expected pages 2-3 only, got %q", got)
+	}
+
+	got, err = extractDocument(pdfPath, "metadata", "")
+	if err != nil || !strings.Contains(got, "Title: Test Report") || !strings.Contains(got, "Pages: 3") {
+		t.Errorf("Unexpected PDF metadata %q (err %v)", got, err)
+	}
+
+	docxPath := filepath.Join(dir, "spec.DOCX")
+	writeTestDOCX(t, docxPath)
+	got, err = extractDocument(docxPath, "text", "")
+	if err != nil || got != "First\tpara\nSecond\n" {
+		t.Errorf("Unexpected DOCX text %q (err %v)", got, err)
+	}
+	got, err = extractDocument(docxPath, "metadata", "")
+	if err != nil || got != "title: Spec\ncreator: Ana\n" {
+		t.Errorf("Unexpected DOCX metadata %q (err %v)", got, err)
+	}
+
+	if _, err := extractDocument(filepath.Join(dir, "notes.txt"), "", ""); err == nil {
+		t.Error("Expected error for unsupported extension")
+	}
+}
+
+func TestParsePageRange(t *testing.T) {
+	cases := []struct {
+		in       string
+		from, to int
+		ok       bool
+	}{
+		{"", 1, 10, true},
+		{"3", 3, 3, true},
+		{"2-5", 2, 5, true},
+		{"8-", 8, 10, true},
+		{"5-20", 5, 10, true},
+		{"0-2", 0, 0, false},
+		{"5-2", 0, 0, false},
+		{"11", 0, 0, false},
+		{"a-b", 0, 0, false},
+	}
+	for _, c := range cases {
+		from, to, err := parsePageRange(c.in, 10)
+		if (err == nil) != c.ok || from != c.from || to != c.to {
+			t.Errorf("parsePageRange(%q) = %d, %d, %v", c.in, from, to, err)
+		}
+	}
+}
+
+func TestRunFlowDocumentStep(t *testing.T) {
+	originalCallGemini := callGemini
+	defer func() { callGemini = originalCallGemini }()
+	callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+		return "Summary of: " + prompt, 0, nil
+	}
+
+	pdfPath := filepath.Join(t.TempDir(), "report.pdf")
+	writeTestPDF(t, pdfPath, "Quarterly numbers")
+
+	run := newFlowRun(pdfPath)
+	conf := Config{Steps: []Step{
+		{ID: "doc", Type: "document", Filename: "{{input}}"},
+		{ID: "summary", Prompt: "{{doc}}"},
+	}}
+	if err := runFlow(context.Background(), run, conf, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(run.GetResult("summary"), "Quarterly numbers") {
+		t.Errorf("Expected summary of the PDF text, got %q", run.GetResult("summary"))
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// parseDotenv parses a .env file: KEY=VALUE lines with optional "export ",
+// blank lines and # comments. Values may be wrapped in single quotes (taken
+// literally) or double quotes (\n, \t, \" and \\ are unescaped); unquoted
+// values end at " #".
+func parseDotenv(data string) (map[string]string, error) {
+	vars := make(map[string]string)
+	for i, line := range strings.Split(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", i+1)
+		}
+		value = strings.TrimSpace(value)
+
+		switch {
+		case len(value) >= 2 && value[0] == '\'' && strings.IndexByte(value[1:], '\'') >= 0:
+			value = value[1 : 1+strings.IndexByte(value[1:], '\'')]
+		case len(value) >= 2 && value[0] == '"':
+			end := closingQuote(value)
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated quote", i+1)
+			}
+			value = strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\"`, `"`, `\\`, `\`).Replace(value[1:end])
+		default:
+			if j := strings.Index(value, " #"); j >= 0 {
+				value = strings.TrimSpace(value[:j])
+			}
+		}
+		vars[key] = value
+	}
+	return vars, nil
+}
+
+// closingQuote returns the index of the unescaped " ending a double-quoted
+// value, or -1.
+func closingQuote(value string) int {
+	for i := 1; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// loadEnvFile sets the variables of a .env file that aren't already set in
+// the environment, so the shell always wins.
+func loadEnvFile(path string) error {
+	data, err := os.ReadFile(expandHome(path))
+	if err != nil {
+		return err
+	}
+	vars, err := parseDotenv(string(data))
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	for key, value := range vars {
+		if _, ok := os.LookupEnv(key); ok {
+			continue
+		}
+		if err := os.Setenv(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
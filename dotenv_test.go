@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseDotenv(t *testing.T) {
+	data := `# API keys
+GEMINI_API_KEY=abc123
+export REGION = eu-west
+EMPTY=
+
+PLAIN=value # trailing comment
+HASH=a#b
+SINGLE='no $expansion \n here'
+DOUBLE="line one\nline \"two\""
+URL=https://example.com/?a=b
+`
+	vars, err := parseDotenv(data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{
+		"GEMINI_API_KEY": "abc123",
+		"REGION":         "eu-west",
+		"EMPTY":          "",
+		"PLAIN":          "value",
+		"HASH":           "a#b",
+		"SINGLE":         `no $expansion \n here`,
+		"DOUBLE":         "line one\nline \"two\"",
+		"URL":            "https://example.com/?a=b",
+	}
+	if len(vars) != len(want) {
+		t.Errorf("Expected %d variables, got %v", len(want), vars)
+	}
+	for k, v := range want {
+		if vars[k] != v {
+			t.Errorf("%s = %q, want %q", k, vars[k], v)
+		}
+	}
+
+	for _, bad := range []string{"NOVALUE", "=x", "TWO WORDS=x", `Q="open`} {
+		if _, err := parseDotenv(bad); err == nil {
+			t.Errorf("Expected error for %q", bad)
+		}
+	}
+}
+
+func TestEnvFileSetsFlowsDir(t *testing.T) {
+	shared := t.TempDir()
+	if err := os.WriteFile(filepath.Join(shared, "config.json"), []byte(`{"theme": "light"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte("FAST_FLOWS_DIR="+shared+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FAST_FLOWS_DIR", "")
+	os.Unsetenv("FAST_FLOWS_DIR")
+	defer func(conf GlobalConfig) { globalConfig = conf }(globalConfig)
+
+	if _, err := parseRunArgs([]string{"scope", "--env-file", path}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if globalConfig.Theme != "light" {
+		t.Errorf("Expected config.json from the FAST_FLOWS_DIR in the env file, got theme %q", globalConfig.Theme)
+	}
+}
+
+func TestLoadEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte("FAST_TEST_NEW=from-file\nFAST_TEST_SET=from-file\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("FAST_TEST_SET", "from-shell")
+	t.Setenv("FAST_TEST_NEW", "")
+	os.Unsetenv("FAST_TEST_NEW")
+
+	if err := loadEnvFile(path); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := os.Getenv("FAST_TEST_NEW"); got != "from-file" {
+		t.Errorf("Expected FAST_TEST_NEW from the file, got %q", got)
+	}
+	if got := os.Getenv("FAST_TEST_SET"); got != "from-shell" {
+		t.Errorf("Expected the shell value to win, got %q", got)
+	}
+}
@@ -0,0 +1,995 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textarea"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	editorListStyle   = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("241")).Padding(0, 1)
+	editorDetailStyle = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("241")).Padding(0, 1)
+	editorLabelStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("214")).Width(10)
+)
+
+// tagBadgeColors are the colors tag badges cycle through; a tag always gets
+// the same one.
+var tagBadgeColors = []string{"39", "170", "76", "214", "203", "141"}
+
+const editorListWidth = 24
+
+// maxEditorHistory caps the number of undo snapshots kept by the editor.
+const maxEditorHistory = 50
+
+type editorMode int
+
+const (
+	editorBrowse editorMode = iota
+	editorEditing
+	editorConfirmQuit
+	editorConfirmDelete
+	editorNewStep
+	editorConfirmSave
+	editorTagFilter
+)
+
+// editorField is the step field being edited in the textarea.
+type editorField string
+
+const (
+	fieldPrompt   editorField = "prompt"
+	fieldModel    editorField = "model"
+	fieldID       editorField = "id"
+	fieldType     editorField = "type"
+	fieldFilename editorField = "filename"
+	fieldSourceA  editorField = "source_a"
+	fieldSourceB  editorField = "source_b"
+	fieldSource   editorField = "source"
+)
+
+// editorStepTypes are the step types offered when adding a step.
+var editorStepTypes = []string{"text", "document", "json_diff", "metrics"}
+
+// stepField returns the field of s that f edits.
+func stepField(s *Step, f editorField) *string {
+	switch f {
+	case fieldModel:
+		return &s.Model
+	case fieldID:
+		return &s.ID
+	case fieldType:
+		return &s.Type
+	case fieldFilename:
+		return &s.Filename
+	case fieldSourceA:
+		return &s.SourceA
+	case fieldSourceB:
+		return &s.SourceB
+	case fieldSource:
+		return &s.Source
+	}
+	return &s.Prompt
+}
+
+// contentFields are the fields that say what a step of this type works on:
+// its prompt, the document it reads or the results it compares or measures.
+func contentFields(typ string) []editorField {
+	switch typ {
+	case "document":
+		return []editorField{fieldFilename}
+	case "json_diff":
+		return []editorField{fieldSourceA, fieldSourceB}
+	case "metrics":
+		return []editorField{fieldSource}
+	}
+	return []editorField{fieldPrompt}
+}
+
+// newStepFields are the fields of the new step form for a step type, in
+// order. json_diff and metrics steps don't call a model.
+func newStepFields(typ string) []editorField {
+	fields := []editorField{fieldID, fieldType}
+	if typ == "text" || typ == "document" {
+		fields = append(fields, fieldModel)
+	}
+	return append(fields, contentFields(typ)...)
+}
+
+// FlowEditorModel lets the user browse and edit the steps of a flow and
+// write the result back to the flow file.
+type FlowEditorModel struct {
+	Config   Config
+	Path     string
+	Cursor   int
+	Mode     editorMode
+	Field    editorField
+	Input    textarea.Model
+	NewStep  Step // draft filled in by the new step form
+	NewField int  // index into newStepFields
+	Dirty    bool
+
+	// history holds snapshots of Config after each change; historyIdx is the
+	// one currently shown and savedIdx the one last written (-1 if dropped).
+	history    []Config
+	historyIdx int
+	savedIdx   int
+
+	// TagFilter holds the tags picked in the tag filter; when it isn't empty
+	// only steps with one of them are listed. TagCursor is the highlighted
+	// row of the checklist.
+	TagFilter map[string]bool
+	TagCursor int
+
+	// SaveDiff compares the file on disk with the flow about to be written
+	// while a save waits for confirmation; DiffScroll is its first visible row.
+	SaveDiff   []diffRow
+	DiffScroll int
+
+	Status        string
+	Width, Height int
+	Quitting      bool
+}
+
+func NewFlowEditorModel(conf Config, path string) FlowEditorModel {
+	ta := textarea.New()
+	ta.ShowLineNumbers = false
+	return FlowEditorModel{
+		Config:  conf,
+		Path:    path,
+		Input:   ta,
+		history: []Config{cloneConfig(conf)},
+		Width:   80,
+		Height:  24,
+	}
+}
+
+// cloneConfig copies conf so a snapshot doesn't share steps, or the slices
+// and maps inside them, with the live config.
+func cloneConfig(conf Config) Config {
+	conf.Steps = slices.Clone(conf.Steps)
+	for i := range conf.Steps {
+		s := &conf.Steps[i]
+		s.Tags = slices.Clone(s.Tags)
+		s.DependsOn = slices.Clone(s.DependsOn)
+		s.RetryOn = slices.Clone(s.RetryOn)
+		s.SplitOutput = maps.Clone(s.SplitOutput)
+		s.ModelConfig = cloneModelConfig(s.ModelConfig)
+		if s.TrimWhitespace != nil {
+			trim := *s.TrimWhitespace
+			s.TrimWhitespace = &trim
+		}
+	}
+	conf.ModelConfig = cloneModelConfig(conf.ModelConfig)
+	return conf
+}
+
+// cloneModelConfig deep-copies a model_config, including nested settings
+// like stop_sequences and safety_settings.
+func cloneModelConfig(cfg map[string]interface{}) map[string]interface{} {
+	if cfg == nil {
+		return nil
+	}
+	return cloneJSONValue(cfg).(map[string]interface{})
+}
+
+func cloneJSONValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			out[k] = cloneJSONValue(val)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, val := range v {
+			out[i] = cloneJSONValue(val)
+		}
+		return out
+	}
+	return v
+}
+
+// record marks the flow as changed and adds a snapshot to the undo history,
+// dropping any redo states.
+func (m *FlowEditorModel) record() {
+	m.history = append(m.history[:m.historyIdx+1], cloneConfig(m.Config))
+	m.historyIdx++
+	if m.savedIdx >= m.historyIdx {
+		m.savedIdx = -1
+	}
+	if len(m.history) > maxEditorHistory {
+		m.history = m.history[1:]
+		m.historyIdx--
+		m.savedIdx--
+	}
+	m.Dirty = true
+}
+
+// restore moves through the undo history by delta steps.
+func (m *FlowEditorModel) restore(delta int) {
+	idx := m.historyIdx + delta
+	if idx < 0 || idx >= len(m.history) {
+		return
+	}
+	m.historyIdx = idx
+	m.Config = cloneConfig(m.history[idx])
+	m.Cursor = min(m.Cursor, max(len(m.Config.Steps)-1, 0))
+	m.Dirty = idx != m.savedIdx
+	m.Status = ""
+}
+
+// runEditCommand implements `fast edit <flowname>`.
+func runEditCommand(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: fast edit <flowname>")
+	}
+	path, data, err := findFlow(args[0])
+	if err != nil {
+		return fmt.Errorf("Flow '%s' not found", args[0])
+	}
+	// Unmarshal directly so project defaults from fast.json aren't written back
+	var conf Config
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return fmt.Errorf("Failed to parse flow configuration: %v", err)
+	}
+
+	if theme, err := themeByName(globalConfig.Theme); err == nil {
+		applyTheme(theme)
+	}
+	_, err = tea.NewProgram(NewFlowEditorModel(conf, path)).Run()
+	return err
+}
+
+func (m FlowEditorModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m FlowEditorModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.Width, m.Height = msg.Width, msg.Height
+		m.Input.SetWidth(m.detailWidth())
+		return m, nil
+	case tea.KeyMsg:
+		switch m.Mode {
+		case editorConfirmQuit:
+			switch msg.String() {
+			case "y", "Y":
+				m.Quitting = true
+				return m, tea.Quit
+			case "n", "N", "esc":
+				m.Mode = editorBrowse
+				m.Status = ""
+			}
+			return m, nil
+		case editorConfirmDelete:
+			if k := msg.String(); k == "y" || k == "Y" {
+				m.deleteStep()
+			}
+			m.Mode = editorBrowse
+			return m, nil
+		case editorNewStep:
+			return m.updateNewStep(msg)
+		case editorTagFilter:
+			return m.updateTagFilter(msg)
+		case editorConfirmSave:
+			switch msg.String() {
+			case "y", "Y":
+				m.Mode = editorBrowse
+				m.save()
+			case "n", "N", "esc":
+				m.Mode = editorBrowse
+				m.Status = ""
+			case "up", "k":
+				m.DiffScroll = max(m.DiffScroll-1, 0)
+			case "down", "j":
+				m.DiffScroll = min(m.DiffScroll+1, max(len(m.SaveDiff)-1, 0))
+			}
+			return m, nil
+		case editorEditing:
+			switch msg.String() {
+			case "esc":
+				m.applyEdit()
+				return m, nil
+			case "tab":
+				// Move on to the next field a json_diff step compares
+				fields := contentFields(m.Config.Steps[m.Cursor].Type)
+				if i := slices.Index(fields, m.Field); i >= 0 && i < len(fields)-1 {
+					m.applyEdit()
+					return m, m.startEdit(fields[i+1])
+				}
+				return m, nil
+			case "ctrl+s":
+				m.applyEdit()
+				m.requestSave()
+				return m, nil
+			}
+			var cmd tea.Cmd
+			m.Input, cmd = m.Input.Update(msg)
+			return m, cmd
+		}
+
+		switch msg.String() {
+		case "ctrl+q", "ctrl+c", "q":
+			if !m.Dirty {
+				m.Quitting = true
+				return m, tea.Quit
+			}
+			m.Mode = editorConfirmQuit
+		case "ctrl+s":
+			m.requestSave()
+		case "ctrl+z":
+			m.restore(-1)
+		case "ctrl+y":
+			m.restore(1)
+		case "up", "k":
+			m.moveCursor(-1)
+		case "down", "j":
+			m.moveCursor(1)
+		case "t":
+			if len(m.allTags()) == 0 {
+				m.Status = subtleStyle.Render("No step has tags")
+				break
+			}
+			m.Mode = editorTagFilter
+			m.TagCursor = 0
+			m.Status = ""
+		case "ctrl+up":
+			m.moveStep(-1)
+		case "ctrl+down":
+			m.moveStep(1)
+		case "n":
+			return m, m.startNewStep()
+		case "d":
+			m.duplicateStep()
+		case "delete", "backspace":
+			if len(m.Config.Steps) > 0 {
+				m.Mode = editorConfirmDelete
+				m.Status = ""
+			}
+		case "enter", "e":
+			if m.Cursor < len(m.Config.Steps) {
+				return m, m.startEdit(contentFields(m.Config.Steps[m.Cursor].Type)[0])
+			}
+		case "m":
+			return m, m.startEdit(fieldModel)
+		case "r":
+			return m, m.startEdit(fieldID)
+		}
+	}
+	return m, nil
+}
+
+// moveCursor selects the next step up (-1) or down (1) that the tag filter
+// shows.
+func (m *FlowEditorModel) moveCursor(delta int) {
+	for i := m.Cursor + delta; i >= 0 && i < len(m.Config.Steps); i += delta {
+		if m.visible(m.Config.Steps[i]) {
+			m.Cursor = i
+			return
+		}
+	}
+}
+
+// visible reports whether s passes the tag filter.
+func (m FlowEditorModel) visible(s Step) bool {
+	if len(m.TagFilter) == 0 {
+		return true
+	}
+	for _, tag := range s.Tags {
+		if m.TagFilter[tag] {
+			return true
+		}
+	}
+	return false
+}
+
+// allTags returns every tag used by a step, sorted.
+func (m FlowEditorModel) allTags() []string {
+	var tags []string
+	for _, s := range m.Config.Steps {
+		tags = append(tags, s.Tags...)
+	}
+	slices.Sort(tags)
+	return slices.Compact(tags)
+}
+
+// updateTagFilter handles the tag checklist: space or enter toggles a tag,
+// esc or t closes it.
+func (m FlowEditorModel) updateTagFilter(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	tags := m.allTags()
+	switch msg.String() {
+	case "up", "k":
+		m.TagCursor = max(m.TagCursor-1, 0)
+	case "down", "j":
+		m.TagCursor = min(m.TagCursor+1, len(tags)-1)
+	case " ", "enter":
+		tag := tags[m.TagCursor]
+		filter := maps.Clone(m.TagFilter)
+		if filter == nil {
+			filter = map[string]bool{}
+		}
+		if filter[tag] {
+			delete(filter, tag)
+		} else {
+			filter[tag] = true
+		}
+		m.TagFilter = filter
+		if m.Cursor < len(m.Config.Steps) && !m.visible(m.Config.Steps[m.Cursor]) {
+			m.Cursor = max(slices.IndexFunc(m.Config.Steps, m.visible), 0)
+		}
+	case "c":
+		m.TagFilter = nil
+	case "esc", "t", "q":
+		m.Mode = editorBrowse
+	}
+	return m, nil
+}
+
+// viewTagFilter renders the tag checklist.
+func (m FlowEditorModel) viewTagFilter() string {
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("Filter by tag") + "\n")
+	for i, tag := range m.allTags() {
+		box := "[ ]"
+		if m.TagFilter[tag] {
+			box = "[x]"
+		}
+		if i == m.TagCursor {
+			box = lipgloss.NewStyle().Reverse(true).Render(box)
+		}
+		b.WriteString(box + " " + tagBadge(tag) + "\n")
+	}
+	return b.String()
+}
+
+// tagBadge renders a tag in its color.
+func tagBadge(tag string) string {
+	h := fnv.New32a()
+	h.Write([]byte(tag))
+	color := tagBadgeColors[h.Sum32()%uint32(len(tagBadgeColors))]
+	return lipgloss.NewStyle().Foreground(lipgloss.Color(color)).Render("#" + tag)
+}
+
+func tagBadges(tags []string) string {
+	badges := make([]string, len(tags))
+	for i, tag := range tags {
+		badges[i] = tagBadge(tag)
+	}
+	return strings.Join(badges, " ")
+}
+
+// moveStep swaps the selected step with its neighbour and keeps it selected.
+// Steps run by dependency, so the order only changes how the flow reads.
+func (m *FlowEditorModel) moveStep(delta int) {
+	to := m.Cursor + delta
+	if to < 0 || to >= len(m.Config.Steps) {
+		return
+	}
+	steps := m.Config.Steps
+	steps[m.Cursor], steps[to] = steps[to], steps[m.Cursor]
+	m.Cursor = to
+	m.record()
+
+	m.Status = ""
+	if cycle := findCycle(steps); cycle != nil {
+		m.Status = fmt.Sprintf("%s Dependency cycle: %s", crossMark, strings.Join(cycle, " → "))
+	}
+}
+
+// startNewStep opens the form for adding a step, with the model pre-filled
+// from the flow.
+func (m *FlowEditorModel) startNewStep() tea.Cmd {
+	m.Mode = editorNewStep
+	m.NewStep = Step{Type: editorStepTypes[0], Model: m.Config.Model}
+	m.NewField = 0
+	m.Status = ""
+	return m.loadNewStepField()
+}
+
+// loadNewStepField puts the current form field into the textarea. The type
+// field is a selector and doesn't use it.
+func (m *FlowEditorModel) loadNewStepField() tea.Cmd {
+	m.Input.SetWidth(m.detailWidth())
+	m.Input.SetHeight(1)
+	field := newStepFields(m.NewStep.Type)[m.NewField]
+	switch field {
+	case fieldType:
+		m.Input.Blur()
+		return nil
+	case fieldPrompt:
+		m.Input.SetHeight(max(m.Height-16, 3))
+	}
+	m.Input.SetValue(*stepField(&m.NewStep, field))
+	return m.Input.Focus()
+}
+
+// storeNewStepField copies the textarea into the draft step. It reports
+// whether the value is valid.
+func (m *FlowEditorModel) storeNewStepField() bool {
+	value := m.Input.Value()
+	switch field := newStepFields(m.NewStep.Type)[m.NewField]; field {
+	case fieldID:
+		value = strings.TrimSpace(value)
+		m.NewStep.ID = value
+		switch {
+		case value == "":
+			m.Status = fmt.Sprintf("%s The step needs an ID", crossMark)
+			return false
+		case strings.ContainsAny(value, " \t\n"):
+			m.Status = fmt.Sprintf("%s Step IDs can't contain spaces", crossMark)
+			return false
+		case m.hasStep(value):
+			m.Status = fmt.Sprintf("%s A step with ID '%s' already exists", crossMark, value)
+			return false
+		}
+	case fieldPrompt:
+		m.NewStep.Prompt = value
+	case fieldType:
+	default:
+		*stepField(&m.NewStep, field) = strings.TrimSpace(value)
+	}
+	m.Status = ""
+	return true
+}
+
+func (m FlowEditorModel) updateNewStep(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	fields := newStepFields(m.NewStep.Type)
+	field := fields[m.NewField]
+	switch msg.String() {
+	case "esc":
+		m.Mode = editorBrowse
+		m.Status = ""
+		m.Input.Blur()
+		return m, nil
+	case "tab":
+		if !m.storeNewStepField() {
+			return m, nil
+		}
+		if m.NewField == len(fields)-1 {
+			m.addNewStep()
+			return m, nil
+		}
+		m.NewField++
+		return m, m.loadNewStepField()
+	case "shift+tab":
+		if m.NewField > 0 {
+			m.storeNewStepField()
+			m.NewField--
+			return m, m.loadNewStepField()
+		}
+		return m, nil
+	}
+
+	if field == fieldType {
+		i := slices.Index(editorStepTypes, m.NewStep.Type)
+		switch msg.String() {
+		case "left", "up", "h", "k":
+			i = (i + len(editorStepTypes) - 1) % len(editorStepTypes)
+		case "right", "down", "l", "j":
+			i = (i + 1) % len(editorStepTypes)
+		}
+		m.NewStep.Type = editorStepTypes[i]
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.Input, cmd = m.Input.Update(msg)
+	return m, cmd
+}
+
+// addNewStep appends the finished draft and selects it, keeping only the
+// fields of its type. A model equal to the flow's default is left out so the
+// step follows the flow.
+func (m *FlowEditorModel) addNewStep() {
+	var s Step
+	for _, field := range newStepFields(m.NewStep.Type) {
+		*stepField(&s, field) = *stepField(&m.NewStep, field)
+	}
+	if s.Model == m.Config.Model {
+		s.Model = ""
+	}
+	if s.Type == "text" {
+		s.Type = ""
+	}
+	m.Config.Steps = append(m.Config.Steps, s)
+	m.Cursor = len(m.Config.Steps) - 1
+	m.Mode = editorBrowse
+	m.Input.Blur()
+	m.record()
+	m.Status = fmt.Sprintf("%s Added %s", checkMark, s.ID)
+}
+
+// viewNewStep renders the new step form.
+func (m FlowEditorModel) viewNewStep() string {
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("New step") + "\n")
+	for i, field := range newStepFields(m.NewStep.Type) {
+		value := *stepField(&m.NewStep, field)
+		if field == fieldType {
+			var opts []string
+			for _, t := range editorStepTypes {
+				if t == m.NewStep.Type {
+					t = lipgloss.NewStyle().Reverse(true).Render(t)
+				}
+				opts = append(opts, t)
+			}
+			value = strings.Join(opts, " ")
+		} else if i == m.NewField {
+			value = "\n" + m.Input.View()
+		}
+		b.WriteString(editorLabelStyle.Render(string(field)) + value + "\n")
+	}
+	return b.String()
+}
+
+// duplicateStep appends a copy of the selected step as <id>_copy.
+func (m *FlowEditorModel) duplicateStep() {
+	if m.Cursor >= len(m.Config.Steps) {
+		return
+	}
+	dup := m.Config.Steps[m.Cursor]
+	dup.Tags = append([]string(nil), dup.Tags...)
+	dup.ID = m.uniqueID(dup.ID + "_copy")
+	m.Config.Steps = append(m.Config.Steps, dup)
+	m.record()
+	m.Status = fmt.Sprintf("%s Added %s", checkMark, dup.ID)
+}
+
+// uniqueID returns id, or id with a number appended if a step already uses it.
+func (m FlowEditorModel) uniqueID(id string) string {
+	candidate := id
+	for n := 2; m.hasStep(candidate); n++ {
+		candidate = fmt.Sprintf("%s%d", id, n)
+	}
+	return candidate
+}
+
+func (m FlowEditorModel) hasStep(id string) bool {
+	for _, s := range m.Config.Steps {
+		if s.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// referencedBy lists the steps whose tags refer to the step id, directly or
+// through one of its split_output keys.
+func (m FlowEditorModel) referencedBy(id string) []string {
+	owners := splitOwners(m.Config.Steps)
+	var ids []string
+	for _, s := range m.Config.Steps {
+		if s.ID == id {
+			continue
+		}
+		for _, dep := range flowDependencies(s, owners) {
+			if dep == id {
+				ids = append(ids, s.ID)
+				break
+			}
+		}
+	}
+	return ids
+}
+
+// deleteStep removes the selected step, keeping the cursor in the same place.
+func (m *FlowEditorModel) deleteStep() {
+	if m.Cursor >= len(m.Config.Steps) {
+		return
+	}
+	id := m.Config.Steps[m.Cursor].ID
+	m.Config.Steps = append(m.Config.Steps[:m.Cursor], m.Config.Steps[m.Cursor+1:]...)
+	m.Cursor = min(m.Cursor, max(len(m.Config.Steps)-1, 0))
+	m.record()
+	m.Status = fmt.Sprintf("%s Deleted %s", checkMark, id)
+}
+
+// startEdit loads the selected step's field into the textarea.
+func (m *FlowEditorModel) startEdit(field editorField) tea.Cmd {
+	if m.Cursor >= len(m.Config.Steps) {
+		return nil
+	}
+	s := m.Config.Steps[m.Cursor]
+	value := *stepField(&s, field)
+	height := 1
+	if field == fieldPrompt {
+		height = max(m.Height-10, 3)
+	}
+
+	m.Mode = editorEditing
+	m.Field = field
+	m.Status = ""
+	m.Input.SetWidth(m.detailWidth())
+	m.Input.SetHeight(height)
+	m.Input.SetValue(value)
+	return m.Input.Focus()
+}
+
+// applyEdit copies the textarea back into the step. Renaming a step also
+// updates the {{tags}} that refer to it.
+func (m *FlowEditorModel) applyEdit() {
+	m.Mode = editorBrowse
+	m.Input.Blur()
+	s := &m.Config.Steps[m.Cursor]
+	value := m.Input.Value()
+
+	switch m.Field {
+	case fieldPrompt:
+		if value == s.Prompt {
+			return
+		}
+		s.Prompt = value
+	case fieldModel, fieldFilename, fieldSourceA, fieldSourceB, fieldSource:
+		value = strings.TrimSpace(value)
+		field := stepField(s, m.Field)
+		if value == *field {
+			return
+		}
+		*field = value
+	case fieldID:
+		value = strings.TrimSpace(value)
+		if value == "" || value == s.ID {
+			return
+		}
+		for _, other := range m.Config.Steps {
+			if other.ID == value {
+				m.Status = fmt.Sprintf("%s A step with ID '%s' already exists", crossMark, value)
+				return
+			}
+		}
+		old := s.ID
+		s.ID = value
+		for i := range m.Config.Steps {
+			for _, field := range tagFields(&m.Config.Steps[i]) {
+				*field = renameTagRefs(*field, old, value)
+			}
+			for j, dep := range m.Config.Steps[i].DependsOn {
+				if dep == old {
+					m.Config.Steps[i].DependsOn[j] = value
+				}
+			}
+		}
+	}
+	m.record()
+}
+
+// diffRow is one line of the side-by-side save diff. Op is "+"/"-" for a
+// changed row and " " when both sides match.
+type diffRow struct {
+	Left, Right string
+	Op          string
+}
+
+// sideBySide pairs up the removed and added lines of a line diff so changes
+// line up next to each other.
+func sideBySide(before, after string) []diffRow {
+	var rows []diffRow
+	var removed, added []string
+	flush := func() {
+		for i := 0; i < max(len(removed), len(added)); i++ {
+			row := diffRow{Op: "-"}
+			if i < len(removed) {
+				row.Left = removed[i]
+			}
+			if i < len(added) {
+				row.Right = added[i]
+				if i >= len(removed) {
+					row.Op = "+"
+				}
+			}
+			rows = append(rows, row)
+		}
+		removed, added = nil, nil
+	}
+	for _, l := range diffLines(before, after) {
+		switch l.Op {
+		case "-":
+			removed = append(removed, l.Text)
+		case "+":
+			added = append(added, l.Text)
+		default:
+			flush()
+			rows = append(rows, diffRow{Left: l.Text, Right: l.Text, Op: " "})
+		}
+	}
+	flush()
+	return rows
+}
+
+// requestSave shows what will change in the flow file and waits for the
+// user to confirm. New files and unchanged flows are written directly.
+func (m *FlowEditorModel) requestSave() {
+	original, err := os.ReadFile(m.Path)
+	if err != nil {
+		m.save()
+		return
+	}
+	data, err := json.MarshalIndent(m.Config, "", "  ")
+	if err != nil {
+		m.Status = fmt.Sprintf("%s Save failed: %v", crossMark, err)
+		return
+	}
+	if string(original) == string(data)+"\n" {
+		m.save()
+		return
+	}
+	m.SaveDiff = sideBySide(string(original), string(data)+"\n")
+	m.DiffScroll = 0
+	m.Mode = editorConfirmSave
+	m.Status = ""
+}
+
+// viewSaveDiff renders the original file on the left and the new flow on
+// the right.
+func (m FlowEditorModel) viewSaveDiff() string {
+	colWidth := max((m.Width-3)/2, 10)
+	rows := max(m.Height-8, 3)
+	cell := func(text string, style *lipgloss.Style) string {
+		if r := []rune(text); len(r) > colWidth {
+			text = string(r[:colWidth-1]) + "…"
+		}
+		text = lipgloss.NewStyle().Width(colWidth).Render(text)
+		if style != nil {
+			text = style.Render(text)
+		}
+		return text
+	}
+
+	var b strings.Builder
+	b.WriteString(cell("Original", &titleStyle) + " │ " + cell("Modified", &titleStyle) + "\n")
+	end := min(m.DiffScroll+rows, len(m.SaveDiff))
+	for _, row := range m.SaveDiff[m.DiffScroll:end] {
+		var left, right string
+		switch row.Op {
+		case " ":
+			left, right = cell(row.Left, nil), cell(row.Right, nil)
+		case "+":
+			left, right = cell("", nil), cell(row.Right, &diffAddStyle)
+		default:
+			left, right = cell(row.Left, &diffRemoveStyle), cell(row.Right, &diffAddStyle)
+		}
+		b.WriteString(left + " │ " + right + "\n")
+	}
+	return b.String()
+}
+
+// save writes the flow back to the file it was loaded from.
+func (m *FlowEditorModel) save() {
+	data, err := json.MarshalIndent(m.Config, "", "  ")
+	if err == nil {
+		err = os.WriteFile(m.Path, append(data, '\n'), 0644)
+	}
+	if err != nil {
+		m.Status = fmt.Sprintf("%s Save failed: %v", crossMark, err)
+		return
+	}
+	m.Dirty = false
+	m.savedIdx = m.historyIdx
+	m.Status = fmt.Sprintf("%s Saved to %s", checkMark, m.Path)
+}
+
+func (m FlowEditorModel) detailWidth() int {
+	return max(m.Width-editorListWidth-8, 20)
+}
+
+func (m FlowEditorModel) View() string {
+	if m.Quitting {
+		return ""
+	}
+	if m.Mode == editorConfirmSave {
+		return "\n" + titleStyle.Render("Save changes to "+m.Path+"?") + "\n\n" + m.viewSaveDiff() + "\n" +
+			subtleStyle.Render("↑/↓ scroll • y write • n keep editing") + "\n"
+	}
+
+	title := "Edit: " + m.Path
+	if m.Dirty {
+		title += " *"
+	}
+	header := titleStyle.Render(title) + subtleStyle.Render(fmt.Sprintf(" | Model: %s", m.Config.Model))
+
+	var list strings.Builder
+	for i, s := range m.Config.Steps {
+		if !m.visible(s) {
+			continue
+		}
+		label := s.ID
+		if i == m.Cursor {
+			label = lipgloss.NewStyle().Reverse(true).Render(label)
+		}
+		list.WriteString(label + "\n")
+		if len(s.Tags) > 0 {
+			list.WriteString("  " + tagBadges(s.Tags) + "\n")
+		}
+	}
+	if len(m.TagFilter) > 0 {
+		header += subtleStyle.Render(" | Tags: " + strings.Join(slices.Sorted(maps.Keys(m.TagFilter)), ", "))
+	}
+
+	var detail string
+	if m.Mode == editorNewStep {
+		detail = m.viewNewStep()
+	} else if m.Mode == editorTagFilter {
+		detail = m.viewTagFilter()
+	} else if m.Mode == editorEditing {
+		detail = titleStyle.Render(fmt.Sprintf("Editing %s", m.Field)) + "\n" + m.Input.View()
+	} else if m.Cursor < len(m.Config.Steps) {
+		s := m.Config.Steps[m.Cursor]
+		model := s.Model
+		if model == "" {
+			model = subtleStyle.Render("(flow default)")
+		}
+		detail = editorLabelStyle.Render("ID") + s.ID + "\n"
+		if s.Comment != "" {
+			detail += subtleStyle.Render(s.Comment) + "\n"
+		}
+		if s.Type != "" {
+			detail += editorLabelStyle.Render("Type") + s.Type + "\n"
+		}
+		detail += editorLabelStyle.Render("Model") + model + "\n"
+		if s.TabID != "" {
+			detail += editorLabelStyle.Render("Tab") + s.TabID + "\n"
+		}
+		if len(s.Tags) > 0 {
+			detail += editorLabelStyle.Render("Tags") + tagBadges(s.Tags) + "\n"
+		}
+		if fields := contentFields(s.Type); fields[0] == fieldPrompt {
+			detail += "\n" + lipgloss.NewStyle().Width(m.detailWidth()).Render(s.Prompt)
+		} else {
+			for _, field := range fields {
+				detail += "\n" + editorLabelStyle.Render(string(field)) + *stepField(&s, field)
+			}
+		}
+		if s.Prepend != "" {
+			detail += "\n\n" + editorLabelStyle.Render("Prepend") + strconv.Quote(s.Prepend)
+		}
+		if s.Append != "" {
+			detail += "\n" + editorLabelStyle.Render("Append") + strconv.Quote(s.Append)
+		}
+	}
+
+	body := lipgloss.JoinHorizontal(lipgloss.Top,
+		editorListStyle.Width(editorListWidth).Render(strings.TrimSuffix(list.String(), "\n")),
+		editorDetailStyle.Width(m.detailWidth()+2).Render(detail),
+	)
+
+	footer := subtleStyle.Render("↑/↓ select • ctrl+↑/↓ move • enter edit prompt/source • m model • r rename • t filter tags • n new • d duplicate • del delete • ctrl+z/y undo/redo • ctrl+s save • ctrl+q quit")
+	if undo, redo := m.historyIdx, len(m.history)-1-m.historyIdx; undo > 0 || redo > 0 {
+		footer = subtleStyle.Render(fmt.Sprintf("undo %d • redo %d", undo, redo)) + "\n" + footer
+	}
+	switch m.Mode {
+	case editorEditing:
+		footer = subtleStyle.Render("esc done • ctrl+s save")
+		fields := contentFields(m.Config.Steps[m.Cursor].Type)
+		if i := slices.Index(fields, m.Field); i >= 0 && i < len(fields)-1 {
+			footer = subtleStyle.Render("tab next field • esc done • ctrl+s save")
+		}
+	case editorTagFilter:
+		footer = subtleStyle.Render("↑/↓ select • space toggle • c clear • esc done")
+	case editorNewStep:
+		footer = subtleStyle.Render("tab next • shift+tab back • ←/→ pick type • esc cancel")
+	case editorConfirmQuit:
+		footer = fmt.Sprintf("%s %s", crossMark, "Discard unsaved changes? [y/N]")
+	case editorConfirmDelete:
+		id := m.Config.Steps[m.Cursor].ID
+		footer = fmt.Sprintf("%s Delete step '%s'? [y/N]", crossMark, id)
+		if refs := m.referencedBy(id); len(refs) > 0 {
+			footer = fmt.Sprintf("%s {{%s}} is used by %s\n%s", crossMark, id, strings.Join(refs, ", "), footer)
+		}
+	}
+	if m.Status != "" {
+		footer = m.Status + "\n" + footer
+	}
+
+	return "\n" + header + "\n\n" + body + "\n\n" + footer + "\n"
+}
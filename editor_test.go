@@ -0,0 +1,433 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func editorKey(m FlowEditorModel, keys ...tea.KeyMsg) FlowEditorModel {
+	for _, k := range keys {
+		updated, _ := m.Update(k)
+		m = updated.(FlowEditorModel)
+	}
+	return m
+}
+
+func TestFlowEditorRenameAndSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flow.json")
+	conf := Config{
+		Model: "test-model",
+		Steps: []Step{
+			{ID: "step1", Prompt: "Hello"},
+			{ID: "step2", Prompt: "Previous was {{step1}}"},
+		},
+	}
+	m := NewFlowEditorModel(conf, path)
+
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	if m.Mode != editorEditing || m.Field != fieldID {
+		t.Fatalf("Expected to edit the step ID, got mode %v field %q", m.Mode, m.Field)
+	}
+	m.Input.SetValue("first")
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyEsc})
+
+	if m.Config.Steps[0].ID != "first" || m.Config.Steps[1].Prompt != "Previous was {{first}}" {
+		t.Errorf("Expected rename to update references, got %+v", m.Config.Steps)
+	}
+	if !m.Dirty {
+		t.Error("Expected editor to be dirty after a change")
+	}
+
+	// Quitting with unsaved changes asks first
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyCtrlQ})
+	if m.Mode != editorConfirmQuit || m.Quitting {
+		t.Error("Expected a confirmation before discarding changes")
+	}
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyEsc})
+
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyCtrlS})
+	if m.Dirty {
+		t.Errorf("Expected save to clear dirty flag, status %q", m.Status)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved Config
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved.Steps[0].ID != "first" || saved.Model != "test-model" {
+		t.Errorf("Unexpected saved flow %+v", saved)
+	}
+}
+
+func TestFlowEditorRenameAllTagFields(t *testing.T) {
+	conf := Config{Steps: []Step{
+		{ID: "step1", Prompt: "Hello"},
+		{ID: "step2", Prompt: "{{step1}}", FallbackPrompt: "{{step1}}?", SaveTo: "~/{{step1}}.md", Prepend: "# {{step1}}", Append: "{{len:step1}}", ImageFile: "{{step1}}.png"},
+		{ID: "diff", Type: "json_diff", SourceA: "{{step1}}", SourceB: "{{json:step1.items}}"},
+		{ID: "stats", Type: "metrics", Source: "{{step1}}"},
+		{ID: "doc", Type: "document", Filename: "{{step1}}.pdf"},
+	}}
+	m := NewFlowEditorModel(conf, "flow.json")
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	m.Input.SetValue("first")
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyEsc})
+
+	for _, s := range m.Config.Steps[1:] {
+		for _, field := range tagFields(&s) {
+			if strings.Contains(*field, "step1") {
+				t.Errorf("Expected step '%s' to refer to first, got %q", s.ID, *field)
+			}
+		}
+	}
+	if got := m.Config.Steps[2].SourceB; got != "{{json:first.items}}" {
+		t.Errorf("Expected the json tag to be renamed, got %q", got)
+	}
+}
+
+func TestFlowEditorUndoRename(t *testing.T) {
+	conf := Config{Steps: []Step{
+		{ID: "a", Prompt: "A"},
+		{ID: "b", Prompt: "B", DependsOn: []string{"a"}},
+	}}
+	m := NewFlowEditorModel(conf, "flow.json")
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	m.Input.SetValue("first")
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyEsc})
+	if got := m.Config.Steps[1].DependsOn; !slices.Equal(got, []string{"first"}) {
+		t.Fatalf("Expected depends_on to follow the rename, got %v", got)
+	}
+
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyCtrlZ})
+	if m.Config.Steps[0].ID != "a" {
+		t.Fatalf("Expected undo to restore the ID, got %q", m.Config.Steps[0].ID)
+	}
+	if got := m.Config.Steps[1].DependsOn; !slices.Equal(got, []string{"a"}) {
+		t.Errorf("Expected undo to restore depends_on, got %v", got)
+	}
+	if err := validateDependsOn(m.Config.Steps); err != nil {
+		t.Errorf("Expected a valid flow after undo, got %v", err)
+	}
+}
+
+func TestCloneConfigCopiesNestedValues(t *testing.T) {
+	conf := Config{Steps: []Step{{
+		ID:          "s",
+		RetryOn:     []string{"429"},
+		SplitOutput: map[string]string{"title": "(.+)"},
+		ModelConfig: map[string]interface{}{"stop_sequences": []interface{}{"END"}},
+	}}}
+	clone := cloneConfig(conf)
+	clone.Steps[0].RetryOn[0] = "500"
+	clone.Steps[0].SplitOutput["title"] = "x"
+	clone.Steps[0].ModelConfig["stop_sequences"].([]interface{})[0] = "STOP"
+
+	s := conf.Steps[0]
+	if s.RetryOn[0] != "429" || s.SplitOutput["title"] != "(.+)" || s.ModelConfig["stop_sequences"].([]interface{})[0] != "END" {
+		t.Errorf("Expected the original to be unchanged, got %+v", s)
+	}
+}
+
+func TestFlowEditorTagFilter(t *testing.T) {
+	conf := Config{Steps: []Step{
+		{ID: "a", Tags: []string{"draft"}},
+		{ID: "b", Tags: []string{"review"}},
+		{ID: "c", Tags: []string{"draft", "final"}},
+	}}
+	m := NewFlowEditorModel(conf, "flow.json")
+	if got := m.allTags(); !slices.Equal(got, []string{"draft", "final", "review"}) {
+		t.Fatalf("Expected the unique tags, got %v", got)
+	}
+
+	// Tick "draft", the first tag in the checklist
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("t")})
+	if m.Mode != editorTagFilter {
+		t.Fatalf("Expected the tag filter, got mode %v", m.Mode)
+	}
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeySpace}, tea.KeyMsg{Type: tea.KeyEsc})
+	if !m.TagFilter["draft"] || m.Mode != editorBrowse {
+		t.Fatalf("Expected draft to be selected, got %v", m.TagFilter)
+	}
+	view := m.View()
+	if !strings.Contains(view, "#draft") || !strings.Contains(view, "#final") {
+		t.Errorf("Expected tag badges in the list, got:\n%s", view)
+	}
+
+	// b is hidden, so down skips it
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyDown})
+	if m.Cursor != 2 {
+		t.Errorf("Expected the cursor to skip hidden steps, got %d", m.Cursor)
+	}
+
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("t")}, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("c")})
+	if len(m.TagFilter) != 0 {
+		t.Errorf("Expected c to clear the filter, got %v", m.TagFilter)
+	}
+}
+
+func TestFlowEditorMoveStep(t *testing.T) {
+	conf := Config{Steps: []Step{
+		{ID: "a", Prompt: "A"},
+		{ID: "b", Prompt: "B {{a}}"},
+		{ID: "c", Prompt: "C"},
+	}}
+	m := NewFlowEditorModel(conf, "flow.json")
+
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyCtrlDown}, tea.KeyMsg{Type: tea.KeyCtrlDown})
+	if got := []string{m.Config.Steps[0].ID, m.Config.Steps[1].ID, m.Config.Steps[2].ID}; got[0] != "b" || got[1] != "c" || got[2] != "a" {
+		t.Errorf("Expected order b c a, got %v", got)
+	}
+	if m.Cursor != 2 || !m.Dirty {
+		t.Errorf("Expected cursor to follow the moved step, got %d (dirty %v)", m.Cursor, m.Dirty)
+	}
+	if m.Status != "" {
+		t.Errorf("Expected no warning, got %q", m.Status)
+	}
+
+	// Moving past the end is a no-op
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyCtrlDown})
+	if m.Cursor != 2 || m.Config.Steps[2].ID != "a" {
+		t.Errorf("Expected step to stay last, got cursor %d", m.Cursor)
+	}
+
+	m.Config.Steps[2].Prompt = "A {{b}}"
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyCtrlUp})
+	if !strings.Contains(m.Status, "Dependency cycle") {
+		t.Errorf("Expected a cycle warning, got %q", m.Status)
+	}
+}
+
+func TestFlowEditorDuplicateAndDelete(t *testing.T) {
+	conf := Config{Steps: []Step{
+		{ID: "a", Prompt: "A"},
+		{ID: "b", Prompt: "B {{a}}"},
+	}}
+	m := NewFlowEditorModel(conf, "flow.json")
+
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")}, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")})
+	if len(m.Config.Steps) != 4 || m.Config.Steps[2].ID != "a_copy" || m.Config.Steps[3].ID != "a_copy2" {
+		t.Fatalf("Expected two copies of a, got %+v", m.Config.Steps)
+	}
+	if m.Cursor != 0 {
+		t.Errorf("Expected cursor to stay on a, got %d", m.Cursor)
+	}
+
+	// Deleting a referenced step warns before asking
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyDelete})
+	if m.Mode != editorConfirmDelete || !strings.Contains(m.View(), "{{a}} is used by b") {
+		t.Errorf("Expected a reference warning, got:\n%s", m.View())
+	}
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	if len(m.Config.Steps) != 4 {
+		t.Fatal("Expected delete to be cancelled")
+	}
+
+	m.Cursor = 3
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyBackspace}, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	if len(m.Config.Steps) != 3 || m.Cursor != 2 || m.Mode != editorBrowse {
+		t.Errorf("Expected last step deleted and cursor clamped, got %d steps, cursor %d", len(m.Config.Steps), m.Cursor)
+	}
+}
+
+func TestFlowEditorReferencedBySplitKey(t *testing.T) {
+	conf := Config{Steps: []Step{
+		{ID: "draft", Prompt: "Draft", SplitOutput: map[string]string{"title": "(?m)^Title: (.+)$"}},
+		{ID: "post", Prompt: "Publish {{title}}"},
+	}}
+	m := NewFlowEditorModel(conf, "flow.json")
+	if refs := m.referencedBy("draft"); len(refs) != 1 || refs[0] != "post" {
+		t.Errorf("Expected post to reference draft through {{title}}, got %v", refs)
+	}
+}
+
+func TestFlowEditorNewStep(t *testing.T) {
+	conf := Config{Model: "flow-model", Steps: []Step{{ID: "a", Prompt: "A"}}}
+	m := NewFlowEditorModel(conf, "flow.json")
+	tab := tea.KeyMsg{Type: tea.KeyTab}
+
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	if m.Mode != editorNewStep || m.NewStep.Model != "flow-model" {
+		t.Fatalf("Expected the form with the flow model, got mode %v model %q", m.Mode, m.NewStep.Model)
+	}
+
+	// The ID must be unique and contain no spaces
+	for _, bad := range []string{"a", "my step"} {
+		m.Input.SetValue(bad)
+		m = editorKey(m, tab)
+		if m.NewField != 0 || m.Status == "" {
+			t.Errorf("Expected ID %q to be rejected", bad)
+		}
+	}
+	m.Input.SetValue("b")
+	m = editorKey(m, tab)
+
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyRight}, tab)
+	if m.NewStep.Type != "document" {
+		t.Errorf("Expected type document, got %q", m.NewStep.Type)
+	}
+	m.Input.SetValue("other-model")
+	m = editorKey(m, tab)
+	m.Input.SetValue("report.pdf")
+	m = editorKey(m, tab)
+
+	if m.Mode != editorBrowse || len(m.Config.Steps) != 2 || m.Cursor != 1 {
+		t.Fatalf("Expected the step to be added and selected, got %+v", m.Config.Steps)
+	}
+	want := Step{ID: "b", Type: "document", Model: "other-model", Filename: "report.pdf"}
+	if got := m.Config.Steps[1]; got.ID != want.ID || got.Type != want.Type || got.Model != want.Model || got.Filename != want.Filename {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+}
+
+func TestFlowEditorNewStepTypes(t *testing.T) {
+	tab := tea.KeyMsg{Type: tea.KeyTab}
+	right := tea.KeyMsg{Type: tea.KeyRight}
+	m := NewFlowEditorModel(Config{Model: "flow-model", Steps: []Step{{ID: "a", Prompt: "A"}}}, "flow.json")
+
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	m.Input.SetValue("diff")
+	m = editorKey(m, tab, right, right, tab)
+	if m.NewStep.Type != "json_diff" {
+		t.Fatalf("Expected type json_diff, got %q", m.NewStep.Type)
+	}
+	m.Input.SetValue("{{a}}")
+	m = editorKey(m, tab)
+	m.Input.SetValue("{{b}}")
+	m = editorKey(m, tab)
+	got := m.Config.Steps[1]
+	if got.Type != "json_diff" || got.SourceA != "{{a}}" || got.SourceB != "{{b}}" || got.Model != "" || got.Prompt != "" {
+		t.Errorf("Expected a json_diff step, got %+v", got)
+	}
+
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	m.Input.SetValue("stats")
+	m = editorKey(m, tab, right, right, right, tab)
+	m.Input.SetValue("{{a}}")
+	m = editorKey(m, tab)
+	if got := m.Config.Steps[2]; got.Type != "metrics" || got.Source != "{{a}}" {
+		t.Errorf("Expected a metrics step, got %+v", got)
+	}
+}
+
+func TestFlowEditorEditSources(t *testing.T) {
+	conf := Config{Steps: []Step{{ID: "diff", Type: "json_diff", SourceA: "{{a}}", SourceB: "{{b}}"}}}
+	m := NewFlowEditorModel(conf, "flow.json")
+
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyEnter})
+	if m.Field != fieldSourceA || m.Input.Value() != "{{a}}" {
+		t.Fatalf("Expected to edit source_a, got %q = %q", m.Field, m.Input.Value())
+	}
+	m.Input.SetValue("{{before}}")
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyTab})
+	if m.Field != fieldSourceB {
+		t.Fatalf("Expected tab to move to source_b, got %q", m.Field)
+	}
+	m.Input.SetValue("{{after}}")
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyEsc})
+
+	s := m.Config.Steps[0]
+	if s.Type != "json_diff" || s.SourceA != "{{before}}" || s.SourceB != "{{after}}" {
+		t.Errorf("Expected both sources updated, got %+v", s)
+	}
+	if view := m.View(); !strings.Contains(view, "json_diff") || !strings.Contains(view, "{{after}}") {
+		t.Errorf("Expected the type and sources in the detail pane, got:\n%s", view)
+	}
+}
+
+func TestFlowEditorUndoRedo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flow.json")
+	conf := Config{Steps: []Step{{ID: "a", Prompt: "A"}, {ID: "b", Prompt: "B"}}}
+	m := NewFlowEditorModel(conf, path)
+	undo := tea.KeyMsg{Type: tea.KeyCtrlZ}
+	redo := tea.KeyMsg{Type: tea.KeyCtrlY}
+
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyCtrlDown}, tea.KeyMsg{Type: tea.KeyDelete}, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	if len(m.Config.Steps) != 1 || m.Config.Steps[0].ID != "b" {
+		t.Fatalf("Expected only b left, got %+v", m.Config.Steps)
+	}
+	if !strings.Contains(m.View(), "undo 2 • redo 0") {
+		t.Errorf("Expected the history depth in the view, got:\n%s", m.View())
+	}
+
+	m = editorKey(m, undo)
+	if len(m.Config.Steps) != 2 || m.Config.Steps[1].ID != "a" {
+		t.Errorf("Expected the delete to be undone, got %+v", m.Config.Steps)
+	}
+	m = editorKey(m, undo, undo)
+	if m.Config.Steps[0].ID != "a" || m.Dirty {
+		t.Errorf("Expected the original order and a clean editor, got %+v (dirty %v)", m.Config.Steps, m.Dirty)
+	}
+	m = editorKey(m, redo)
+	if m.Config.Steps[0].ID != "b" || !m.Dirty {
+		t.Errorf("Expected the move to be redone, got %+v", m.Config.Steps)
+	}
+
+	// A new change drops the redo states
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")})
+	m = editorKey(m, redo)
+	if len(m.Config.Steps) != 3 || len(m.history)-1 != m.historyIdx {
+		t.Errorf("Expected no redo after a new change, got %+v", m.Config.Steps)
+	}
+
+	for range maxEditorHistory + 10 {
+		m = editorKey(m, tea.KeyMsg{Type: tea.KeyCtrlUp}, tea.KeyMsg{Type: tea.KeyCtrlDown})
+	}
+	if len(m.history) != maxEditorHistory {
+		t.Errorf("Expected history capped at %d, got %d", maxEditorHistory, len(m.history))
+	}
+}
+
+func TestFlowEditorSaveDiff(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "flow.json")
+	original := "{\n  \"model\": \"m\",\n  \"steps\": [\n    {\n      \"id\": \"a\",\n      \"prompt\": \"old\"\n    }\n  ]\n}\n"
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+	m := NewFlowEditorModel(Config{Model: "m", Steps: []Step{{ID: "a", Prompt: "new"}}}, path)
+
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyCtrlS})
+	if m.Mode != editorConfirmSave {
+		t.Fatalf("Expected a diff before saving, got mode %v (%s)", m.Mode, m.Status)
+	}
+	var changed []diffRow
+	for _, row := range m.SaveDiff {
+		if row.Op != " " {
+			changed = append(changed, row)
+		}
+	}
+	if len(changed) != 1 || !strings.Contains(changed[0].Left, `"old"`) || !strings.Contains(changed[0].Right, `"new"`) {
+		t.Errorf("Expected the prompt line side by side, got %+v", changed)
+	}
+
+	// n keeps editing without writing
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	if data, _ := os.ReadFile(path); string(data) != original || m.Mode != editorBrowse {
+		t.Fatal("Expected the file to be left alone")
+	}
+
+	m = editorKey(m, tea.KeyMsg{Type: tea.KeyCtrlS}, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")})
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), `"new"`) {
+		t.Errorf("Expected the flow to be written, got %s", data)
+	}
+}
+
+func TestSideBySide(t *testing.T) {
+	rows := sideBySide("a\nb\nc\n", "a\nB\nc\nd\n")
+	want := []diffRow{{"a", "a", " "}, {"b", "B", "-"}, {"c", "c", " "}, {"", "d", "+"}}
+	if len(rows) != len(want) {
+		t.Fatalf("Expected %v, got %v", want, rows)
+	}
+	for i := range want {
+		if rows[i] != want[i] {
+			t.Errorf("Row %d: expected %v, got %v", i, want[i], rows[i])
+		}
+	}
+}
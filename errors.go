@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// errNoResult is the cause when the model call produced nothing.
+var errNoResult = errors.New("the model returned no result")
+
+// errFlowTimeout is returned when --timeout expires; fast exits with code 2.
+var errFlowTimeout = errors.New("flow timeout")
+
+// exitCode maps a flow error to the process exit code.
+func exitCode(err error) int {
+	if errors.Is(err, errFlowTimeout) {
+		return 2
+	}
+	return 1
+}
+
+// StepError describes why a step failed.
+type StepError struct {
+	StepID   string
+	StepType string
+	Cause    error
+	Attempt  int
+	Duration time.Duration
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("step '%s' failed: %v", e.StepID, e.Cause)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Cause
+}
+
+// apiError is a Gemini request that failed with an HTTP error. Its message
+// carries the status and the API's own message, so retry_on can match e.g.
+// "429", "rate limit" or "503".
+type apiError struct {
+	StatusCode int
+	Status     string // e.g. "429 Too Many Requests"
+	Message    string
+}
+
+func (e *apiError) Error() string {
+	msg := fmt.Sprintf("API error (%s): %s", e.Status, e.Message)
+	if e.StatusCode == http.StatusTooManyRequests {
+		msg += " (rate limit)"
+	}
+	return msg
+}
+
+// newAPIError reads the message of an error response; Gemini sends
+// {"error": {"message": ...}}, anything else is kept as it came.
+func newAPIError(resp *http.Response, body []byte) *apiError {
+	var res struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	msg := strings.TrimSpace(string(body))
+	if err := json.Unmarshal(body, &res); err == nil && res.Error.Message != "" {
+		msg = res.Error.Message
+	}
+	return &apiError{StatusCode: resp.StatusCode, Status: resp.Status, Message: msg}
+}
+
+// stepType returns the step's type, with the implicit "text" made explicit.
+func stepType(s Step) string {
+	if s.Type == "" {
+		return "text"
+	}
+	return s.Type
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var tagPattern = regexp.MustCompile(`{{(.*?)}}`)
+
+// estimateTokens guesses the number of tokens in text from its word count;
+// a token is about three quarters of an English word.
+func estimateTokens(text string) int {
+	words := len(strings.Fields(text))
+	return (words*4 + 1) / 3
+}
+
+// explainText fills the tags of text the way step s would see them, except
+// that tags for steps missing from known become [pending: id] markers.
+func explainText(run *FlowRun, known map[string]string, s Step, model, text string) string {
+	text = tagPattern.ReplaceAllStringFunc(text, func(tag string) string {
+		name := tag[2 : len(tag)-2]
+		if isInputTag(name) {
+			return tag
+		}
+		id := tagStep(name)
+		if _, ok := known[id]; ok {
+			return tag
+		}
+		return "[pending: " + id + "]"
+	})
+	return run.fillStepTags(text, s, model)
+}
+
+// explainFlow describes what every step of a flow will do, in file order,
+// without calling the AI.
+func explainFlow(run *FlowRun, conf Config, flowName string) string {
+	known := run.Results()
+	owners := splitOwners(conf.Steps)
+	var b strings.Builder
+	b.WriteString(titleStyle.Render(fmt.Sprintf("Flow %s: %d steps", flowName, len(conf.Steps))) + "\n")
+	if conf.SystemPrompt != "" {
+		b.WriteString(subtleStyle.Render("System prompt: "+conf.SystemPrompt) + "\n")
+	}
+
+	for i, s := range conf.Steps {
+		model := effectiveModel(conf, s)
+		stepType := s.Type
+		if stepType == "" {
+			stepType = "text"
+		}
+		b.WriteString("\n" + titleStyle.Render(fmt.Sprintf("%d. %s", i+1, s.ID)))
+		b.WriteString(subtleStyle.Render(" (" + stepType + ")"))
+		b.WriteString("\n")
+		if s.Comment != "" {
+			b.WriteString("   " + subtleStyle.Render(s.Comment) + "\n")
+		}
+		var deps []string
+		for _, dep := range flowDependencies(s, owners) {
+			// {{draft}} and a split_output key of draft wait for the same step
+			if !slices.Contains(deps, dep) {
+				deps = append(deps, dep)
+			}
+		}
+		if len(deps) > 0 {
+			b.WriteString("   Waits for: " + strings.Join(deps, ", ") + "\n")
+		}
+
+		if _, ok := known[s.ID]; ok {
+			b.WriteString("   Skipped, its result is given with --set\n")
+			continue
+		}
+		if _, ok := run.mockResult(s.ID); ok {
+			b.WriteString("   Skipped, its result is given with --mock-step\n")
+			continue
+		}
+
+		block := func(label, text string) {
+			b.WriteString("   " + label + ":\n")
+			for _, line := range strings.Split(text, "\n") {
+				b.WriteString("     " + line + "\n")
+			}
+		}
+		switch s.Type {
+		case "document":
+			block("Reads", explainText(run, known, s, model, s.Filename))
+		case "json_diff":
+			block("Compares", explainText(run, known, s, model, s.SourceA))
+			block("With", explainText(run, known, s, model, s.SourceB))
+		case "metrics":
+			block("Measures", explainText(run, known, s, model, s.Source))
+		default:
+			prompt := explainText(run, known, s, model, s.Prompt)
+			tokens := estimateTokens(systemPrompt(conf, s) + " " + prompt)
+			b.WriteString(fmt.Sprintf("   Model: %s, about %d input tokens\n", model, tokens))
+			if s.ChunkSize > 0 {
+				b.WriteString(fmt.Sprintf("   Sent in chunks of %d characters\n", s.ChunkSize))
+			}
+			block("Prompt", prompt)
+			if s.FallbackPrompt != "" {
+				block("Fallback prompt", explainText(run, known, s, model, s.FallbackPrompt))
+			}
+		}
+		if s.PostProcess != "" {
+			b.WriteString("   Piped through: " + s.PostProcess + "\n")
+		}
+		if s.SaveTo != "" {
+			b.WriteString("   Saved to: " + explainText(run, known, s, model, s.SaveTo) + "\n")
+		}
+	}
+	b.WriteString("\n" + subtleStyle.Render("No AI calls were made.") + "\n")
+	return b.String()
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	cases := map[string]int{
+		"":                   0,
+		"one":                1,
+		"one two three":      4,
+		"a b c d e f":        8,
+		"  spaced \n  out  ": 3,
+	}
+	for text, want := range cases {
+		if got := estimateTokens(text); got != want {
+			t.Errorf("estimateTokens(%q) = %d, want %d", text, got, want)
+		}
+	}
+}
+
+func TestExplainFlow(t *testing.T) {
+	conf := Config{
+		Model: "gemini-2.5-flash",
+		Steps: []Step{
+			{ID: "notes", Prompt: "Clean up {{input}}"},
+			{ID: "draft", Model: "gemini-2.5-pro", Prompt: "Draft a reply to {{notes}} for {{flow_name}}\nSign it."},
+			{ID: "stats", Type: "metrics", Source: "{{draft}}"},
+			{ID: "given", Prompt: "Never shown"},
+		},
+	}
+	run := newFlowRun("the meeting")
+	run.flowName = "reply"
+	run.pin(map[string]string{"given": "pinned"})
+
+	out := explainFlow(run, conf, "reply")
+	for _, want := range []string{
+		"Flow reply: 4 steps",
+		"1. notes (text)",
+		"Model: gemini-2.5-flash, about 5 input tokens",
+		"Clean up the meeting",
+		"Waits for: notes",
+		"Model: gemini-2.5-pro",
+		"Draft a reply to [pending: notes] for reply\n     Sign it.",
+		"3. stats (metrics)",
+		"Measures:\n     [pending: draft]",
+		"Skipped, its result is given with --set",
+		"No AI calls were made.",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected explanation to contain %q, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "Never shown") {
+		t.Errorf("Expected the pinned step's prompt to be skipped, got:\n%s", out)
+	}
+	if len(run.Results()) != 1 {
+		t.Errorf("Expected explain not to store results, got %v", run.Results())
+	}
+}
+
+func TestExplainFlowSplitOutput(t *testing.T) {
+	conf := Config{Steps: []Step{
+		{ID: "draft", Prompt: "Write", SplitOutput: map[string]string{"title": "(?m)^Title: (.+)$"}},
+		{ID: "post", Prompt: "Publish {{title}} from {{draft}}"},
+	}}
+	out := explainFlow(newFlowRun(""), conf, "blog")
+	if !strings.Contains(out, "Waits for: draft\n") || strings.Contains(out, "Waits for: title") {
+		t.Errorf("Expected post to wait for draft once, got:\n%s", out)
+	}
+}
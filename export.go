@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// exportExtensions maps each --export format to its file extension.
+var exportExtensions = map[string]string{
+	"markdown": "md",
+	"html":     "html",
+	"pdf":      "pdf",
+}
+
+// exportName returns the file a --export run writes to, e.g.
+// scope_report_20240102-150405.md in the current directory.
+func exportName(flowName, format string, now time.Time) string {
+	return fmt.Sprintf("%s_report_%s.%s", flowName, now.Format("20060102-150405"), exportExtensions[format])
+}
+
+// reportStep is one step of a run as shown in a report.
+type reportStep struct {
+	ID       string
+	Type     string
+	Model    string
+	Prompt   string
+	Result   string
+	Ran      bool
+	Duration time.Duration
+	Tokens   int
+	Retries  int
+	Warnings []string
+}
+
+// report is a finished run, ready to be rendered as Markdown or HTML.
+type report struct {
+	FlowName  string
+	Timestamp time.Time
+	Model     string
+	Input     string
+	Steps     []reportStep
+	Duration  time.Duration
+	Tokens    int
+}
+
+func newReport(log SessionLog) report {
+	r := report{
+		FlowName:  log.FlowName,
+		Timestamp: log.Timestamp,
+		Model:     log.Config.Model,
+		Input:     log.Input,
+	}
+	stepLogs := make(map[string]StepLog, len(log.Steps))
+	var start, end time.Time
+	for _, l := range log.Steps {
+		stepLogs[l.ID] = l
+		if start.IsZero() || l.StartTime.Before(start) {
+			start = l.StartTime
+		}
+		if l.EndTime.After(end) {
+			end = l.EndTime
+		}
+		r.Tokens += l.TokensUsed
+	}
+	r.Duration = end.Sub(start)
+
+	for _, s := range log.Config.Steps {
+		rs := reportStep{
+			ID:     s.ID,
+			Type:   s.Type,
+			Model:  effectiveModel(log.Config, s),
+			Prompt: s.Prompt,
+		}
+		if rs.Type == "" {
+			rs.Type = "text"
+		}
+		if res, ok := log.Results[s.ID]; ok {
+			rs.Result = res
+			rs.Ran = true
+		}
+		if l, ok := stepLogs[s.ID]; ok {
+			rs.Duration = l.Duration.Round(time.Millisecond)
+			rs.Tokens = l.TokensUsed
+			rs.Retries = l.Retries
+			rs.Warnings = l.Warnings
+		}
+		r.Steps = append(r.Steps, rs)
+	}
+	return r
+}
+
+// codeFence returns a fence longer than any run of backticks in text, so the
+// text can't close its own code block.
+func codeFence(text string) string {
+	fence := "```"
+	for strings.Contains(text, fence) {
+		fence += "`"
+	}
+	return fence
+}
+
+// renderMarkdownReport renders a run as a Markdown document.
+func renderMarkdownReport(r report) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", r.FlowName)
+	fmt.Fprintf(&b, "- **Run at:** %s\n", r.Timestamp.Format("2006-01-02 15:04:05"))
+	if r.Model != "" {
+		fmt.Fprintf(&b, "- **Model:** %s\n", r.Model)
+	}
+	fmt.Fprintf(&b, "- **Duration:** %s\n", r.Duration.Round(time.Millisecond))
+	fmt.Fprintf(&b, "- **Tokens:** %d\n", r.Tokens)
+	if r.Input != "" {
+		fmt.Fprintf(&b, "- **Input:** %s\n", strings.ReplaceAll(r.Input, "\n", " "))
+	}
+
+	for _, s := range r.Steps {
+		fmt.Fprintf(&b, "\n## %s\n\n", s.ID)
+		fmt.Fprintf(&b, "_%s step", s.Type)
+		if s.Type == "text" {
+			fmt.Fprintf(&b, ", %s", s.Model)
+		}
+		b.WriteString("_\n")
+		if s.Prompt != "" {
+			fence := codeFence(s.Prompt)
+			fmt.Fprintf(&b, "\n### Prompt\n\n%s\n%s\n%s\n", fence, s.Prompt, fence)
+		}
+		b.WriteString("\n### Result\n\n")
+		if s.Ran {
+			b.WriteString(s.Result + "\n")
+		} else {
+			b.WriteString("_Did not finish._\n")
+		}
+		for _, w := range s.Warnings {
+			fmt.Fprintf(&b, "\n> ⚠️ %s\n", w)
+		}
+	}
+
+	b.WriteString("\n## Timing\n\n| Step | Duration | Tokens | Retries |\n| --- | --- | --- | --- |\n")
+	for _, s := range r.Steps {
+		fmt.Fprintf(&b, "| %s | %s | %d | %d |\n", s.ID, s.Duration, s.Tokens, s.Retries)
+	}
+	return b.String()
+}
+
+var htmlReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.FlowName}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; max-width: 50em; margin: 2em auto; padding: 0 1em; color: #222; line-height: 1.5; }
+h1 { border-bottom: 2px solid #ddd; padding-bottom: .3em; }
+h2 { margin-top: 2em; border-bottom: 1px solid #eee; }
+.meta { color: #666; }
+pre { background: #f6f8fa; padding: 1em; overflow-x: auto; white-space: pre-wrap; }
+.result { white-space: pre-wrap; }
+.warning { color: #9a6700; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ddd; padding: .3em .8em; text-align: left; }
+</style>
+</head>
+<body>
+<h1>{{.FlowName}}</h1>
+<p class="meta">Run at {{.Timestamp.Format "2006-01-02 15:04:05"}}{{if .Model}} · {{.Model}}{{end}} · {{.Duration}} · {{.Tokens}} tokens</p>
+{{if .Input}}<p><strong>Input:</strong> {{.Input}}</p>{{end}}
+{{range .Steps}}
+<h2>{{.ID}}</h2>
+<p class="meta">{{.Type}} step{{if eq .Type "text"}}, {{.Model}}{{end}}</p>
+{{if .Prompt}}<h3>Prompt</h3>
+<pre>{{.Prompt}}</pre>{{end}}
+<h3>Result</h3>
+{{if .Ran}}<div class="result">{{.Result}}</div>{{else}}<p><em>Did not finish.</em></p>{{end}}
+{{range .Warnings}}<p class="warning">⚠️ {{.}}</p>{{end}}
+{{end}}
+<h2>Timing</h2>
+<table>
+<tr><th>Step</th><th>Duration</th><th>Tokens</th><th>Retries</th></tr>
+{{range .Steps}}<tr><td>{{.ID}}</td><td>{{.Duration}}</td><td>{{.Tokens}}</td><td>{{.Retries}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// renderHTMLReport renders a run as a self-contained HTML page.
+func renderHTMLReport(r report) (string, error) {
+	var b bytes.Buffer
+	if err := htmlReportTemplate.Execute(&b, r); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// exportRun implements --export: it writes a report of the run in format to
+// the current directory and returns its path.
+func exportRun(log SessionLog, format string) (string, error) {
+	r := newReport(log)
+	path := exportName(log.FlowName, format, log.Timestamp)
+	switch format {
+	case "markdown":
+		return path, os.WriteFile(path, []byte(renderMarkdownReport(r)), 0644)
+	case "html":
+		page, err := renderHTMLReport(r)
+		if err != nil {
+			return "", err
+		}
+		return path, os.WriteFile(path, []byte(page), 0644)
+	case "pdf":
+		return path, exportPDF(r, path)
+	}
+	return "", fmt.Errorf("unknown export format %q", format)
+}
+
+// exportPDF converts the HTML report to a PDF with wkhtmltopdf.
+func exportPDF(r report, path string) error {
+	converter, err := exec.LookPath("wkhtmltopdf")
+	if err != nil {
+		return errors.New("--export pdf needs wkhtmltopdf on your PATH (or use --export html)")
+	}
+	page, err := renderHTMLReport(r)
+	if err != nil {
+		return err
+	}
+	dir, err := os.MkdirTemp("", "fast-export")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	htmlPath := filepath.Join(dir, "report.html")
+	if err := os.WriteFile(htmlPath, []byte(page), 0644); err != nil {
+		return err
+	}
+	if out, err := exec.Command(converter, "--quiet", htmlPath, path).CombinedOutput(); err != nil {
+		return fmt.Errorf("wkhtmltopdf failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSessionLog() SessionLog {
+	start := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	return SessionLog{
+		Timestamp: start,
+		FlowName:  "reply",
+		Input:     "I am sick",
+		Config: Config{
+			Model: "gemini-2.5-flash",
+			Steps: []Step{
+				{ID: "draft", Prompt: "Write ```code``` for {{input}}"},
+				{ID: "stats", Type: "metrics", Source: "{{draft}}"},
+				{ID: "polish", Prompt: "Polish <{{draft}}>"},
+			},
+		},
+		Results: map[string]string{"draft": "Get well <soon>", "stats": "{}"},
+		Steps: []StepLog{
+			{ID: "draft", StartTime: start, EndTime: start.Add(2 * time.Second), Duration: 2 * time.Second, TokensUsed: 40, Warnings: []string{"ndjson line skipped"}},
+			{ID: "stats", StartTime: start.Add(2 * time.Second), EndTime: start.Add(3 * time.Second), Duration: time.Second},
+		},
+	}
+}
+
+func TestRenderMarkdownReport(t *testing.T) {
+	out := renderMarkdownReport(newReport(testSessionLog()))
+	for _, want := range []string{
+		"# reply\n",
+		"- **Run at:** 2024-01-02 15:04:05\n",
+		"- **Duration:** 3s\n",
+		"- **Tokens:** 40\n",
+		"## draft\n\n_text step, gemini-2.5-flash_\n",
+		"````\nWrite ```code``` for {{input}}\n````\n",
+		"### Result\n\nGet well <soon>\n",
+		"> ⚠️ ndjson line skipped\n",
+		"## stats\n\n_metrics step_\n",
+		"## polish",
+		"_Did not finish._",
+		"| draft | 2s | 40 | 0 |\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected report to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestRenderHTMLReport(t *testing.T) {
+	out, err := renderHTMLReport(newReport(testSessionLog()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"<title>reply</title>",
+		"<style>",
+		"<h2>draft</h2>",
+		"Get well &lt;soon&gt;",
+		"<td>draft</td><td>2s</td><td>40</td>",
+		"Did not finish.",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected HTML to contain %q, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "<soon>") {
+		t.Error("Expected results to be escaped")
+	}
+}
+
+func TestExportRun(t *testing.T) {
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	path, err := exportRun(testSessionLog(), "markdown")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "reply_report_20240102-150405.md" {
+		t.Errorf("Unexpected report name %s", path)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, path))
+	if err != nil || !strings.HasPrefix(string(data), "# reply") {
+		t.Errorf("Expected a Markdown report, got %q (%v)", data, err)
+	}
+
+	t.Setenv("PATH", dir)
+	if _, err := exportRun(testSessionLog(), "pdf"); err == nil || !strings.Contains(err.Error(), "wkhtmltopdf") {
+		t.Errorf("Expected pdf export to need wkhtmltopdf, got %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	mdFence      = regexp.MustCompile("(?m)^[ \\t]*```.*$\\n?")
+	mdHeader     = regexp.MustCompile(`(?m)^[ \t]{0,3}#{1,6}[ \t]+`)
+	mdQuote      = regexp.MustCompile(`(?m)^[ \t]{0,3}>[ \t]?`)
+	mdRule       = regexp.MustCompile(`(?m)^[ \t]{0,3}(-{3,}|\*{3,}|_{3,})[ \t]*$\n?`)
+	mdImage      = regexp.MustCompile(`!\[([^\]]*)\]\([^)]*\)`)
+	mdLink       = regexp.MustCompile(`\[([^\]]+)\]\([^)]*\)`)
+	mdBold       = regexp.MustCompile(`(\*\*|__)(.+?)(\*\*|__)`)
+	mdItalic     = regexp.MustCompile(`(^|[^\w*])[*_]([^*_\s][^*_]*?)[*_]($|[^\w*])`)
+	mdInlineCode = regexp.MustCompile("`([^`]+)`")
+)
+
+// formatOutput applies the --format option to the final result.
+func formatOutput(result, format string) (string, error) {
+	switch format {
+	case "", "raw":
+		return result, nil
+	case "json-pretty":
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, []byte(strings.TrimSpace(result)), "", "  "); err != nil {
+			return "", fmt.Errorf("result is not valid JSON: %w", err)
+		}
+		return buf.String(), nil
+	case "markdown-strip":
+		return stripMarkdown(result), nil
+	}
+	return "", fmt.Errorf("unknown format %q", format)
+}
+
+// stripMarkdown removes common markdown formatting, keeping the text.
+func stripMarkdown(s string) string {
+	s = mdFence.ReplaceAllString(s, "")
+	s = mdRule.ReplaceAllString(s, "")
+	s = mdHeader.ReplaceAllString(s, "")
+	s = mdQuote.ReplaceAllString(s, "")
+	s = mdImage.ReplaceAllString(s, "$1")
+	s = mdLink.ReplaceAllString(s, "$1")
+	s = mdBold.ReplaceAllString(s, "$2")
+	s = mdItalic.ReplaceAllString(s, "$1$2$3")
+	s = mdInlineCode.ReplaceAllString(s, "$1")
+	return s
+}
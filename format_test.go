@@ -0,0 +1,31 @@
+package main
+
+import "testing"
+
+func TestFormatOutputJSONPretty(t *testing.T) {
+	got, err := formatOutput(`{"a":1,"b":[true]}`, "json-pretty")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := "{\n  \"a\": 1,\n  \"b\": [\n    true\n  ]\n}"
+	if got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+
+	if _, err := formatOutput("not json", "json-pretty"); err == nil {
+		t.Error("Expected error for invalid JSON")
+	}
+}
+
+func TestStripMarkdown(t *testing.T) {
+	input := "# Title\n\nSome **bold** and _italic_ text with `code` and a [link](http://x.y).\n\n```go\nfmt.Println()\n```\n> quoted\n---\n- item"
+	expected := "Title\n\nSome bold and italic text with code and a link.\n\nfmt.Println()\nquoted\n- item"
+	if got := stripMarkdown(input); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+
+	// snake_case identifiers are not italics
+	if got := stripMarkdown("use max_log_age here"); got != "use max_log_age here" {
+		t.Errorf("Expected identifiers untouched, got %q", got)
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	nodeStyle        = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("241")).Padding(0, 1)
+	nodeRunningStyle = nodeStyle.BorderForeground(lipgloss.Color("214")).Foreground(lipgloss.Color("214"))
+	nodeDoneStyle    = nodeStyle.BorderForeground(lipgloss.Color("42")).Foreground(lipgloss.Color("0")).Background(lipgloss.Color("42"))
+	nodeFailedStyle  = nodeStyle.BorderForeground(lipgloss.Color("196")).Foreground(lipgloss.Color("196"))
+	edgeStyle        = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+)
+
+const nodeGap = 2
+
+// stepLevels assigns every step to a phase: steps without step dependencies
+// are phase 0, and every other step runs one phase after its latest dependency.
+func stepLevels(steps []Step) map[string]int {
+	byID := make(map[string]Step)
+	for _, s := range steps {
+		byID[s.ID] = s
+	}
+
+	owners := splitOwners(steps)
+	levels := make(map[string]int)
+	visiting := make(map[string]bool)
+	var level func(id string) int
+	level = func(id string) int {
+		if l, ok := levels[id]; ok {
+			return l
+		}
+		if visiting[id] {
+			return 0 // dependency cycle, don't recurse forever
+		}
+		visiting[id] = true
+		l := 0
+		for _, dep := range flowDependencies(byID[id], owners) {
+			if _, ok := byID[dep]; ok {
+				l = max(l, level(dep)+1)
+			}
+		}
+		visiting[id] = false
+		levels[id] = l
+		return l
+	}
+	for _, s := range steps {
+		level(s.ID)
+	}
+	return levels
+}
+
+// findCycle returns the IDs of a dependency cycle, e.g. [a b a], or nil if
+// the steps form a DAG.
+func findCycle(steps []Step) []string {
+	byID := make(map[string]Step)
+	for _, s := range steps {
+		byID[s.ID] = s
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	owners := splitOwners(steps)
+	state := make(map[string]int)
+	var path []string
+	var visit func(id string) []string
+	visit = func(id string) []string {
+		switch state[id] {
+		case visiting:
+			for i, p := range path {
+				if p == id {
+					return append(append([]string{}, path[i:]...), id)
+				}
+			}
+		case visited:
+			return nil
+		}
+		state[id] = visiting
+		path = append(path, id)
+		for _, dep := range flowDependencies(byID[id], owners) {
+			if _, ok := byID[dep]; !ok {
+				continue
+			}
+			if cycle := visit(dep); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[id] = visited
+		return nil
+	}
+	for _, s := range steps {
+		if cycle := visit(s.ID); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// stepPhases groups step IDs by level, keeping flow order within a phase.
+func stepPhases(steps []Step) [][]string {
+	levels := stepLevels(steps)
+	var phases [][]string
+	for _, s := range steps {
+		l := levels[s.ID]
+		for len(phases) <= l {
+			phases = append(phases, nil)
+		}
+		phases[l] = append(phases[l], s.ID)
+	}
+	return phases
+}
+
+// formatStepOrder lists the phases a flow runs in, one per line, e.g.
+// "Phase 1 (parallel): step1, step2". Steps in the same phase don't depend
+// on each other and can run at the same time.
+func formatStepOrder(steps []Step) (string, error) {
+	if cycle := findCycle(steps); cycle != nil {
+		return "", fmt.Errorf("steps depend on each other in a cycle: %s", strings.Join(cycle, " → "))
+	}
+	var b strings.Builder
+	for i, phase := range stepPhases(steps) {
+		label := fmt.Sprintf("Phase %d", i+1)
+		if len(phase) > 1 {
+			label += " (parallel)"
+		}
+		fmt.Fprintf(&b, "%s: %s\n", label, strings.Join(phase, ", "))
+	}
+	return b.String(), nil
+}
+
+// isLinear reports whether the steps form a single chain, i.e. no two steps
+// can run at the same time.
+func isLinear(steps []Step) bool {
+	for _, phase := range stepPhases(steps) {
+		if len(phase) > 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// renderGraph draws the flow as rows of boxes, one row per phase, with
+// parallel steps side by side and connectors between consecutive phases.
+func (m FlowModel) renderGraph() string {
+	var steps []Step
+	status := make(map[string]*StepStatus)
+	for _, s := range m.Steps {
+		steps = append(steps, s.Step)
+		status[s.Step.ID] = s
+	}
+
+	// Which steps feed later steps, and which steps wait on others. Steps
+	// that only wait through depends_on get a dashed line.
+	hasDependents := make(map[string]bool)
+	hasDeps := make(map[string]bool)
+	hasTagDeps := make(map[string]bool)
+	owners := splitOwners(steps)
+	for _, s := range steps {
+		deps := flowDependencies(s, owners)
+		for i, dep := range deps {
+			if _, ok := status[dep]; ok {
+				hasDependents[dep] = true
+				hasDeps[s.ID] = true
+				if i < len(deps)-len(s.DependsOn) {
+					hasTagDeps[s.ID] = true
+				}
+			}
+		}
+	}
+
+	phases := stepPhases(steps)
+	var rows []string
+	var centers []map[string]int
+	for _, phase := range phases {
+		var boxes []string
+		rowCenters := make(map[string]int)
+		col := 0
+		for i, id := range phase {
+			box := nodeBox(status[id])
+			if i > 0 {
+				boxes = append(boxes, strings.Repeat(" ", nodeGap))
+				col += nodeGap
+			}
+			w := lipgloss.Width(box)
+			rowCenters[id] = col + w/2
+			col += w
+			boxes = append(boxes, box)
+		}
+		rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top, boxes...))
+		centers = append(centers, rowCenters)
+	}
+
+	var b strings.Builder
+	for i, row := range rows {
+		b.WriteString(row)
+		b.WriteString("\n")
+		if i == len(rows)-1 {
+			break
+		}
+
+		var up, down, dashed []int
+		for id, c := range centers[i] {
+			if hasDependents[id] {
+				up = append(up, c)
+			}
+		}
+		for id, c := range centers[i+1] {
+			if hasDeps[id] {
+				down = append(down, c)
+				if !hasTagDeps[id] {
+					dashed = append(dashed, c)
+				}
+			}
+		}
+		b.WriteString(edgeStyle.Render(dashDrops(connector(up, down), dashed)))
+		b.WriteString("\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// dashDrops draws the drops of a connector into the given columns dashed.
+func dashDrops(conn string, cols []int) string {
+	lines := strings.Split(conn, "\n")
+	if len(cols) == 0 || len(lines) != 3 {
+		return conn
+	}
+	bottom := []rune(lines[2])
+	for _, c := range cols {
+		if c < len(bottom) && bottom[c] == '│' {
+			bottom[c] = '┆'
+		}
+	}
+	lines[2] = string(bottom)
+	return strings.Join(lines, "\n")
+}
+
+func nodeBox(s *StepStatus) string {
+	switch s.State {
+	case StateRunning:
+		return nodeRunningStyle.Render(s.Step.ID)
+	case StateDone:
+		return nodeDoneStyle.Render("✓ " + s.Step.ID)
+	case StateFailed:
+		return nodeFailedStyle.Render("✕ " + s.Step.ID)
+	}
+	return nodeStyle.Render(s.Step.ID)
+}
+
+// connector draws the three lines joining one phase to the next: drops from
+// the upper boxes, a horizontal bus, and drops into the lower boxes.
+func connector(up, down []int) string {
+	if len(up) == 0 && len(down) == 0 {
+		return ""
+	}
+	sort.Ints(up)
+	sort.Ints(down)
+
+	isUp := make(map[int]bool)
+	isDown := make(map[int]bool)
+	all := append(append([]int{}, up...), down...)
+	for _, c := range up {
+		isUp[c] = true
+	}
+	for _, c := range down {
+		isDown[c] = true
+	}
+	sort.Ints(all)
+	left, right := all[0], all[len(all)-1]
+
+	top := []rune(strings.Repeat(" ", right+1))
+	bus := []rune(strings.Repeat(" ", right+1))
+	bottom := []rune(strings.Repeat(" ", right+1))
+	for c := left; c <= right; c++ {
+		bus[c] = '─'
+	}
+	for _, c := range all {
+		u, d := isUp[c], isDown[c]
+		switch {
+		case left == right:
+			bus[c] = '│'
+		case u && d && c == left:
+			bus[c] = '├'
+		case u && d && c == right:
+			bus[c] = '┤'
+		case u && d:
+			bus[c] = '┼'
+		case c == left && u:
+			bus[c] = '└'
+		case c == left:
+			bus[c] = '┌'
+		case c == right && u:
+			bus[c] = '┘'
+		case c == right:
+			bus[c] = '┐'
+		case u:
+			bus[c] = '┴'
+		default:
+			bus[c] = '┬'
+		}
+		if u {
+			top[c] = '│'
+		}
+		if d {
+			bottom[c] = '│'
+		}
+	}
+	return string(top) + "\n" + string(bus) + "\n" + string(bottom)
+}
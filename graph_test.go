@@ -0,0 +1,123 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStepPhases(t *testing.T) {
+	steps := []Step{
+		{ID: "a", Prompt: "{{clipboard}}"},
+		{ID: "b", Prompt: "{{a}}"},
+		{ID: "c", Prompt: "{{a}}"},
+		{ID: "d", Prompt: "{{b}} and {{c}}"},
+		{ID: "e", Prompt: "{{input}}"},
+	}
+
+	phases := stepPhases(steps)
+	var got []string
+	for _, p := range phases {
+		got = append(got, strings.Join(p, ","))
+	}
+	expected := "a,e|b,c|d"
+	if strings.Join(got, "|") != expected {
+		t.Errorf("Expected phases %s, got %s", expected, strings.Join(got, "|"))
+	}
+}
+
+func TestConnector(t *testing.T) {
+	got := connector([]int{2}, []int{2, 6})
+	expected := "  │    \n  ├───┐\n  │   │"
+	if got != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, got)
+	}
+}
+
+func TestFindCycle(t *testing.T) {
+	steps := []Step{
+		{ID: "a", Prompt: "{{input}}"},
+		{ID: "b", Prompt: "{{a}} {{c}}"},
+		{ID: "c", Prompt: "{{b}}"},
+	}
+	if got := strings.Join(findCycle(steps), " "); got != "b c b" {
+		t.Errorf("Expected cycle b c b, got %q", got)
+	}
+	if cycle := findCycle(steps[:2]); cycle != nil {
+		t.Errorf("Expected no cycle, got %v", cycle)
+	}
+}
+
+func TestDashDrops(t *testing.T) {
+	got := dashDrops(connector([]int{2}, []int{2, 6}), []int{6})
+	expected := "  │    \n  ├───┐\n  │   ┆"
+	if got != expected {
+		t.Errorf("Expected\n%s\ngot\n%s", expected, got)
+	}
+}
+
+func TestFlatLayout(t *testing.T) {
+	chain := Config{Steps: []Step{
+		{ID: "a", Prompt: "{{input}}"},
+		{ID: "b", Prompt: "{{a}}", Comment: "second"},
+		{ID: "c", Prompt: "{{b}}"},
+	}}
+	parallel := Config{Steps: []Step{
+		{ID: "a", Prompt: "{{input}}"},
+		{ID: "b", Prompt: "{{a}}"},
+		{ID: "c", Prompt: "{{a}}"},
+	}}
+
+	cases := []struct {
+		layout string
+		conf   Config
+		flat   bool
+	}{
+		{"auto", chain, true},
+		{"auto", parallel, false},
+		{"tree", chain, false},
+		{"flat", parallel, true},
+	}
+	for _, c := range cases {
+		m := FlowModel{Layout: c.layout, Steps: buildSteps(c.conf)}
+		if got := m.flatLayout(); got != c.flat {
+			t.Errorf("flatLayout() with %s layout = %v, want %v", c.layout, got, c.flat)
+		}
+	}
+
+	m := FlowModel{Layout: "flat", Steps: buildSteps(chain), Expanded: map[string]bool{}}
+	m.Steps[0].State = StateDone
+	m.Steps[0].Duration = 1200 * time.Millisecond
+	lines := strings.Split(m.renderFlat(""), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Expected three steps and a comment line, got %q", lines)
+	}
+	for i, want := range []string{"1. ✓ a 1.2s", "2. • b", "   ", "3. • c"} {
+		if !strings.HasPrefix(lines[i], want) {
+			t.Errorf("Line %d = %q, want prefix %q", i, lines[i], want)
+		}
+	}
+}
+
+func TestFormatStepOrder(t *testing.T) {
+	steps := []Step{
+		{ID: "step1", Prompt: "{{input}}"},
+		{ID: "step2", Prompt: "{{clipboard}}"},
+		{ID: "step3", Prompt: "{{step1}} {{step2}}"},
+		{ID: "step4", Prompt: "{{step3}}"},
+		{ID: "step5", Prompt: "{{step3}}"},
+	}
+	got, err := formatStepOrder(steps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Phase 1 (parallel): step1, step2\nPhase 2: step3\nPhase 3 (parallel): step4, step5\n"
+	if got != want {
+		t.Errorf("formatStepOrder() = %q, want %q", got, want)
+	}
+
+	steps[0].Prompt = "{{step4}}"
+	if _, err := formatStepOrder(steps); err == nil {
+		t.Error("Expected error for a dependency cycle")
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// imageResultPrefix starts the results of expect_image steps, which are
+// stored as data URLs like "data:image/png;base64,iVBOR...".
+const imageResultPrefix = "data:image/"
+
+// imagePreview is shown in the TUI instead of an image result's base64.
+const imagePreview = "🖼 [image]"
+
+// expectImageKey marks the context of calls whose answer should be the first
+// image of the response rather than its text.
+type expectImageKey struct{}
+
+func withExpectImage(ctx context.Context) context.Context {
+	return context.WithValue(ctx, expectImageKey{}, true)
+}
+
+func expectsImage(ctx context.Context) bool {
+	v, _ := ctx.Value(expectImageKey{}).(bool)
+	return v
+}
+
+// validateExpectImage rejects options that would treat an image result as
+// text or send it in pieces.
+func validateExpectImage(s Step) error {
+	if !s.ExpectImage {
+		return nil
+	}
+	var conflict string
+	switch {
+	case s.Type != "" && s.Type != "text":
+		return fmt.Errorf("step '%s': expect_image only applies to text steps", s.ID)
+	case s.StreamingToFile:
+		conflict = "streaming_to_file"
+	case s.ChunkSize > 0:
+		conflict = "chunk_size"
+	case s.OutputFormat != "":
+		conflict = "output_format"
+	case s.OutputMaxLength > 0:
+		conflict = "output_max_length"
+	case s.PostProcess != "":
+		conflict = "post_process"
+	case s.Prepend != "" || s.Append != "":
+		conflict = "prepend and append"
+	case len(s.SplitOutput) > 0:
+		conflict = "split_output"
+	default:
+		return nil
+	}
+	return fmt.Errorf("step '%s': expect_image can't be combined with %s", s.ID, conflict)
+}
+
+// imageModelConfig asks the model to answer with an image as well as text.
+var imageModelConfig = map[string]interface{}{"response_modalities": []interface{}{"TEXT", "IMAGE"}}
+
+// firstImagePart returns the first inline image of a response's parts as a
+// data URL, or "" if there is none.
+func firstImagePart(parts []interface{}) string {
+	for _, p := range parts {
+		part, _ := p.(map[string]interface{})
+		inline, ok := part["inlineData"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		mime, _ := inline["mimeType"].(string)
+		data, _ := inline["data"].(string)
+		if strings.HasPrefix(mime, "image/") && data != "" {
+			return "data:" + mime + ";base64," + data
+		}
+	}
+	return ""
+}
+
+func isImageResult(res string) bool {
+	return strings.HasPrefix(res, imageResultPrefix) && strings.Contains(res, ";base64,")
+}
+
+// imageBytes decodes an image result; ok is false for any other result.
+func imageBytes(res string) (data []byte, ok bool) {
+	if !isImageResult(res) {
+		return nil, false
+	}
+	_, encoded, _ := strings.Cut(res, ";base64,")
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// pngDataURL is a data URL holding the bytes "PNG!".
+const pngDataURL = "data:image/png;base64,UE5HIQ=="
+
+func TestFirstImagePart(t *testing.T) {
+	parts := []interface{}{
+		map[string]interface{}{"text": "Here you go"},
+		map[string]interface{}{"inlineData": map[string]interface{}{"mimeType": "image/png", "data": "UE5HIQ=="}},
+	}
+	if got := firstImagePart(parts); got != pngDataURL {
+		t.Errorf("firstImagePart() = %q", got)
+	}
+	if got := firstImagePart(parts[:1]); got != "" {
+		t.Errorf("Expected no image in a text-only response, got %q", got)
+	}
+}
+
+func TestImageBytes(t *testing.T) {
+	if data, ok := imageBytes(pngDataURL); !ok || string(data) != "PNG!" {
+		t.Errorf("imageBytes() = %q, %v", data, ok)
+	}
+	for _, res := range []string{"plain text", "data:image/png;base64,***", "data:text/plain;base64,UE5HIQ=="} {
+		if _, ok := imageBytes(res); ok {
+			t.Errorf("Expected %q not to be an image", res)
+		}
+	}
+	if stepPreview(pngDataURL) != imagePreview {
+		t.Errorf("Expected the image indicator as preview, got %q", stepPreview(pngDataURL))
+	}
+}
+
+func TestValidateExpectImage(t *testing.T) {
+	if err := validateExpectImage(Step{ID: "img", ExpectImage: true, SaveTo: "out.png"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	for _, s := range []Step{
+		{ID: "img", ExpectImage: true, Type: "metrics"},
+		{ID: "img", ExpectImage: true, StreamingToFile: true},
+		{ID: "img", ExpectImage: true, OutputMaxLength: 100},
+		{ID: "img", ExpectImage: true, Append: "!"},
+	} {
+		if err := validateExpectImage(s); err == nil {
+			t.Errorf("Expected error for %+v", s)
+		}
+	}
+}
+
+func TestRunStepExpectImage(t *testing.T) {
+	originalCallGemini := callGemini
+	defer func() { callGemini = originalCallGemini }()
+	var cfg map[string]interface{}
+	callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+		if !expectsImage(ctx) {
+			return "just text", 0, nil
+		}
+		cfg = modelConfigFrom(ctx)
+		return pngDataURL, 0, nil
+	}
+
+	out := filepath.Join(t.TempDir(), "cat.png")
+	s := Step{ID: "img", Prompt: "Draw a cat", ExpectImage: true, SaveTo: out}
+	res, _, err := runStep(context.Background(), newFlowRun(""), Config{}, s, func(string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != pngDataURL {
+		t.Errorf("Expected the image as a data URL, got %q", res)
+	}
+	if want := []interface{}{"TEXT", "IMAGE"}; !reflect.DeepEqual(cfg["response_modalities"], want) {
+		t.Errorf("Expected image output to be requested, got %v", cfg)
+	}
+	if data, err := os.ReadFile(out); err != nil || string(data) != "PNG!" {
+		t.Errorf("Expected save_to to write the decoded image, got %q (%v)", data, err)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// InlineImage is an image sent to Gemini alongside the prompt text.
+type InlineImage struct {
+	MIMEType string
+	Data     []byte
+}
+
+// readClipboardImage returns the PNG on the clipboard, using pngpaste when
+// installed and falling back to osascript.
+var readClipboardImage = func() ([]byte, error) {
+	if out, err := exec.Command("pngpaste", "-").Output(); err == nil && len(out) > 0 {
+		return out, nil
+	}
+	out, err := exec.Command("osascript", "-e", "the clipboard as «class PNGf»").Output()
+	if err != nil {
+		return nil, errors.New("no image on the clipboard")
+	}
+	// osascript prints the data as «data PNGf89504E47...»
+	data := strings.TrimSpace(string(out))
+	data = strings.TrimSuffix(strings.TrimPrefix(data, "«data PNGf"), "»")
+	return hex.DecodeString(data)
+}
+
+// stepImages collects the images a step sends: the clipboard image when the
+// prompt uses {{clipboard_image}}, and the step's image_file.
+func (r *FlowRun) stepImages(s Step) ([]InlineImage, error) {
+	var images []InlineImage
+	if strings.Contains(s.Prompt, "{{clipboard_image}}") {
+		data, err := readClipboardImage()
+		if err != nil {
+			return nil, err
+		}
+		img, err := newInlineImage(data)
+		if err != nil {
+			return nil, fmt.Errorf("clipboard: %w", err)
+		}
+		images = append(images, img)
+	}
+	if s.ImageFile != "" {
+		path := expandHome(r.fillTags(s.ImageFile))
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read image: %w", err)
+		}
+		img, err := newInlineImage(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		images = append(images, img)
+	}
+	return images, nil
+}
+
+func newInlineImage(data []byte) (InlineImage, error) {
+	mime := http.DetectContentType(data)
+	if !strings.HasPrefix(mime, "image/") {
+		return InlineImage{}, fmt.Errorf("not an image (%s)", mime)
+	}
+	return InlineImage{MIMEType: mime, Data: data}, nil
+}
+
+// imageHashes identifies images in the session log without storing their bytes.
+func imageHashes(images []InlineImage) []string {
+	var hashes []string
+	for _, img := range images {
+		sum := sha256.Sum256(img.Data)
+		hashes = append(hashes, hex.EncodeToString(sum[:]))
+	}
+	return hashes
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// A 1x1 PNG header is enough for content sniffing.
+var testPNG = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01")
+
+func TestRunFlowSendsImages(t *testing.T) {
+	originalCallGemini := callGemini
+	originalReadClipboardImage := readClipboardImage
+	defer func() {
+		callGemini = originalCallGemini
+		readClipboardImage = originalReadClipboardImage
+	}()
+
+	readClipboardImage = func() ([]byte, error) { return testPNG, nil }
+	var gotPrompt string
+	var gotImages []InlineImage
+	callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+		gotPrompt, gotImages = prompt, images
+		return "described", 0, nil
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "photo.png"), testPNG, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	run := newFlowRun(dir)
+	conf := Config{Steps: []Step{
+		{ID: "describe", Prompt: "Describe {{clipboard_image}}", ImageFile: "{{input}}/photo.png"},
+	}}
+	if err := runFlow(context.Background(), run, conf, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if gotPrompt != "Describe " {
+		t.Errorf("Expected image tag removed from prompt, got %q", gotPrompt)
+	}
+	if len(gotImages) != 2 || gotImages[0].MIMEType != "image/png" {
+		t.Fatalf("Expected 2 PNG images, got %+v", gotImages)
+	}
+	logs := run.collectStepLogs(conf)
+	if len(logs) != 1 || len(logs[0].Images) != 2 || len(logs[0].Images[0]) != 64 {
+		t.Errorf("Expected sha256 image hashes in the step log, got %+v", logs)
+	}
+}
+
+func TestStepImagesRejectsNonImages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(path, []byte("just text"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newFlowRun("").stepImages(Step{ID: "s", ImageFile: path}); err == nil {
+		t.Error("Expected error for a non-image image_file")
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// sampleFlow shows every feature a step supports so it doubles as documentation.
+const sampleFlow = `{
+  "steps": [
+    {
+      "id": "summary",
+      "prompt": "Summarize the following text in three bullet points:\n{{clipboard}}"
+    },
+    {
+      "id": "questions",
+      "model": "gemini-2.0-flash",
+      "prompt": "List open questions raised by this summary:\n{{summary}}"
+    },
+    {
+      "id": "tone",
+      "prompt": "Describe the tone of this summary in one sentence:\n{{summary}}"
+    },
+    {
+      "id": "hello",
+      "tab_id": "main",
+      "prompt": "Write a short message for {{input}} covering:\n{{summary}}\n\nOpen questions:\n{{questions}}\n\nMatch this tone: {{tone}}"
+    }
+  ]
+}
+`
+
+// runInitCommand implements `fast init [--global]`.
+func runInitCommand(args []string) error {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	global := fs.Bool("global", false, "create the ~/fast-flows layout instead of a project")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *global {
+		root := resolveFastFlowsDir()
+		for _, dir := range []string{filepath.Join(root, "flows"), filepath.Join(root, "logs")} {
+			if err := createDir(dir); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := createDir("flows"); err != nil {
+		return err
+	}
+	if err := createFile(filepath.Join("flows", "hello.json"), []byte(sampleFlow)); err != nil {
+		return err
+	}
+
+	project, _ := json.MarshalIndent(ProjectConfig{
+		Model:        "gemini-2.5-flash",
+		SystemPrompt: "You are a helpful assistant.",
+	}, "", "  ")
+	return createFile(projectConfigFile, append(project, '\n'))
+}
+
+func createDir(dir string) error {
+	if _, err := os.Stat(dir); err == nil {
+		fmt.Printf("  - %s (exists, skipped)\n", dir)
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	fmt.Printf("%s Created %s/\n", checkMark, dir)
+	return nil
+}
+
+func createFile(path string, data []byte) error {
+	if _, err := os.Stat(path); err == nil {
+		fmt.Printf("  - %s (exists, skipped)\n", path)
+		return nil
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("%s Created %s\n", checkMark, path)
+	return nil
+}
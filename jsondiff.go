@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// jsonChange is one difference between two JSON documents, shaped like an
+// RFC 6902 JSON Patch operation: op is add, remove or replace and path a
+// JSON Pointer. Old is only used for the summary.
+type jsonChange struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value"`
+	Old   any    `json:"-"`
+}
+
+// MarshalJSON writes value for add and replace even when it is null, and
+// leaves it out for remove, as RFC 6902 requires.
+func (c jsonChange) MarshalJSON() ([]byte, error) {
+	if c.Op == "remove" {
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{c.Op, c.Path})
+	}
+	return json.Marshal(struct {
+		Op    string `json:"op"`
+		Path  string `json:"path"`
+		Value any    `json:"value"`
+	}{c.Op, c.Path, c.Value})
+}
+
+// validateJSONDiff checks the fields of a json_diff step.
+func validateJSONDiff(s Step) error {
+	if s.Type != "json_diff" {
+		return nil
+	}
+	if s.SourceA == "" || s.SourceB == "" {
+		return fmt.Errorf("step '%s': json_diff needs source_a and source_b", s.ID)
+	}
+	return nil
+}
+
+// runJSONDiff compares two JSON documents and reports the changes from a
+// to b, as a JSON Patch with output_format json_patch and as a readable
+// summary otherwise.
+func runJSONDiff(a, b, format string) (string, error) {
+	var va, vb any
+	if err := json.Unmarshal([]byte(stripCodeFence(a)), &va); err != nil {
+		return "", fmt.Errorf("source_a is not valid JSON: %w", err)
+	}
+	if err := json.Unmarshal([]byte(stripCodeFence(b)), &vb); err != nil {
+		return "", fmt.Errorf("source_b is not valid JSON: %w", err)
+	}
+	changes := diffJSON("", va, vb, nil)
+
+	if format == "json_patch" {
+		if changes == nil {
+			return "[]", nil
+		}
+		out, err := json.MarshalIndent(changes, "", "  ")
+		return string(out), err
+	}
+	return summarizeJSONChanges(changes), nil
+}
+
+// diffJSON appends the changes turning a into b at path to changes.
+func diffJSON(path string, a, b any, changes []jsonChange) []jsonChange {
+	switch a := a.(type) {
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(a)+len(b))
+		for k := range a {
+			keys = append(keys, k)
+		}
+		for k := range b {
+			if _, ok := a[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			p := path + "/" + escapeJSONPointer(k)
+			av, inA := a[k]
+			bv, inB := b[k]
+			switch {
+			case !inB:
+				changes = append(changes, jsonChange{Op: "remove", Path: p, Old: av})
+			case !inA:
+				changes = append(changes, jsonChange{Op: "add", Path: p, Value: bv})
+			default:
+				changes = diffJSON(p, av, bv, changes)
+			}
+		}
+		return changes
+	case []any:
+		b, ok := b.([]any)
+		if !ok {
+			break
+		}
+		common := min(len(a), len(b))
+		for i := 0; i < common; i++ {
+			changes = diffJSON(fmt.Sprintf("%s/%d", path, i), a[i], b[i], changes)
+		}
+		for i := common; i < len(b); i++ {
+			changes = append(changes, jsonChange{Op: "add", Path: fmt.Sprintf("%s/%d", path, i), Value: b[i]})
+		}
+		// Remove from the end so the indexes of a patch stay valid
+		for i := len(a) - 1; i >= common; i-- {
+			changes = append(changes, jsonChange{Op: "remove", Path: fmt.Sprintf("%s/%d", path, i), Old: a[i]})
+		}
+		return changes
+	}
+	if !reflect.DeepEqual(a, b) {
+		changes = append(changes, jsonChange{Op: "replace", Path: path, Value: b, Old: a})
+	}
+	return changes
+}
+
+// escapeJSONPointer escapes a key for use in a JSON Pointer (RFC 6901).
+func escapeJSONPointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
+
+// summarizeJSONChanges lists changes one per line: + added, - removed and
+// ~ changed values.
+func summarizeJSONChanges(changes []jsonChange) string {
+	if len(changes) == 0 {
+		return "No differences."
+	}
+	var added, removed, changed int
+	var b strings.Builder
+	for _, c := range changes {
+		path := c.Path
+		if path == "" {
+			path = "/"
+		}
+		switch c.Op {
+		case "add":
+			added++
+			fmt.Fprintf(&b, "+ %s: %s\n", path, compactJSON(c.Value))
+		case "remove":
+			removed++
+			fmt.Fprintf(&b, "- %s: %s\n", path, compactJSON(c.Old))
+		default:
+			changed++
+			fmt.Fprintf(&b, "~ %s: %s → %s\n", path, compactJSON(c.Old), compactJSON(c.Value))
+		}
+	}
+	fmt.Fprintf(&b, "\n%d added, %d removed, %d changed", added, removed, changed)
+	return b.String()
+}
+
+func compactJSON(v any) string {
+	out, _ := json.Marshal(v)
+	return string(out)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRunJSONDiffPatch(t *testing.T) {
+	a := `{"name": "fast", "tags": ["a", "b", "c"], "meta": {"v": 1, "old": true}, "a/b": 1}`
+	b := "```json\n" + `{"name": "faster", "tags": ["a", "x"], "meta": {"v": 1, "new": null}, "a/b": 2}` + "\n```"
+
+	got, err := runJSONDiff(a, b, "json_patch")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var patch []map[string]any
+	if err := json.Unmarshal([]byte(got), &patch); err != nil {
+		t.Fatalf("invalid patch %s: %v", got, err)
+	}
+	var ops []string
+	for _, op := range patch {
+		ops = append(ops, op["op"].(string)+" "+op["path"].(string))
+		if _, ok := op["value"]; ok != (op["op"] != "remove") {
+			t.Errorf("Expected value only for add and replace, got %v", op)
+		}
+	}
+	want := "replace /a~1b|add /meta/new|remove /meta/old|replace /name|replace /tags/1|remove /tags/2"
+	if strings.Join(ops, "|") != want {
+		t.Errorf("ops = %s, want %s", strings.Join(ops, "|"), want)
+	}
+	if v, ok := patch[1]["value"]; !ok || v != nil {
+		t.Errorf("Expected the null value of /meta/new in the patch, got %v", patch[1])
+	}
+	if patch[3]["value"] != "faster" {
+		t.Errorf("Expected the new /name in the patch, got %v", patch[3])
+	}
+
+	if got, _ := runJSONDiff(a, a, "json_patch"); got != "[]" {
+		t.Errorf("Expected an empty patch, got %s", got)
+	}
+}
+
+func TestRunJSONDiffSummary(t *testing.T) {
+	got, err := runJSONDiff(`{"a": 1, "b": [1]}`, `{"a": 2, "b": [1, 2], "c": "x"}`, "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := "~ /a: 1 → 2\n+ /b/1: 2\n+ /c: \"x\"\n\n2 added, 0 removed, 1 changed"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if got, _ := runJSONDiff(`[1]`, `[1]`, ""); got != "No differences." {
+		t.Errorf("got %q", got)
+	}
+	if got, _ := runJSONDiff(`{"a": 1}`, `[1]`, ""); !strings.HasPrefix(got, "~ /: ") {
+		t.Errorf("Expected a root replace, got %q", got)
+	}
+	if _, err := runJSONDiff(`{`, `{}`, ""); err == nil {
+		t.Error("Expected error for invalid source_a")
+	}
+}
+
+func TestValidateJSONDiff(t *testing.T) {
+	if err := validateJSONDiff(Step{ID: "d", Type: "json_diff", SourceA: "{{a}}"}); err == nil {
+		t.Error("Expected error without source_b")
+	}
+	if err := validateOutputFormat(Step{ID: "d", OutputFormat: "json_patch"}); err == nil {
+		t.Error("Expected json_patch to need a json_diff step")
+	}
+	if err := validateOutputFormat(Step{ID: "d", Type: "json_diff", OutputFormat: "json_patch"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
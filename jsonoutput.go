@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// jsonOutput is what --json-output prints to stdout after a run.
+type jsonOutput struct {
+	Flow       string            `json:"flow"`
+	Success    bool              `json:"success"`
+	Result     string            `json:"result,omitempty"`
+	Steps      map[string]string `json:"steps,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	FailedStep string            `json:"failed_step,omitempty"`
+	DurationMs int64             `json:"duration_ms"`
+}
+
+// newJSONOutput describes a finished run: the final result and every step
+// result, or the error and the step that caused it. run may be nil for
+// errors before the flow started.
+func newJSONOutput(flowName string, run *FlowRun, result string, err error, duration time.Duration) jsonOutput {
+	out := jsonOutput{Flow: flowName, Success: err == nil, DurationMs: duration.Milliseconds()}
+	if err != nil {
+		out.Error = err.Error()
+		var se *StepError
+		if errors.As(err, &se) {
+			out.FailedStep = se.StepID
+		}
+		return out
+	}
+	out.Result = result
+	out.Steps = make(map[string]string)
+	for id, res := range run.Results() {
+		out.Steps[id] = res
+	}
+	return out
+}
+
+// printJSONOutput writes a run's outcome to stdout as one line of JSON.
+func printJSONOutput(out jsonOutput) error {
+	data, err := json.Marshal(out)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// runWithJSONOutput implements --json-output: the flow runs like with
+// --quiet and the outcome is printed to stdout as a single JSON object,
+// failures included. The returned error only sets the exit code.
+func runWithJSONOutput(run *FlowRun, conf Config, flowName string, opts Options) error {
+	start := time.Now()
+	result, err := runFlowWithoutTUI(run, conf, flowName, opts)
+	if jerr := printJSONOutput(newJSONOutput(flowName, run, result, err, time.Since(start))); jerr != nil {
+		return jerr
+	}
+	return err
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewJSONOutput(t *testing.T) {
+	run := newFlowRun("hi")
+	run.setResult("draft", "Hello")
+	run.setResult("polish", "Hello!")
+
+	data, err := json.Marshal(newJSONOutput("reply", run, "Hello!", nil, 1500*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"flow":"reply","success":true,"result":"Hello!","steps":{"draft":"Hello","polish":"Hello!"},"duration_ms":1500}`
+	if string(data) != want {
+		t.Errorf("Expected %s, got %s", want, data)
+	}
+
+	stepErr := &StepError{StepID: "polish", StepType: "text", Cause: errors.New("quota exceeded")}
+	out := newJSONOutput("reply", run, "", stepErr, time.Second)
+	if out.Success || out.FailedStep != "polish" || out.Error != stepErr.Error() || out.Steps != nil || out.Result != "" {
+		t.Errorf("Unexpected failure output: %+v", out)
+	}
+
+	out = newJSONOutput("reply", run, "", errors.New("no input"), time.Second)
+	if out.Success || out.FailedStep != "" || out.Error != "no input" {
+		t.Errorf("Unexpected failure output: %+v", out)
+	}
+}
+
+func TestNewJSONOutputBeforeRun(t *testing.T) {
+	data, err := json.Marshal(newJSONOutput("missing", nil, "", errors.New("Flow 'missing' not found"), 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"flow":"missing","success":false,"error":"Flow 'missing' not found","duration_ms":0}`
+	if string(data) != want {
+		t.Errorf("Expected %s, got %s", want, data)
+	}
+}
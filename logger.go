@@ -1,10 +1,15 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"sync"
 	"time"
 )
 
@@ -12,19 +17,63 @@ type SessionLog struct {
 	Timestamp time.Time         `json:"timestamp"`
 	FlowName  string            `json:"flow_name"`
 	Input     string            `json:"input"`
+	InputFile string            `json:"input_file,omitempty"`
 	Clipboard string            `json:"clipboard"`
 	Config    Config            `json:"config"`
 	Results   map[string]string `json:"results"`
+	Pinned    []string          `json:"pinned,omitempty"`
+	Steps     []StepLog         `json:"steps,omitempty"`
+
+	// ConfigHash identifies the flow config, to detect edits before --resume.
+	ConfigHash string `json:"config_hash,omitempty"`
+}
+
+// StepLog records how a single step executed.
+type StepLog struct {
+	ID         string        `json:"id"`
+	StartTime  time.Time     `json:"start_time"`
+	EndTime    time.Time     `json:"end_time"`
+	Duration   time.Duration `json:"duration"`
+	TokensUsed int           `json:"tokens_used"`
+	Retries    int           `json:"retries"`
+	Model      string        `json:"model"`
+	Images     []string      `json:"images,omitempty"` // sha256 of each image sent
+	Warnings   []string      `json:"warnings,omitempty"`
+	Mocked     bool          `json:"mocked,omitempty"`   // result came from --mock-step
+	Fallback   bool          `json:"fallback,omitempty"` // result came from fallback_prompt
+
+	// TruncatedFrom is the result length before output_max_length cut it.
+	TruncatedFrom int `json:"truncated_from,omitempty"`
 }
 
-func saveSessionLog(flowName, input, clipboard string, conf Config, results map[string]string) {
+// collectStepLogs returns the recorded step logs in flow order. Steps that
+// never ran (e.g. pinned with --set) are omitted.
+func (r *FlowRun) collectStepLogs(conf Config) []StepLog {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var logs []StepLog
+	for _, s := range conf.Steps {
+		if l, ok := r.stepLogs[s.ID]; ok {
+			logs = append(logs, l)
+		}
+	}
+	return logs
+}
+
+// saveSessionLog writes a run to the logs folder and returns what it wrote.
+func saveSessionLog(flowName, input, inputFile, clipboard string, conf Config, results map[string]string, pinned []string, steps []StepLog) SessionLog {
 	log := SessionLog{
 		Timestamp: time.Now(),
 		FlowName:  flowName,
 		Input:     input,
+		InputFile: inputFile,
 		Clipboard: clipboard,
 		Config:    conf,
 		Results:   results,
+		Pinned:    pinned,
+		Steps:     steps,
+
+		ConfigHash: configHash(conf),
 	}
 
 	data, err := json.MarshalIndent(log, "", "  ")
@@ -32,21 +81,191 @@ func saveSessionLog(flowName, input, clipboard string, conf Config, results map[
 		// Silently fail or print to stderr? TUI might be running or just finished.
 		// Since this runs in the background goroutine, printing might interfere if TUI is still active,
 		// but we call this after the flow is done.
-		return
+		return log
+	}
+
+	logDir := logsDir()
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return log
 	}
 
-	home, err := os.UserHomeDir()
+	filename := fmt.Sprintf("%s_%s.json", time.Now().Format("2006-01-02_15-04-05"), flowName)
+	filePath := filepath.Join(logDir, filename)
+
+	_ = os.WriteFile(filePath, data, 0644)
+
+	rotateLogs(logDir)
+	return log
+}
+
+// configHash returns a short fingerprint of a flow config.
+func configHash(conf Config) string {
+	data, _ := json.Marshal(conf)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// resumeResults adds the step results stored in a session log to pinned, so
+// a new run only executes the steps that didn't finish. Values already in
+// pinned (from --set) win. It reports whether the flow changed since the log
+// was written.
+func resumeResults(path, flowName string, conf Config, pinned setFlags) (bool, error) {
+	log, err := loadSessionLog(path)
 	if err != nil {
+		return false, err
+	}
+	if log.FlowName != flowName {
+		return false, fmt.Errorf("log '%s' is from flow '%s', not '%s'", path, log.FlowName, flowName)
+	}
+
+	for _, s := range conf.Steps {
+		if _, ok := pinned[s.ID]; ok {
+			continue
+		}
+		if res := log.Results[s.ID]; res != "" {
+			pinned[s.ID] = res
+		}
+	}
+
+	logHash := log.ConfigHash
+	if logHash == "" {
+		logHash = configHash(log.Config)
+	}
+	return logHash != configHash(conf), nil
+}
+
+// runLogsCommand implements `fast logs [--clean]`.
+func runLogsCommand(args []string) error {
+	fs := flag.NewFlagSet("logs", flag.ContinueOnError)
+	clean := fs.Bool("clean", false, "delete logs past max_log_age / max_log_count")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	logDir := logsDir()
+
+	if *clean {
+		maxAge, err := parseAge(globalConfig.MaxLogAge)
+		if err != nil {
+			return fmt.Errorf("invalid max_log_age: %w", err)
+		}
+		removed, err := cleanLogs(logDir, maxAge, globalConfig.MaxLogCount)
+		fmt.Fprintf(os.Stderr, "🧹 Removed %d old session log(s)\n", removed)
+		return err
+	}
+
+	files, _ := filepath.Glob(filepath.Join(logDir, "*.json"))
+	fmt.Printf("%d session log(s) in %s\n", len(files), logDir)
+	for _, f := range files {
+		fmt.Printf("  - %s\n", filepath.Base(f))
+	}
+	return nil
+}
+
+// quietLogs hides the notice about removed logs, for --quiet.
+var quietLogs bool
+
+// While the TUI is on screen, what rotateLogs has to say is kept in
+// logNotices instead of drawing over it; flushLogNotices prints it.
+var (
+	logNoticesMu   sync.Mutex
+	holdLogNotices bool
+	logNotices     []string
+)
+
+// logsDir is where session logs and trace files go.
+func logsDir() string {
+	return filepath.Join(resolveFastFlowsDir(), "logs")
+}
+
+// rotateLogs applies the max_log_age and max_log_count limits from the
+// global config and reports how many logs were removed on stderr.
+func rotateLogs(logDir string) {
+	maxAge, err := parseAge(globalConfig.MaxLogAge)
+	if err != nil {
+		logNotice("❌ Invalid max_log_age: %v", err)
 		return
 	}
+	removed, err := cleanLogs(logDir, maxAge, globalConfig.MaxLogCount)
+	if err != nil {
+		logNotice("❌ Failed to clean logs: %v", err)
+	}
+	if removed > 0 && !quietLogs {
+		logNotice("🧹 Removed %d old session log(s)", removed)
+	}
+}
 
-	logDir := filepath.Join(home, "fast-flows", "logs")
-	if err := os.MkdirAll(logDir, 0755); err != nil {
+// logNotice prints a message about the logs folder on stderr, or keeps it
+// for flushLogNotices while the TUI is running.
+func logNotice(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	logNoticesMu.Lock()
+	defer logNoticesMu.Unlock()
+	if holdLogNotices {
+		logNotices = append(logNotices, msg)
 		return
 	}
+	fmt.Fprintln(os.Stderr, msg)
+}
 
-	filename := fmt.Sprintf("%s_%s.json", time.Now().Format("2006-01-02_15-04-05"), flowName)
-	filePath := filepath.Join(logDir, filename)
+// holdLogNoticesDuringTUI starts keeping log notices back; call it before
+// the TUI starts and flushLogNotices once it is gone.
+func holdLogNoticesDuringTUI() {
+	logNoticesMu.Lock()
+	holdLogNotices = true
+	logNoticesMu.Unlock()
+}
 
-	_ = os.WriteFile(filePath, data, 0644)
+// flushLogNotices prints the notices kept while the TUI ran and lets later
+// ones through.
+func flushLogNotices() {
+	logNoticesMu.Lock()
+	defer logNoticesMu.Unlock()
+	for _, msg := range logNotices {
+		fmt.Fprintln(os.Stderr, msg)
+	}
+	logNotices = nil
+	holdLogNotices = false
+}
+
+// cleanLogs deletes logs older than maxAge, then the oldest logs until at
+// most maxCount remain. A zero maxAge or maxCount disables that limit.
+func cleanLogs(logDir string, maxAge time.Duration, maxCount int) (int, error) {
+	entries, err := os.ReadDir(logDir)
+	if err != nil {
+		return 0, err
+	}
+
+	type logFile struct {
+		path    string
+		modTime time.Time
+	}
+	var files []logFile
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, logFile{filepath.Join(logDir, e.Name()), info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	removed := 0
+	remaining := len(files)
+	for _, f := range files {
+		expired := maxAge > 0 && time.Since(f.modTime) > maxAge
+		overLimit := maxCount > 0 && remaining > maxCount
+		if !expired && !overLimit {
+			continue
+		}
+		if err := os.Remove(f.path); err != nil {
+			return removed, err
+		}
+		removed++
+		remaining--
+	}
+	return removed, nil
 }
@@ -2,17 +2,22 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -20,61 +25,477 @@ import (
 // --- Configuration & Types ---
 
 type Step struct {
-	ID, TabID, Model, Prompt string
+	ID        string   `json:"id"`
+	TabID     string   `json:"tab_id,omitempty"`
+	Model     string   `json:"model,omitempty"`
+	Prompt    string   `json:"prompt"`
+	Tags      []string `json:"tags,omitempty"`
+	Comment   string   `json:"comment,omitempty"`
+	Persona   string   `json:"persona,omitempty"`
+	ImageFile string   `json:"image_file,omitempty"`
+
+	// StreamingToFile writes the response to a temp file instead of memory.
+	StreamingToFile bool `json:"streaming_to_file,omitempty"`
+
+	// TrimWhitespace overrides the flow's trim_whitespace for this step.
+	TrimWhitespace *bool `json:"trim_whitespace,omitempty"`
+
+	// ChunkSize splits a long prompt into pieces of this many characters,
+	// overlapping by Overlap, and sends each one separately. The answers are
+	// joined with Separator and optionally combined by MergePrompt.
+	ChunkSize   int    `json:"chunk_size,omitempty"`
+	Overlap     int    `json:"overlap,omitempty"`
+	Separator   string `json:"separator,omitempty"`
+	MergePrompt string `json:"merge_prompt,omitempty"`
+
+	// OutputFormat "ndjson" turns one JSON value per line into a JSON array.
+	OutputFormat string `json:"output_format,omitempty"`
+
+	// OutputMaxLength cuts results longer than this many characters at a
+	// word boundary. TruncateFrom "start" keeps the end instead ("end").
+	OutputMaxLength int    `json:"output_max_length,omitempty"`
+	TruncateFrom    string `json:"truncate_from,omitempty"`
+
+	// SplitOutput stores parts of the result as results of their own: each
+	// key gets what its regexp captures, e.g. {"title": "(?m)^Title: (.+)$"}
+	// makes {{title}} available to later steps.
+	SplitOutput map[string]string `json:"split_output,omitempty"`
+
+	// PostProcess is a shell command the result is piped through; its
+	// output becomes the result, e.g. "jq '.[] | .name'".
+	PostProcess string `json:"post_process,omitempty"`
+
+	// Prepend and Append wrap the result in static text; tags are filled in.
+	Prepend string `json:"prepend,omitempty"`
+	Append  string `json:"append,omitempty"`
+
+	// SaveTo also writes the result to this file; tags and ~ are expanded.
+	SaveTo string `json:"save_to,omitempty"`
+
+	// FallbackPrompt is tried once when Prompt fails or returns nothing.
+	FallbackPrompt string `json:"fallback_prompt,omitempty"`
+
+	// DependsOn lists steps that must finish first even though their
+	// results aren't used in a {{tag}}.
+	DependsOn []string `json:"depends_on,omitempty"`
+
+	// MaxRetries runs a failed step again up to this many times. RetryOn
+	// limits retries to errors matching one of its regexps.
+	MaxRetries int      `json:"max_retries,omitempty"`
+	RetryOn    []string `json:"retry_on,omitempty"`
+
+	// Type selects how the step runs: "" or "text" prompts the AI,
+	// "document" extracts text from Filename, "json_diff" compares SourceA
+	// with SourceB and "metrics" measures Source.
+	Type        string `json:"type,omitempty"`
+	Filename    string `json:"filename,omitempty"`
+	ExtractMode string `json:"extract_mode,omitempty"`
+	PageRange   string `json:"page_range,omitempty"`
+
+	// SourceA and SourceB are the JSON documents a "json_diff" step
+	// compares, usually tags like "{{before}}".
+	SourceA string `json:"source_a,omitempty"`
+	SourceB string `json:"source_b,omitempty"`
+
+	// Source is the text a "metrics" step measures, e.g. "{{draft}}".
+	Source string `json:"source,omitempty"`
+
+	// ModelConfig holds Gemini generation settings such as temperature,
+	// stop_sequences or safety_settings, merged over the flow's.
+	ModelConfig map[string]interface{} `json:"model_config,omitempty"`
+
+	// ExpectImage asks the model for an image and stores the first one as a
+	// data URL ("data:image/png;base64,...") instead of the text answer.
+	ExpectImage bool `json:"expect_image,omitempty"`
 }
 
 type Config struct {
-	Model, SystemPrompt string
-	Steps               []Step
+	Model        string `json:"model"`
+	SystemPrompt string `json:"system_prompt,omitempty"`
+	Steps        []Step `json:"steps"`
+
+	// Schedule is a cron expression like "0 9 * * *" used by `fast daemon`.
+	Schedule string `json:"schedule,omitempty"`
+
+	// DepTimeout is how long a step may wait for its dependencies, e.g. "10m".
+	DepTimeout string `json:"dep_timeout,omitempty"`
+
+	// TrimWhitespace strips leading and trailing whitespace from step
+	// results. Unset means true.
+	TrimWhitespace *bool `json:"trim_whitespace,omitempty"`
+
+	// NotifyOnComplete and NotifyOnFailure send a desktop notification when
+	// the flow finishes; unset falls back to config.json.
+	NotifyOnComplete *bool `json:"notify_on_complete,omitempty"`
+	NotifyOnFailure  *bool `json:"notify_on_failure,omitempty"`
+
+	// ModelConfig holds the generation settings for every step; see
+	// Step.ModelConfig.
+	ModelConfig map[string]interface{} `json:"model_config,omitempty"`
+}
+
+// UnmarshalJSON also reads the keys flows used before the fields had JSON
+// tags, when field names were matched case-insensitively: "systemPrompt"
+// for system_prompt and a step's "TabID" for tab_id. The new keys win.
+func (c *Config) UnmarshalJSON(data []byte) error {
+	type config Config
+	if err := json.Unmarshal(data, (*config)(c)); err != nil {
+		return err
+	}
+	var legacy struct {
+		SystemPrompt string `json:"systemPrompt"`
+		Steps        []struct {
+			TabID string `json:"TabID"`
+		} `json:"steps"`
+	}
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+	if c.SystemPrompt == "" {
+		c.SystemPrompt = legacy.SystemPrompt
+	}
+	for i := range c.Steps {
+		if c.Steps[i].TabID == "" && i < len(legacy.Steps) {
+			c.Steps[i].TabID = legacy.Steps[i].TabID
+		}
+	}
+	return nil
+}
+
+const defaultDepTimeout = 10 * time.Minute
+
+// depTimeout returns the dependency timeout, defaulting to 10 minutes.
+func (c Config) depTimeout() (time.Duration, error) {
+	if c.DepTimeout == "" {
+		return defaultDepTimeout, nil
+	}
+	d, err := time.ParseDuration(c.DepTimeout)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid dep_timeout %q", c.DepTimeout)
+	}
+	return d, nil
+}
+
+// trimWhitespace reports whether the result of s should be trimmed; the
+// step's setting wins over the flow's.
+func (c Config) trimWhitespace(s Step) bool {
+	switch {
+	case s.TrimWhitespace != nil:
+		return *s.TrimWhitespace
+	case c.TrimWhitespace != nil:
+		return *c.TrimWhitespace
+	}
+	return true
 }
 
 // --- Main Logic ---
 
-var (
-	results   = make(map[string]string)
-	userInput string
-	mu        sync.Mutex
-)
+// FlowRun holds the state of one flow execution, so several flows can run
+// side by side without sharing results.
+type FlowRun struct {
+	mu       sync.Mutex
+	results  map[string]string
+	stepLogs map[string]StepLog
+	input    string
+	mocks    map[string]string // from --mock-step, kept across resets
+	flowName string            // fills {{flow_name}}
+
+	// fileResults maps streaming_to_file steps to the file holding their
+	// result; only these results are read from disk.
+	fileResults map[string]string
+
+	// streamLog receives step events and tokens (--stream-log); nil is off.
+	streamLog *StreamLog
+
+	// trace logs every substitution of fillTags (--trace); nil is off.
+	trace *log.Logger
+
+	// ParallelLimit caps how many steps run at once; 0 means no limit.
+	ParallelLimit int
+}
+
+func newFlowRun(input string) *FlowRun {
+	return &FlowRun{
+		results:       make(map[string]string),
+		stepLogs:      make(map[string]StepLog),
+		fileResults:   make(map[string]string),
+		input:         input,
+		ParallelLimit: globalConfig.ParallelLimit,
+	}
+}
+
+// reset clears results before the flow runs again (e.g. in watch mode).
+func (r *FlowRun) reset(input string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results = make(map[string]string)
+	r.stepLogs = make(map[string]StepLog)
+	r.clearFileResults()
+	r.input = input
+}
+
+// Results returns a copy of all step results collected so far, with the
+// files of streaming_to_file steps read in.
+func (r *FlowRun) Results() map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make(map[string]string, len(r.results))
+	for k := range r.results {
+		out[k] = r.resultText(k)
+	}
+	return out
+}
 
 func main() {
 	if len(os.Args) < 2 {
-		fmt.Println("Usage: fast <name> [input]")
+		fmt.Println("Usage: fast <name> [input] [--input-file <path>] [--output <file>] [--watch]")
+		fmt.Println("       fast run <name> [<name>...]")
+		fmt.Println("       fast edit <name>")
+		fmt.Println("       fast serve [--port 8080]")
+		fmt.Println("       fast daemon [--list] [--once <name>]")
+		fmt.Println("       fast version | fast upgrade")
+		fmt.Println("       fast diff <log1> <log2> [--format json]")
+		fmt.Println("       fast logs [--clean]")
+		fmt.Println("       fast init [--global]")
+		fmt.Println("       fast doctor")
 		listFlows()
 		return
 	}
 
-	flowName := os.Args[1]
-	if len(os.Args) > 2 {
-		userInput = strings.Join(os.Args[2:], " ")
+	// Runs load settings after --env-file, which may set FAST_FLOWS_DIR or
+	// NO_COLOR; subcommands have no such flag
+	if slices.Contains(subcommands, os.Args[1]) {
+		loadSettings()
 	}
 
-	// 1. Try local ./flows folder
-	path := fmt.Sprintf("./flows/%s.json", flowName)
-	data, err := os.ReadFile(path)
-	
-	// 2. Try global ~/fast-flows/flows folder
+	switch os.Args[1] {
+	case "init":
+		if err := runInitCommand(os.Args[2:]); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		return
+	case "logs":
+		if err := runLogsCommand(os.Args[2:]); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		return
+	case "diff":
+		if err := runDiffCommand(os.Args[2:]); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		return
+	case "edit":
+		if err := runEditCommand(os.Args[2:]); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		return
+	case "version":
+		if err := runVersionCommand(); err != nil {
+			fmt.Printf("❌ %v\n", err)
+		}
+		return
+	case "upgrade":
+		if err := runUpgradeCommand(); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		return
+	case "daemon":
+		if err := runDaemonCommand(os.Args[2:]); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		return
+	case "serve":
+		if err := runServeCommand(os.Args[2:]); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		return
+	case "doctor":
+		if err := runDoctorCommand(); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		return
+	case "templates":
+		if err := runTemplatesCommand(os.Args[2:]); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// exit flushes the --profile before leaving with a status code
+	exit := os.Exit
+	opts, err := parseRunArgs(os.Args[1:])
+	// fail reports an error that stops fast before the flow runs. Without
+	// the TUI it goes to stderr with exit code 1, so scripts and pipes don't
+	// take it for a result; --json-output gets it as JSON on stdout.
+	fail := func(err error) {
+		if opts.JSONOutput {
+			printJSONOutput(newJSONOutput(opts.FlowName, nil, "", err, 0))
+			exit(1)
+		}
+		if opts.NoTUI || opts.Quiet || opts.JSONOutput {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			exit(1)
+		}
+		fmt.Printf("❌ %v\n", err)
+	}
 	if err != nil {
-		home, _ := os.UserHomeDir()
-		path = filepath.Join(home, "fast-flows", "flows", flowName+".json")
-		data, err = os.ReadFile(path)
+		fail(err)
+		return
 	}
 
+	input, err := resolveInput(&opts)
 	if err != nil {
-		fmt.Printf("❌ Flow '%s' not found.\n", flowName)
-		listFlows()
+		fail(err)
 		return
 	}
 
-	var conf Config
-	if err := json.Unmarshal(data, &conf); err != nil {
-		fmt.Printf("❌ Failed to parse flow configuration: %v\n", err)
+	themeName := opts.Theme
+	if themeName == "" {
+		themeName = globalConfig.Theme
+	}
+	theme, err := themeByName(themeName)
+	if err != nil {
+		fail(err)
 		return
 	}
 
-	if len(conf.Steps) == 0 {
-		fmt.Println("❌ Flow configuration has no steps.")
+	if opts.Profile != "" {
+		names := opts.FlowNames
+		if len(names) == 0 {
+			names = []string{opts.FlowName}
+		}
+		stop, err := startProfile(opts.Profile, names)
+		if err != nil {
+			fail(err)
+			return
+		}
+		defer stop()
+		exit = func(code int) {
+			stop()
+			os.Exit(code)
+		}
+	}
+
+	if len(opts.FlowNames) > 1 {
+		if err := runMultipleFlows(opts, input, theme); err != nil {
+			fmt.Printf("❌ %v\n", err)
+			exit(exitCode(err))
+		}
+		return
+	}
+
+	flowName := opts.FlowName
+	path, conf, err := loadFlow(flowName, opts)
+	if err != nil {
+		fail(err)
+		if errors.Is(err, os.ErrNotExist) {
+			listFlows()
+		}
+		return
+	}
+
+	if opts.Resume != "" {
+		changed, err := resumeResults(opts.Resume, flowName, conf, opts.Set)
+		if err != nil {
+			fail(err)
+			return
+		}
+		if changed {
+			fmt.Fprintln(os.Stderr, "⚠️  The flow has changed since this log was written; reused results may be stale.")
+		}
+	}
+
+	run := newFlowRun(input)
+	run.flowName = flowName
+	run.pin(opts.Set)
+	run.mock(opts.Mock)
+	if opts.ParallelLimit > 0 {
+		run.ParallelLimit = opts.ParallelLimit
+	}
+	defer run.removeFileResults()
+	exitAfterProfile := exit
+	exit = func(code int) {
+		run.removeFileResults()
+		exitAfterProfile(code)
+	}
+	if run.streamLog, err = openConfiguredStreamLog(opts.StreamLog); err != nil {
+		fail(err)
 		return
 	}
+	defer run.streamLog.Close()
+
+	if opts.Trace {
+		toFile := !opts.NoTUI && opts.Step == "" && !opts.Explain && !opts.StepOrder
+		trace, f, err := openTrace(toFile, time.Now())
+		if err != nil {
+			fail(err)
+			return
+		}
+		run.trace = trace
+		if f != nil {
+			defer func() {
+				f.Close()
+				fmt.Fprintf(os.Stderr, "🔍 Trace written to %s\n", f.Name())
+			}()
+		}
+	}
+
+	if opts.StepOrder {
+		order, err := formatStepOrder(conf.Steps)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			exit(1)
+		}
+		fmt.Print(order)
+		return
+	}
+
+	if opts.Explain {
+		fmt.Print(explainFlow(run, conf, flowName))
+		return
+	}
+
+	if opts.Step != "" {
+		if err := runSingleStep(run, conf, opts.Step); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			exit(1)
+		}
+		return
+	}
+
+	if opts.JSONOutput {
+		quietLogs = true
+		if err := runWithJSONOutput(run, conf, flowName, opts); err != nil {
+			exit(exitCode(err))
+		}
+		return
+	}
+
+	if opts.NoTUI {
+		quietLogs = opts.Quiet
+		if err := runWithoutTUI(run, conf, flowName, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			exit(exitCode(err))
+		}
+		return
+	}
+
+	if opts.Output != "" {
+		opts.Output = expandHome(opts.Output)
+		if _, err := os.Stat(opts.Output); err == nil && !opts.Force && !confirmOverwrite(opts.Output) {
+			fmt.Println("❌ Aborted. Use --force to overwrite.")
+			return
+		}
+	}
 
 	// Get clipboard content for UI
 	clipboardContent := ""
@@ -82,26 +503,302 @@ func main() {
 	clipboardContent = string(out)
 
 	// Initialize TUI
-	p := tea.NewProgram(InitialModel(conf, flowName, clipboardContent, userInput))
+	model := InitialModel(conf, flowName, clipboardContent, input, theme)
+	model.Run = run
+	model.OutputFile = opts.Output
+	model.NoPreview = opts.NoPreview
+
+	changed := make(chan struct{}, 1)
+	if opts.Watch {
+		model.WatchFiles = []string{path}
+		if override := envOverridePath(path); override != "" {
+			if _, err := os.Stat(override); err == nil {
+				model.WatchFiles = append(model.WatchFiles, override)
+			}
+		}
+		if opts.InputFile != "" && opts.InputFile != "-" {
+			model.WatchFiles = append(model.WatchFiles, expandHome(opts.InputFile))
+		}
+		go func() {
+			if err := watchFiles(model.WatchFiles, changed); err != nil {
+				fmt.Fprintf(os.Stderr, "❌ Watch error: %v\n", err)
+			}
+		}()
+	}
+
+	// Pressing `a` in the TUI closes model.Done, which cancels every running step
+	ctx, cancel := flowContext(opts)
+	defer cancel()
+	if opts.Timeout > 0 {
+		model.Deadline = time.Now().Add(opts.Timeout)
+	}
+	model.Done = make(chan struct{})
+	go func() {
+		<-model.Done
+		cancel()
+	}()
+
+	var programOpts []tea.ProgramOption
+	stopRecording := func() error { return nil }
+	if opts.Record != "" {
+		opt, stop, err := startRecording(opts.Record, "fast "+flowName)
+		if err != nil {
+			fmt.Printf("❌ %v\n", err)
+			return
+		}
+		programOpts = append(programOpts, opt)
+		stopRecording = stop
+	}
+	p := tea.NewProgram(model, programOpts...)
+
+	// The last run is exported once the TUI is gone, so a slow export
+	// (wkhtmltopdf) doesn't keep the TUI waiting
+	var lastLogMu sync.Mutex
+	var lastLog *SessionLog
+	saveLog := func() {
+		logInput := run.input
+		if opts.InputFile != "" {
+			logInput = ""
+		}
+		log := saveSessionLog(flowName, logInput, opts.InputFile, clipboardContent, conf, run.Results(), opts.Set.Keys(), run.collectStepLogs(conf))
+		lastLogMu.Lock()
+		lastLog = &log
+		lastLogMu.Unlock()
+	}
 
 	// Run flow in background
 	go func() {
-		runFlow(conf, p)
-		finalResult := results[conf.Steps[len(conf.Steps)-1].ID]
-		copyToClipboard(finalResult)
-		saveSessionLog(flowName, userInput, clipboardContent, conf, results)
-		p.Send(FlowFinishedMsg{Result: finalResult})
+		for {
+			if err := runFlow(ctx, run, conf, p); err != nil {
+				// Keep whatever finished before the failure, abort or timeout
+				saveLog()
+				notifyFlowDone(flowName, conf, "", err)
+				if errors.Is(err, context.DeadlineExceeded) {
+					p.Send(FlowTimeoutMsg{})
+				} else {
+					p.Send(FlowFinishedMsg{})
+				}
+				return
+			}
+			finalResult := run.GetResult(conf.Steps[len(conf.Steps)-1].ID)
+			copyToClipboard(finalResult)
+			formatted, outputErr := formatOutput(finalResult, opts.Format)
+			if outputErr == nil && opts.Output != "" {
+				outputErr = writeOutput(opts.Output, formatted)
+			}
+			saveLog()
+			notifyFlowDone(flowName, conf, finalResult, nil)
+			p.Send(FlowFinishedMsg{Result: finalResult, OutputErr: outputErr})
+
+			if !opts.Watch {
+				return
+			}
+
+			// Wait for a change that leaves us with a runnable flow
+			for {
+				<-changed
+				next, err := reloadFlow(path, opts)
+				if err == nil && opts.InputFile != "" && opts.InputFile != "-" {
+					input, err = readInputFile(opts.InputFile, opts.InputFormat)
+				}
+				if err != nil {
+					p.Send(WatchErrorMsg{Err: err})
+					continue
+				}
+				conf = next
+				break
+			}
+
+			out, _ := exec.Command("pbpaste").Output()
+			clipboardContent = string(out)
+
+			run.reset(input)
+			run.pin(opts.Set)
+
+			p.Send(FlowRestartMsg{Config: conf, Clipboard: clipboardContent, Input: input})
+		}
 	}()
 
-	if _, err := p.Run(); err != nil {
+	holdLogNoticesDuringTUI()
+	final, err := p.Run()
+	flushLogNotices()
+	if recordErr := stopRecording(); recordErr != nil {
+		fmt.Fprintf(os.Stderr, "❌ %v\n", recordErr)
+	}
+	if err != nil {
 		fmt.Printf("Alas, there's been an error: %v", err)
-		os.Exit(1)
+		exit(1)
+	}
+	lastLogMu.Lock()
+	exportLog := lastLog
+	lastLogMu.Unlock()
+	if opts.Export != "" && exportLog != nil {
+		if report, err := exportRun(*exportLog, opts.Export); err != nil {
+			fmt.Fprintf(os.Stderr, "❌ Export failed: %v\n", err)
+		} else {
+			fmt.Fprintf(os.Stderr, "📄 Report written to %s\n", report)
+		}
+	}
+	if fm, ok := final.(FlowModel); ok && fm.TimedOut {
+		exit(2)
+	}
+	if fm, ok := final.(FlowModel); ok && fm.Err != nil {
+		exit(1)
+	}
+}
+
+// subcommands are the commands main handles before parsing run flags.
+var subcommands = []string{"init", "logs", "diff", "edit", "version", "upgrade", "daemon", "serve", "doctor", "templates"}
+
+// loadSettings applies NO_COLOR and reads config.json from the fast-flows
+// directory into globalConfig.
+func loadSettings() {
+	applyNoColor()
+	var err error
+	globalConfig, err = loadGlobalConfig()
+	if err != nil {
+		fmt.Printf("❌ %v\n", err)
+	}
+}
+
+// parseRunArgs parses the flags of a run and loads --env-file before the
+// settings, so the file can set FAST_FLOWS_DIR or NO_COLOR.
+func parseRunArgs(args []string) (Options, error) {
+	opts, err := parseArgs(args)
+	if err != nil {
+		return opts, err
+	}
+	if opts.EnvFile != "" {
+		if err := loadEnvFile(opts.EnvFile); err != nil {
+			return opts, err
+		}
+	}
+	loadSettings()
+	return opts, nil
+}
+
+// flowContext returns the context a flow runs in, bounded by --timeout.
+func flowContext(opts Options) (context.Context, context.CancelFunc) {
+	if opts.Timeout > 0 {
+		return context.WithTimeout(context.Background(), opts.Timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// loadFlow finds and parses a flow, applying the --only-tags filter.
+func loadFlow(flowName string, opts Options) (string, Config, error) {
+	path, data, err := findFlow(flowName)
+	if err != nil {
+		return path, Config{}, fmt.Errorf("Flow '%s' not found: %w", flowName, os.ErrNotExist)
 	}
+	if data, err = applyEnvOverride(path, data); err != nil {
+		return path, Config{}, err
+	}
+	conf, err := parseFlow(data)
+	if err != nil {
+		return path, conf, err
+	}
+	if opts.OnlyTags != "" {
+		if conf, err = filterByTags(conf, opts.OnlyTags); err != nil {
+			return path, conf, err
+		}
+	}
+	return path, conf, nil
+}
+
+// findFlow looks for a flow in the local ./flows folder first, then in the
+// global flows folder (~/fast-flows/flows or $FAST_FLOWS_DIR/flows).
+func findFlow(flowName string) (string, []byte, error) {
+	// 1. Try local ./flows folder
+	path := fmt.Sprintf("./flows/%s.json", flowName)
+	data, err := os.ReadFile(path)
+
+	// 2. Try the global flows folder
+	if err != nil {
+		path = filepath.Join(resolveFastFlowsDir(), "flows", flowName+".json")
+		data, err = os.ReadFile(path)
+	}
+	return path, data, err
+}
+
+func parseFlow(data []byte) (Config, error) {
+	var conf Config
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return conf, fmt.Errorf("Failed to parse flow configuration: %v", err)
+	}
+	if err := applyProjectConfig(&conf); err != nil {
+		return conf, err
+	}
+
+	if len(conf.Steps) == 0 {
+		return conf, errors.New("Flow configuration has no steps.")
+	}
+	if _, err := conf.depTimeout(); err != nil {
+		return conf, err
+	}
+	if err := validateSafetySettings(conf.ModelConfig); err != nil {
+		return conf, err
+	}
+	for _, s := range conf.Steps {
+		if err := validateChunking(s); err != nil {
+			return conf, err
+		}
+		if err := validateOutputFormat(s); err != nil {
+			return conf, err
+		}
+		if err := validateTruncation(s); err != nil {
+			return conf, err
+		}
+		if err := validatePostProcess(s); err != nil {
+			return conf, err
+		}
+		if err := validateRetry(s); err != nil {
+			return conf, err
+		}
+		if err := validateJSONDiff(s); err != nil {
+			return conf, err
+		}
+		if err := validateMetrics(s); err != nil {
+			return conf, err
+		}
+		if err := validateModelConfig(s); err != nil {
+			return conf, err
+		}
+		if err := validateExpectImage(s); err != nil {
+			return conf, err
+		}
+	}
+	if err := validateSplitOutput(conf.Steps); err != nil {
+		return conf, err
+	}
+	if err := validateDependsOn(conf.Steps); err != nil {
+		return conf, err
+	}
+	return conf, nil
+}
+
+// reloadFlow re-reads the flow file after a change in watch mode.
+func reloadFlow(path string, opts Options) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	if data, err = applyEnvOverride(path, data); err != nil {
+		return Config{}, err
+	}
+	conf, err := parseFlow(data)
+	if err != nil {
+		return conf, err
+	}
+	if opts.OnlyTags != "" {
+		return filterByTags(conf, opts.OnlyTags)
+	}
+	return conf, nil
 }
 
 func listFlows() {
 	fmt.Println("\nAvailable flows:")
-	
+
 	// Check local
 	files, _ := filepath.Glob("./flows/*.json")
 	for _, f := range files {
@@ -109,56 +806,451 @@ func listFlows() {
 	}
 
 	// Check global
-	home, _ := os.UserHomeDir()
-	globalFiles, _ := filepath.Glob(filepath.Join(home, "fast-flows", "flows", "*.json"))
+	globalFiles, _ := filepath.Glob(filepath.Join(resolveFastFlowsDir(), "flows", "*.json"))
 	for _, f := range globalFiles {
 		fmt.Printf("  - %s\n", strings.TrimSuffix(filepath.Base(f), ".json"))
 	}
 	fmt.Println()
 }
 
-func runFlow(conf Config, p *tea.Program) {
-	var wg sync.WaitGroup
+// runFlow runs every step, each as soon as its dependencies are ready. It
+// returns the first step failure, or ctx.Err() if the flow was cancelled.
+func runFlow(ctx context.Context, run *FlowRun, conf Config, p msgSender) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	// depsExpired is closed once steps have waited dep_timeout for their dependencies
+	depTimeout, err := conf.depTimeout()
+	if err != nil {
+		return err
+	}
+	depsExpired := make(chan struct{})
+	timer := time.AfterFunc(depTimeout, func() { close(depsExpired) })
+	defer timer.Stop()
+
+	// Each running step holds a slot, so at most ParallelLimit call the AI at once
+	var slots chan struct{}
+	if run.ParallelLimit > 0 {
+		slots = make(chan struct{}, run.ParallelLimit)
+	}
+
 	for _, step := range conf.Steps {
 		wg.Add(1)
 		go func(s Step) {
 			defer wg.Done()
-			for !depsReady(s.Prompt) {
-				time.Sleep(100 * time.Millisecond)
+			stepFailed := func(err *StepError) {
+				run.streamLog.Event("error", s.ID, err.Error())
+				if p != nil {
+					p.Send(StepFailedMsg{ID: s.ID, Err: err})
+				} else {
+					fmt.Printf("❌ %v\n", err)
+				}
+				fail(err)
+			}
+
+			if res := run.GetResult(s.ID); res != "" {
+				// Result pinned with --set, nothing to run
+				parts, _ := splitOutput(s, res)
+				for k, v := range parts {
+					if run.GetResult(k) == "" {
+						run.setResult(k, v)
+					}
+				}
+				if p != nil {
+					p.Send(StepDoneMsg{ID: s.ID, Pinned: true, Preview: stepPreview(res)})
+				}
+				return
+			}
+
+			for !run.depsReady(s) {
+				select {
+				case <-ctx.Done():
+					return
+				case <-depsExpired:
+					stepFailed(&StepError{
+						StepID:   s.ID,
+						StepType: stepType(s),
+						Cause:    fmt.Errorf("dependency timeout after %s waiting for %s", depTimeout, strings.Join(run.pendingDeps(s), ", ")),
+						Duration: depTimeout,
+					})
+					return
+				case <-time.After(100 * time.Millisecond):
+				}
 			} // Automatic Parallel Detection
 
+			if slots != nil {
+				select {
+				case slots <- struct{}{}:
+					defer func() { <-slots }()
+				case <-ctx.Done():
+					return
+				}
+			}
+
+			run.streamLog.Event("start", s.ID, "")
 			if p != nil {
 				p.Send(StepStartedMsg{ID: s.ID})
 			} else {
 				fmt.Printf("Running %s...\n", s.ID)
 			}
 
-			model := conf.Model
-			if s.Model != "" {
-				model = s.Model
+			progress := func(text string) {
+				if p != nil {
+					p.Send(StepProgressMsg{ID: s.ID, Text: text})
+				}
 			}
+			res, log, err := runStepWithRetries(ctx, run, conf, s, progress)
+			run.recordStep(log)
 
-			res := callGemini(model, conf.SystemPrompt, fillTags(s.Prompt))
-			if res == "" {
-				err := fmt.Errorf("step '%s' failed", s.ID)
-				if p != nil {
-					p.Send(StepFailedMsg{ID: s.ID, Err: err})
-				} else {
-					fmt.Printf("❌ %v\n", err)
+			if ctx.Err() != nil {
+				// Aborted while the step was in flight
+				return
+			}
+
+			if err != nil {
+				var se *StepError
+				if !errors.As(err, &se) {
+					se = &StepError{StepID: s.ID, StepType: stepType(s), Cause: err, Attempt: log.Retries + 1, Duration: log.Duration}
 				}
-				os.Exit(1)
+				stepFailed(se)
+				return
 			}
 
-			mu.Lock()
-			results[s.ID] = res
-			mu.Unlock()
+			parts, err := splitOutput(s, res)
+			if err != nil {
+				stepFailed(&StepError{StepID: s.ID, StepType: stepType(s), Cause: err, Attempt: 1, Duration: log.Duration})
+				return
+			}
+			run.setResult(s.ID, res)
+			for k, v := range parts {
+				run.setResult(k, v)
+			}
+			run.streamLog.Event("done", s.ID, "")
 
 			if p != nil {
-				p.Send(StepDoneMsg{ID: s.ID})
+				if log.TruncatedFrom > 0 {
+					p.Send(StepTruncatedMsg{ID: s.ID, Length: log.TruncatedFrom})
+				}
+				p.Send(StepDoneMsg{ID: s.ID, Warnings: log.Warnings, Preview: stepPreview(res)})
+			} else {
+				for _, w := range log.Warnings {
+					fmt.Fprintf(os.Stderr, "⚠️  %s: %s\n", s.ID, w)
+				}
 			}
 		}(step)
 	}
 	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
+
+// msgSender is anything that can deliver TUI messages, normally *tea.Program.
+type msgSender interface {
+	Send(msg tea.Msg)
+}
+
+// pin pre-populates results from --set so those steps are skipped.
+func (r *FlowRun) pin(pinned map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for k, v := range pinned {
+		r.results[k] = v
+	}
+}
+
+// mock makes the given steps return these results instead of running.
+func (r *FlowRun) mock(mocks map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mocks = mocks
+}
+
+func (r *FlowRun) mockResult(id string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res, ok := r.mocks[id]
+	return res, ok
+}
+
+func (r *FlowRun) setResult(id, res string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results[id] = res
+}
+
+func (r *FlowRun) recordStep(l StepLog) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stepLogs[l.ID] = l
+}
+
+// discardSender drops TUI messages, for runs that must stay silent.
+type discardSender struct{}
+
+func (discardSender) Send(tea.Msg) {}
+
+// runWithoutTUI implements --no-tui: nothing but the final result is written
+// to stdout, so flows can be chained in shell pipelines.
+func runWithoutTUI(run *FlowRun, conf Config, flowName string, opts Options) error {
+	formatted, err := runFlowWithoutTUI(run, conf, flowName, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Println(formatted)
+	return nil
+}
+
+// runFlowWithoutTUI runs a flow silently, saves its session log and returns
+// the final result after --format.
+func runFlowWithoutTUI(run *FlowRun, conf Config, flowName string, opts Options) (string, error) {
+	if opts.Output != "" {
+		// There's no one to ask in a pipeline, so only overwrite with --force
+		opts.Output = expandHome(opts.Output)
+		if _, err := os.Stat(opts.Output); err == nil && !opts.Force {
+			return "", fmt.Errorf("%s already exists. Use --force to overwrite.", opts.Output)
+		}
+	}
+
+	out, _ := exec.Command("pbpaste").Output()
+	clipboardContent := string(out)
+
+	ctx, cancel := flowContext(opts)
+	defer cancel()
+	err := runFlow(ctx, run, conf, discardSender{})
+	logInput := run.input
+	if opts.InputFile != "" {
+		logInput = ""
+	}
+	log := saveSessionLog(flowName, logInput, opts.InputFile, clipboardContent, conf, run.Results(), opts.Set.Keys(), run.collectStepLogs(conf))
+	notifyFlowDone(flowName, conf, run.GetResult(conf.Steps[len(conf.Steps)-1].ID), err)
+	if opts.Export != "" {
+		path, exportErr := exportRun(log, opts.Export)
+		switch {
+		case exportErr != nil && err == nil:
+			return "", fmt.Errorf("export failed: %w", exportErr)
+		case exportErr != nil:
+			fmt.Fprintf(os.Stderr, "❌ Export failed: %v\n", exportErr)
+		case !opts.Quiet:
+			fmt.Fprintf(os.Stderr, "📄 Report written to %s\n", path)
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "", fmt.Errorf("%w after %s (partial results saved to the session log)", errFlowTimeout, opts.Timeout)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	finalResult := run.GetResult(conf.Steps[len(conf.Steps)-1].ID)
+	copyToClipboard(finalResult)
+	formatted, err := formatOutput(finalResult, opts.Format)
+	if err != nil {
+		return "", err
+	}
+	if opts.Output != "" {
+		if err := writeOutput(opts.Output, formatted); err != nil {
+			return "", err
+		}
+	}
+	return formatted, nil
+}
+
+// askModel sends the prompt of a text step the way the step asks for it:
+// in chunks, streamed to a file or as a single call.
+func askModel(ctx context.Context, run *FlowRun, s Step, model, sys, prompt string, images []InlineImage, progress func(string)) (string, int, error) {
+	if s.ChunkSize > 0 {
+		return runChunked(ctx, run, model, sys, prompt, s, images, progress)
+	}
+	if err := waitForGemini(ctx, progress); err != nil {
+		return "", 0, err
+	}
+	if s.StreamingToFile {
+		path, tokens, err := streamToFile(ctx, model, sys, prompt, images, s.ID, run.streamLog.tokens(s.ID))
+		if err != nil {
+			return "", tokens, err
+		}
+		return run.addFileResult(s.ID, path), tokens, nil
+	}
+	res, tokens, err := callGemini(ctx, model, sys, prompt, images)
+	if res != "" {
+		// Not streamed, so the whole answer is one token event
+		run.streamLog.Event("token", s.ID, res)
+	}
+	return res, tokens, err
+}
+
+// runStep executes one step according to its type and returns its result
+// along with the log entry describing the run. Failures are *StepError.
+// progress reports what a long-running step is doing, e.g. which chunk it
+// is on.
+func runStep(ctx context.Context, run *FlowRun, conf Config, s Step, progress func(string)) (string, StepLog, error) {
+	log := StepLog{ID: s.ID, StartTime: time.Now()}
+	if res, ok := run.mockResult(s.ID); ok {
+		log.EndTime = time.Now()
+		log.Mocked = true
+		return res, log, nil
+	}
+	var res string
+	var err error
+
+	switch s.Type {
+	case "", "text":
+		var images []InlineImage
+		if images, err = run.stepImages(s); err != nil {
+			break
+		}
+		log.Model = effectiveModel(conf, s)
+		sys := systemPrompt(conf, s)
+		log.Images = imageHashes(images)
+		modelConfig := mergeModelConfig(conf.ModelConfig, s.ModelConfig)
+		ctx := ctx
+		if s.ExpectImage {
+			modelConfig = mergeModelConfig(imageModelConfig, modelConfig)
+			ctx = withExpectImage(ctx)
+		}
+		ctx = withModelConfig(ctx, modelConfig)
+		res, log.TokensUsed, err = askModel(ctx, run, s, log.Model, sys, run.fillStepTags(s.Prompt, s, log.Model), images, progress)
+		if (err != nil || res == "") && ctx.Err() == nil && s.FallbackPrompt != "" {
+			progress("Using fallback prompt…")
+			var used int
+			res, used, err = askModel(ctx, run, s, log.Model, sys, run.fillStepTags(s.FallbackPrompt, s, log.Model), images, progress)
+			log.TokensUsed += used
+			log.Fallback = true
+			progress("")
+		}
+	case "document":
+		filename := run.fillStepTags(s.Filename, s, effectiveModel(conf, s))
+		res, err = extractDocument(expandHome(filename), s.ExtractMode, s.PageRange)
+	case "json_diff":
+		model := effectiveModel(conf, s)
+		res, err = runJSONDiff(run.fillStepTags(s.SourceA, s, model), run.fillStepTags(s.SourceB, s, model), s.OutputFormat)
+	case "metrics":
+		res, err = runMetrics(run.fillStepTags(s.Source, s, effectiveModel(conf, s)))
+	default:
+		err = fmt.Errorf("unknown step type %q", s.Type)
+	}
+	if err == nil && res != "" && s.PostProcess != "" {
+		res, err = postProcess(ctx, s.PostProcess, res)
+	}
+
+	log.EndTime = time.Now()
+	log.Duration = log.EndTime.Sub(log.StartTime)
+	if conf.trimWhitespace(s) && !s.StreamingToFile {
+		res = strings.TrimSpace(res)
+	}
+	if err == nil && res != "" {
+		var warning string
+		if res, warning, err = applyOutputFormat(s, res); warning != "" {
+			log.Warnings = append(log.Warnings, warning)
+		}
+		if s.OutputMaxLength > 0 {
+			length := utf8.RuneCountInString(res)
+			var cut bool
+			if res, cut = truncateResult(res, s.OutputMaxLength, s.TruncateFrom); cut {
+				log.TruncatedFrom = length
+			}
+		}
+		if s.Prepend != "" || s.Append != "" {
+			model := effectiveModel(conf, s)
+			res = run.fillStepTags(s.Prepend, s, model) + res + run.fillStepTags(s.Append, s, model)
+		}
+		if s.SaveTo != "" {
+			// A failed save shouldn't throw away a good result
+			path := expandHome(run.fillStepTags(s.SaveTo, s, effectiveModel(conf, s)))
+			content := run.resolveStepResult(s.ID, res)
+			if img, ok := imageBytes(content); ok {
+				content = string(img)
+			}
+			if werr := writeOutput(path, content); werr != nil {
+				log.Warnings = append(log.Warnings, fmt.Sprintf("save_to failed: %v", werr))
+			}
+		}
+	}
+	if err == nil && res == "" {
+		err = errNoResult
+	}
+	if err != nil {
+		return "", log, &StepError{
+			StepID:   s.ID,
+			StepType: stepType(s),
+			Cause:    err,
+			Attempt:  1,
+			Duration: log.Duration,
+		}
+	}
+	return res, log, nil
+}
+
+// runSingleStep executes one step without the TUI and prints its result.
+// Step tags without a value (see --set) are substituted with empty strings.
+func runSingleStep(run *FlowRun, conf Config, id string) error {
+	var step *Step
+	for i := range conf.Steps {
+		if conf.Steps[i].ID == id {
+			step = &conf.Steps[i]
+			break
+		}
+	}
+	if step == nil {
+		return fmt.Errorf("step '%s' not found in flow", id)
+	}
+
+	run.mu.Lock()
+	for _, dep := range stepDependencies(*step) {
+		if _, ok := run.results[dep]; !ok {
+			run.results[dep] = ""
+		}
+	}
+	run.mu.Unlock()
+
+	res, _, err := runStep(context.Background(), run, conf, *step, func(string) {})
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(res)
+	copyToClipboard(res)
+	return nil
+}
+
+// systemPrompt returns the system prompt for a step: the flow's, preceded
+// by "You are a <persona>." when the step has a persona. Short names are
+// looked up in the personas map of config.json.
+func systemPrompt(conf Config, s Step) string {
+	if s.Persona == "" {
+		return conf.SystemPrompt
+	}
+	persona := s.Persona
+	if full, ok := globalConfig.Personas[persona]; ok {
+		persona = full
+	}
+	intro := fmt.Sprintf("You are a %s.", persona)
+	if conf.SystemPrompt == "" {
+		return intro
+	}
+	return intro + "\n\n" + conf.SystemPrompt
+}
+
+func effectiveModel(conf Config, s Step) string {
+	if s.Model != "" {
+		return s.Model
+	}
+	return conf.Model
 }
 
 func getAPIKey() string {
@@ -170,100 +1262,300 @@ func getAPIKey() string {
 	return strings.TrimSpace(string(keyData))
 }
 
-func depsReady(prompt string) bool {
-	tags := regexp.MustCompile(`{{(.*?)}}`).FindAllStringSubmatch(prompt, -1)
-	mu.Lock()
-	defer mu.Unlock()
-	for _, t := range tags {
-		if t[1] != "clipboard" && t[1] != "input" && results[t[1]] == "" {
-			return false
+// isInputTag reports whether a {{tag}} refers to an input or a built-in
+// value rather than a step, so it never has to be waited for.
+func isInputTag(tag string) bool {
+	return tag == "clipboard" || tag == "clipboard_image" || tag == "input" || isBuiltinTag(tag)
+}
+
+// tagFields returns the fields of a step that may hold {{tags}}: its prompt
+// and any fields that are filled in before the step runs.
+func tagFields(s *Step) []*string {
+	return []*string{&s.Prompt, &s.FallbackPrompt, &s.ImageFile, &s.Filename, &s.SaveTo, &s.Prepend, &s.Append, &s.SourceA, &s.SourceB, &s.Source}
+}
+
+// stepTags returns the names of all {{tags}} used by a step, in any of its
+// tagFields.
+func stepTags(s Step) []string {
+	var tags []string
+	for _, text := range tagFields(&s) {
+		for _, t := range tagPattern.FindAllStringSubmatch(*text, -1) {
+			tags = append(tags, t[1])
 		}
 	}
-	return true
+	return tags
 }
 
-func fillTags(prompt string) string {
-	mu.Lock()
-	defer mu.Unlock()
+// stepDependencies returns the IDs of the steps referenced by a step, via
+// {{tags}} first and then its depends_on.
+func stepDependencies(s Step) []string {
+	var deps []string
+	for _, t := range stepTags(s) {
+		if !isInputTag(t) {
+			deps = append(deps, tagStep(t))
+		}
+	}
+	return append(deps, s.DependsOn...)
+}
+
+// validateDependsOn checks that every depends_on entry names another step.
+func validateDependsOn(steps []Step) error {
+	ids := make(map[string]bool)
+	for _, s := range steps {
+		ids[s.ID] = true
+	}
+	for _, s := range steps {
+		for _, dep := range s.DependsOn {
+			if dep == s.ID {
+				return fmt.Errorf("step '%s' can't depend on itself", s.ID)
+			}
+			if !ids[dep] {
+				return fmt.Errorf("step '%s': depends_on refers to unknown step '%s'", s.ID, dep)
+			}
+		}
+	}
+	return nil
+}
+
+// filterByTags keeps only steps carrying one of the comma-separated tags,
+// plus every step they depend on.
+func filterByTags(conf Config, tagList string) (Config, error) {
+	wanted := make(map[string]bool)
+	for _, t := range strings.Split(tagList, ",") {
+		wanted[strings.TrimSpace(t)] = true
+	}
+
+	byID := make(map[string]Step)
+	for _, s := range conf.Steps {
+		byID[s.ID] = s
+	}
+
+	owners := splitOwners(conf.Steps)
+	keep := make(map[string]bool)
+	var include func(id string)
+	include = func(id string) {
+		s, ok := byID[id]
+		if !ok || keep[id] {
+			return
+		}
+		keep[id] = true
+		for _, dep := range flowDependencies(s, owners) {
+			include(dep)
+		}
+	}
+	for _, s := range conf.Steps {
+		for _, t := range s.Tags {
+			if wanted[t] {
+				include(s.ID)
+			}
+		}
+	}
+
+	var steps []Step
+	for _, s := range conf.Steps {
+		if keep[s.ID] {
+			steps = append(steps, s)
+		}
+	}
+	if len(steps) == 0 {
+		return conf, fmt.Errorf("no steps tagged %s", tagList)
+	}
+	conf.Steps = steps
+	return conf, nil
+}
+
+func (r *FlowRun) depsReady(s Step) bool {
+	return len(r.pendingDeps(s)) == 0
+}
+
+// pendingDeps returns the dependencies of s that have no result yet.
+func (r *FlowRun) pendingDeps(s Step) []string {
+	deps := stepDependencies(s)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var pending []string
+	for _, dep := range deps {
+		if r.results[dep] == "" {
+			pending = append(pending, dep)
+		}
+	}
+	return pending
+}
+
+func (r *FlowRun) fillTags(prompt string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	res := prompt
 	if strings.Contains(res, "{{clipboard}}") {
 		out, _ := exec.Command("pbpaste").Output()
+		before := res
 		res = strings.ReplaceAll(res, "{{clipboard}}", string(out))
+		r.traceFill(before, res, "clipboard", string(out))
 	}
 	if strings.Contains(res, "{{input}}") {
-		res = strings.ReplaceAll(res, "{{input}}", userInput)
+		before := res
+		res = strings.ReplaceAll(res, "{{input}}", r.input)
+		r.traceFill(before, res, "input", r.input)
 	}
-	for k, v := range results {
-		res = strings.ReplaceAll(res, "{{"+k+"}}", v)
+	before := res
+	res = strings.ReplaceAll(res, "{{flow_name}}", r.flowName)
+	r.traceFill(before, res, "flow_name", r.flowName)
+	// The image itself is sent as a separate part, see stepImages
+	before = res
+	res = strings.ReplaceAll(res, "{{clipboard_image}}", "")
+	r.traceFill(before, res, "clipboard_image", "")
+	for k, v := range r.results {
+		if strings.Contains(res, "{{"+k+"}}") {
+			before, v = res, r.resolve(k, v)
+			res = strings.ReplaceAll(res, "{{"+k+"}}", v)
+			r.traceFill(before, res, k, v)
+		}
+	}
+	before = res
+	res = r.fillJSONTags(res)
+	r.traceFillPattern(before, res, jsonTagPattern, r.fillJSONTags)
+	before = res
+	res = r.fillMetricTags(res)
+	r.traceFillPattern(before, res, metricTagPattern, r.fillMetricTags)
+	if r.trace != nil && res == prompt {
+		r.trace.Printf("fillTags input=%q (no tags)", prompt)
 	}
 	return res
 }
 
-var callGemini = func(model, sys, prompt string) string {
-	if os.Getenv("MOCK_FLOW") == "true" {
-		return "Mocked response for: " + prompt
+// GetResult returns the stored result of a step, or "" if it hasn't finished.
+func (r *FlowRun) GetResult(id string) string {
+	if r == nil {
+		return ""
 	}
-	apiKey := getAPIKey()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.resultText(id)
+}
+
+// requireAPIKey returns the Gemini API key for the next call, or exits with
+// setup instructions.
+func requireAPIKey() string {
+	apiKey := nextAPIKey()
 	if apiKey == "" {
-		fmt.Println("❌ No API Key found!")
+		fmt.Fprintln(os.Stderr, "❌ No API Key found!")
 		home, _ := os.UserHomeDir()
 		keyPath := filepath.Join(home, ".fast_key")
-		fmt.Printf("   (Checked environment variable GEMINI_API_KEY and file: %s)\n", keyPath)
-		fmt.Println("👉 Please run the installer again to set up your key.")
+		fmt.Fprintf(os.Stderr, "   (Checked environment variable GEMINI_API_KEY and file: %s)\n", keyPath)
+		fmt.Fprintln(os.Stderr, "👉 Please run the installer again to set up your key.")
 		os.Exit(1)
 	}
-	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent?key=%s", model, apiKey)
+	return apiKey
+}
 
+// geminiPayload builds the generateContent request body. A non-empty cached
+// is the name of a cachedContents entry holding the system prompt, sent
+// instead of sys.
+func geminiPayload(sys, prompt string, images []InlineImage, cfg map[string]interface{}, cached string) []byte {
+	parts := []map[string]interface{}{{"text": prompt}}
+	for _, img := range images {
+		parts = append(parts, map[string]interface{}{
+			"inline_data": map[string]string{
+				"mime_type": img.MIMEType,
+				"data":      base64.StdEncoding.EncodeToString(img.Data),
+			},
+		})
+	}
 	payload := map[string]interface{}{
-		"contents": []map[string]interface{}{{"parts": []map[string]string{{"text": prompt}}}},
+		"contents": []map[string]interface{}{{"parts": parts}},
 	}
-	if sys != "" {
+	if cached != "" {
+		payload["cachedContent"] = cached
+	} else if sys != "" {
 		payload["system_instruction"] = map[string]interface{}{"parts": []map[string]string{{"text": sys}}}
 	}
+	applyModelConfig(payload, cfg)
 
 	jsonData, _ := json.Marshal(payload)
-	resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+	return jsonData
+}
+
+// callGemini sends a single prompt and returns the response text along with
+// the total token count reported by the API. A failed request returns an
+// *apiError with the HTTP status and the API's message.
+var callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+	if os.Getenv("MOCK_FLOW") == "true" {
+		return "Mocked response for: " + prompt, 0, nil
+	}
+	apiKey := requireAPIKey()
+	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent?key=%s", model, apiKey)
+
+	sys, cached := promptCaches.use(ctx, model, sys, apiKey)
+	jsonData := geminiPayload(sys, prompt, images, modelConfigFrom(ctx), cached)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(jsonData))
 	if err != nil {
-		fmt.Printf("❌ Network error: %v\n", err)
-		return ""
+		return "", 0, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if ctx.Err() != nil {
+		return "", 0, ctx.Err()
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("network error: %w", err)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		apiKeys.markRateLimited(apiKey)
+	}
 
 	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, newAPIError(resp, body)
+	}
 	var res map[string]interface{}
 	if err := json.Unmarshal(body, &res); err != nil {
-		fmt.Printf("❌ Failed to parse API response: %v\nBody: %s\n", err, string(body))
-		return ""
+		return "", 0, fmt.Errorf("failed to parse API response: %v: %s", err, body)
 	}
 
 	if errVal, ok := res["error"]; ok {
-		fmt.Printf("❌ API Error: %v\n", errVal)
-		return ""
+		return "", 0, fmt.Errorf("API error: %v", errVal)
 	}
 
 	candidates, ok := res["candidates"].([]interface{})
 	if !ok || len(candidates) == 0 {
 		// Check if it was blocked due to safety
 		if promptFeedback, ok := res["promptFeedback"]; ok {
-			fmt.Printf("❌ Prompt blocked. Feedback: %v\n", promptFeedback)
-		} else {
-			fmt.Printf("❌ No candidates returned. Response: %s\n", string(body))
+			return "", 0, fmt.Errorf("prompt blocked: %v", promptFeedback)
 		}
-		return ""
+		return "", 0, fmt.Errorf("%w: no candidates in the response", errNoResult)
 	}
 
-	candidate := candidates[0].(map[string]interface{})
+	candidate, _ := candidates[0].(map[string]interface{})
 	content, ok := candidate["content"].(map[string]interface{})
 	if !ok {
 		if finishReason, ok := candidate["finishReason"]; ok {
-			fmt.Printf("❌ Generation stopped. Reason: %v\n", finishReason)
-		} else {
-			fmt.Printf("❌ Unexpected response structure: %s\n", string(body))
+			return "", 0, fmt.Errorf("generation stopped: %v", finishReason)
 		}
-		return ""
+		return "", 0, fmt.Errorf("unexpected response structure: %s", body)
 	}
 
-	return content["parts"].([]interface{})[0].(map[string]interface{})["text"].(string)
+	tokens := 0
+	if usage, ok := res["usageMetadata"].(map[string]interface{}); ok {
+		if total, ok := usage["totalTokenCount"].(float64); ok {
+			tokens = int(total)
+		}
+	}
+
+	parts, _ := content["parts"].([]interface{})
+	if expectsImage(ctx) {
+		img := firstImagePart(parts)
+		if img == "" {
+			return "", tokens, fmt.Errorf("%w: no image in the response", errNoResult)
+		}
+		return img, tokens, nil
+	}
+	var text string
+	if len(parts) > 0 {
+		part, _ := parts[0].(map[string]interface{})
+		text, _ = part["text"].(string)
+	}
+	return text, tokens, nil
 }
 
 func copyToClipboard(s string) {
@@ -1,23 +1,39 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
 )
 
+func TestMain(m *testing.M) {
+	// Mocked AI calls shouldn't be held back by the default rate limit
+	globalConfig.RateLimit.RequestsPerMinute = 0
+	os.Exit(m.Run())
+}
+
 func TestRunFlow(t *testing.T) {
 	// Mock callGemini
 	originalCallGemini := callGemini
 	defer func() { callGemini = originalCallGemini }()
 
-	callGemini = func(model, sys, prompt string) string {
-		return "Mocked response for: " + prompt
+	callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+		return "Mocked response for: " + prompt, 0, nil
 	}
 
-	// Reset results
-	results = make(map[string]string)
+	run := newFlowRun("")
 
 	conf := Config{
 		Model: "test-model",
@@ -27,15 +43,26 @@ func TestRunFlow(t *testing.T) {
 		},
 	}
 
-	runFlow(conf, nil)
+	runFlow(context.Background(), run, conf, nil)
 
-	if results["step1"] != "Mocked response for: Hello" {
-		t.Errorf("Expected step1 result, got %s", results["step1"])
+	if run.GetResult("step1") != "Mocked response for: Hello" {
+		t.Errorf("Expected step1 result, got %s", run.GetResult("step1"))
 	}
 
 	expectedStep2 := "Mocked response for: Previous was Mocked response for: Hello"
-	if results["step2"] != expectedStep2 {
-		t.Errorf("Expected step2 result %s, got %s", expectedStep2, results["step2"])
+	if run.GetResult("step2") != expectedStep2 {
+		t.Errorf("Expected step2 result %s, got %s", expectedStep2, run.GetResult("step2"))
+	}
+
+	logs := run.collectStepLogs(conf)
+	if len(logs) != 2 {
+		t.Fatalf("Expected 2 step logs, got %d", len(logs))
+	}
+	if logs[0].ID != "step1" || logs[0].Model != "test-model" {
+		t.Errorf("Unexpected step log %+v", logs[0])
+	}
+	if logs[1].StartTime.Before(logs[0].EndTime) {
+		t.Errorf("Expected step2 to start after step1 finished")
 	}
 }
 
@@ -55,7 +82,7 @@ func TestSaveSessionLog(t *testing.T) {
 	results := map[string]string{"step1": "result1"}
 
 	// Run the function
-	saveSessionLog(flowName, input, clipboard, conf, results)
+	saveSessionLog(flowName, input, "", clipboard, conf, results, nil, nil)
 
 	// Verify file creation
 	logDir := filepath.Join(tempHome, "fast-flows", "logs")
@@ -86,3 +113,709 @@ func TestSaveSessionLog(t *testing.T) {
 		t.Errorf("Expected result1, got %s", log.Results["step1"])
 	}
 }
+
+func TestRunSingleStep(t *testing.T) {
+	originalCallGemini := callGemini
+	defer func() { callGemini = originalCallGemini }()
+
+	var gotPrompt string
+	callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+		gotPrompt = prompt
+		return "ok", 0, nil
+	}
+
+	run := newFlowRun("")
+	run.pin(map[string]string{"step1": "pinned"})
+	conf := Config{
+		Model: "test-model",
+		Steps: []Step{
+			{ID: "step1", Prompt: "Hello"},
+			{ID: "step2", Prompt: "A={{step1}} B={{other}}"},
+		},
+	}
+
+	if err := runSingleStep(run, conf, "step2"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if gotPrompt != "A=pinned B=" {
+		t.Errorf("Expected prompt 'A=pinned B=', got '%s'", gotPrompt)
+	}
+
+	if err := runSingleStep(run, conf, "missing"); err == nil {
+		t.Error("Expected error for unknown step")
+	}
+}
+
+func TestRunFlowSkipsPinnedSteps(t *testing.T) {
+	originalCallGemini := callGemini
+	defer func() { callGemini = originalCallGemini }()
+
+	calls := 0
+	callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+		calls++
+		return "Mocked response for: " + prompt, 0, nil
+	}
+
+	run := newFlowRun("")
+	run.pin(map[string]string{"step1": "pinned"})
+
+	conf := Config{
+		Steps: []Step{
+			{ID: "step1", Prompt: "Hello"},
+			{ID: "step2", Prompt: "Previous was {{step1}}"},
+		},
+	}
+
+	runFlow(context.Background(), run, conf, nil)
+
+	if calls != 1 {
+		t.Errorf("Expected 1 AI call, got %d", calls)
+	}
+	if run.GetResult("step2") != "Mocked response for: Previous was pinned" {
+		t.Errorf("Expected step2 to use pinned value, got %s", run.GetResult("step2"))
+	}
+}
+
+func TestCleanLogs(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	ages := map[string]time.Duration{
+		"old.json":  40 * 24 * time.Hour,
+		"a.json":    3 * time.Hour,
+		"b.json":    2 * time.Hour,
+		"c.json":    1 * time.Hour,
+		"notes.txt": 50 * 24 * time.Hour,
+	}
+	for name, age := range ages {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	maxAge, err := parseAge("30d")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	removed, err := cleanLogs(dir, maxAge, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if removed != 2 {
+		t.Errorf("Expected 2 logs removed, got %d", removed)
+	}
+
+	for _, name := range []string{"b.json", "c.json", "notes.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("Expected %s to be kept", name)
+		}
+	}
+	for _, name := range []string{"old.json", "a.json"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			t.Errorf("Expected %s to be removed", name)
+		}
+	}
+}
+
+func TestLogNoticesHeldDuringTUI(t *testing.T) {
+	holdLogNoticesDuringTUI()
+	logNotice("🧹 Removed %d old session log(s)", 3)
+	logNoticesMu.Lock()
+	held := slices.Clone(logNotices)
+	logNoticesMu.Unlock()
+	if len(held) != 1 || held[0] != "🧹 Removed 3 old session log(s)" {
+		t.Errorf("Expected the notice to be held, got %q", held)
+	}
+
+	flushLogNotices()
+	if holdLogNotices || logNotices != nil {
+		t.Error("Expected flushLogNotices to print and stop holding")
+	}
+}
+
+func TestFilterByTags(t *testing.T) {
+	conf := Config{
+		Steps: []Step{
+			{ID: "extract", Prompt: "{{clipboard}}"},
+			{ID: "draft", Prompt: "{{extract}}", Tags: []string{"writing"}},
+			{ID: "review", Prompt: "{{draft}}", Tags: []string{"qa"}},
+			{ID: "unrelated", Prompt: "hi"},
+		},
+	}
+
+	filtered, err := filterByTags(conf, "writing")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var ids []string
+	for _, s := range filtered.Steps {
+		ids = append(ids, s.ID)
+	}
+	if strings.Join(ids, ",") != "extract,draft" {
+		t.Errorf("Expected extract,draft, got %v", ids)
+	}
+
+	if _, err := filterByTags(conf, "missing"); err == nil {
+		t.Error("Expected error when no steps match")
+	}
+}
+
+func TestRunFlowCancelled(t *testing.T) {
+	originalCallGemini := callGemini
+	defer func() { callGemini = originalCallGemini }()
+
+	callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+		if prompt == "slow" {
+			<-ctx.Done()
+			return "", 0, nil
+		}
+		return "fast result", 0, nil
+	}
+
+	run := newFlowRun("")
+	conf := Config{
+		Steps: []Step{
+			{ID: "fast", Prompt: "quick"},
+			{ID: "slow", Prompt: "slow"},
+			{ID: "after", Prompt: "{{slow}}"},
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		for run.GetResult("fast") == "" {
+			time.Sleep(10 * time.Millisecond)
+		}
+		cancel()
+	}()
+
+	err := runFlow(ctx, run, conf, nil)
+	if err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if run.GetResult("fast") != "fast result" {
+		t.Errorf("Expected completed result to be kept, got %q", run.GetResult("fast"))
+	}
+	if _, ok := run.Results()["after"]; ok {
+		t.Error("Expected dependent step not to run after abort")
+	}
+}
+
+func TestRunWithoutTUI(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	originalCallGemini := callGemini
+	defer func() { callGemini = originalCallGemini }()
+	callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+		return "Mocked response for: " + prompt, 0, nil
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	conf := Config{Steps: []Step{
+		{ID: "step1", Prompt: "{{input}}"},
+		{ID: "step2", Prompt: "Previous was {{step1}}"},
+	}}
+	err = runWithoutTUI(newFlowRun("piped"), conf, "test", Options{Format: "raw"})
+	w.Close()
+	os.Stdout = stdout
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out, _ := io.ReadAll(r)
+	if string(out) != "Mocked response for: Previous was Mocked response for: piped\n" {
+		t.Errorf("Expected only the final result on stdout, got %q", out)
+	}
+}
+
+func TestRunFlowParallelLimit(t *testing.T) {
+	originalCallGemini := callGemini
+	defer func() { callGemini = originalCallGemini }()
+
+	var mu sync.Mutex
+	running, peak := 0, 0
+	callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return "ok", 0, nil
+	}
+
+	conf := Config{Steps: []Step{
+		{ID: "a", Prompt: "a"},
+		{ID: "b", Prompt: "b"},
+		{ID: "c", Prompt: "c"},
+		{ID: "d", Prompt: "d"},
+	}}
+	for _, limit := range []int{1, 2} {
+		peak = 0
+		run := newFlowRun("")
+		run.ParallelLimit = limit
+		if err := runFlow(context.Background(), run, conf, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if peak != limit {
+			t.Errorf("Expected at most %d steps at once, got %d", limit, peak)
+		}
+	}
+}
+
+func TestRunFlowDependencyTimeout(t *testing.T) {
+	originalCallGemini := callGemini
+	defer func() { callGemini = originalCallGemini }()
+	callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+		return "ok", 0, nil
+	}
+
+	conf := Config{
+		DepTimeout: "200ms",
+		Steps: []Step{
+			{ID: "waiting", Prompt: "Needs {{missing}}"},
+		},
+	}
+	err := runFlow(context.Background(), newFlowRun(""), conf, nil)
+	if err == nil || !strings.Contains(err.Error(), "dependency timeout") || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("Expected dependency timeout naming the missing step, got %v", err)
+	}
+
+	if _, err := parseFlow([]byte(`{"dep_timeout": "soon", "steps": [{"id": "a", "prompt": "hi"}]}`)); err == nil {
+		t.Error("Expected error for an invalid dep_timeout")
+	}
+}
+
+func TestParseFlowLegacyKeys(t *testing.T) {
+	conf, err := parseFlow([]byte(`{"Model": "flash", "systemPrompt": "Be kind.", "Steps": [{"ID": "a", "TabID": "one", "Prompt": "hi"}, {"id": "b", "tab_id": "two", "TabID": "old", "prompt": "hi"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.SystemPrompt != "Be kind." || conf.Model != "flash" {
+		t.Errorf("Expected the legacy keys to be read, got %+v", conf)
+	}
+	if conf.Steps[0].ID != "a" || conf.Steps[0].TabID != "one" || conf.Steps[1].TabID != "two" {
+		t.Errorf("Expected legacy TabID, with tab_id winning, got %+v", conf.Steps)
+	}
+
+	conf, _ = parseFlow([]byte(`{"system_prompt": "new", "systemPrompt": "old", "steps": [{"id": "a", "prompt": "hi"}]}`))
+	if conf.SystemPrompt != "new" {
+		t.Errorf("Expected system_prompt to win, got %q", conf.SystemPrompt)
+	}
+}
+
+func TestResumeResults(t *testing.T) {
+	conf := Config{Steps: []Step{
+		{ID: "step1", Prompt: "Hello"},
+		{ID: "step2", Prompt: "Previous was {{step1}}"},
+		{ID: "step3", Prompt: "Then {{step2}}"},
+	}}
+	logPath := filepath.Join(t.TempDir(), "run.json")
+	data, _ := json.Marshal(SessionLog{
+		FlowName:   "test",
+		Config:     conf,
+		ConfigHash: configHash(conf),
+		Results:    map[string]string{"step1": "one", "step2": "two"},
+	})
+	if err := os.WriteFile(logPath, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pinned := setFlags{"step2": "override"}
+	changed, err := resumeResults(logPath, "test", conf, pinned)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if changed {
+		t.Error("Expected unchanged config")
+	}
+	if pinned["step1"] != "one" || pinned["step2"] != "override" {
+		t.Errorf("Expected step1 from the log and step2 from --set, got %v", pinned)
+	}
+	if _, ok := pinned["step3"]; ok {
+		t.Error("Expected step3 to run again")
+	}
+
+	conf.Steps[2].Prompt = "Edited {{step2}}"
+	if changed, _ := resumeResults(logPath, "test", conf, setFlags{}); !changed {
+		t.Error("Expected config change to be detected")
+	}
+	if _, err := resumeResults(logPath, "other", conf, setFlags{}); err == nil {
+		t.Error("Expected error for a log from another flow")
+	}
+}
+
+func TestRunFlowStepError(t *testing.T) {
+	originalCallGemini := callGemini
+	defer func() { callGemini = originalCallGemini }()
+	callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+		return "", 0, nil
+	}
+
+	conf := Config{Steps: []Step{{ID: "broken", Prompt: "Hello"}}}
+	err := runFlow(context.Background(), newFlowRun(""), conf, nil)
+
+	var se *StepError
+	if !errors.As(err, &se) {
+		t.Fatalf("Expected a *StepError, got %T: %v", err, err)
+	}
+	if se.StepID != "broken" || se.StepType != "text" || se.Attempt != 1 || !errors.Is(err, errNoResult) {
+		t.Errorf("Unexpected step error %+v", se)
+	}
+}
+
+func TestFlowTimeout(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	originalCallGemini := callGemini
+	defer func() { callGemini = originalCallGemini }()
+	callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+		<-ctx.Done()
+		return "", 0, nil
+	}
+
+	conf := Config{Steps: []Step{{ID: "slow", Prompt: "slow"}}}
+	err := runWithoutTUI(newFlowRun(""), conf, "test", Options{Format: "raw", Timeout: 50 * time.Millisecond})
+	if !errors.Is(err, errFlowTimeout) || exitCode(err) != 2 {
+		t.Errorf("Expected flow timeout with exit code 2, got %v", err)
+	}
+
+	m := InitialModel(conf, "test", "", "", themes["dark"])
+	updated, _ := m.Update(StepStartedMsg{ID: "slow"})
+	updated, cmd := updated.(FlowModel).Update(FlowTimeoutMsg{})
+	m = updated.(FlowModel)
+	if !m.TimedOut || m.Steps[0].State != StateFailed || !errors.Is(m.Steps[0].Err, errFlowTimeout) || cmd == nil {
+		t.Errorf("Expected steps marked as failed by the timeout, got %+v", m.Steps[0])
+	}
+}
+
+func TestStepFailedWaitsForFlowFinished(t *testing.T) {
+	conf := Config{Steps: []Step{{ID: "a", Prompt: "A"}, {ID: "b", Prompt: "B"}}}
+	m := InitialModel(conf, "test", "", "", themes["dark"])
+	m.WatchFiles = []string{"flow.json"}
+
+	updated, cmd := m.Update(StepFailedMsg{ID: "a", Err: &StepError{StepID: "a", Cause: errors.New("first")}})
+	updated, _ = updated.(FlowModel).Update(StepFailedMsg{ID: "b", Err: &StepError{StepID: "b", Cause: errors.New("second")}})
+	m = updated.(FlowModel)
+	if cmd != nil || m.Quitting {
+		t.Fatal("Expected the TUI to wait for the runner after a step failure")
+	}
+	if se, ok := m.Err.(*StepError); !ok || se.StepID != "a" {
+		t.Errorf("Expected the first failure to be kept, got %v", m.Err)
+	}
+
+	// Even in watch mode a failed run ends the TUI once the runner is done
+	updated, cmd = m.Update(FlowFinishedMsg{})
+	if !updated.(FlowModel).Quitting || cmd == nil {
+		t.Error("Expected FlowFinishedMsg to quit after a failure")
+	}
+}
+
+func TestCommentLine(t *testing.T) {
+	m := FlowModel{}
+	if got := m.commentLine("Summarizes the transcript"); !strings.Contains(got, "Summarizes the transcript") {
+		t.Errorf("Expected the comment, got %q", got)
+	}
+	m.Width = 30
+	if got := m.commentLine("Summarizes the transcript"); got != "" {
+		t.Errorf("Expected no comment on a narrow terminal, got %q", got)
+	}
+	m.Width = 50
+	if got := m.commentLine(strings.Repeat("x", 60)); !strings.Contains(got, "…") {
+		t.Errorf("Expected a long comment to be cut, got %q", got)
+	}
+}
+
+func TestStepPreview(t *testing.T) {
+	if got := stepPreview("First line\nsecond  line\n"); got != "First line second line" {
+		t.Errorf("stepPreview() = %q", got)
+	}
+	if got := stepPreview(strings.Repeat("é", 100)); got != strings.Repeat("é", 80)+"…" {
+		t.Errorf("Expected the preview to be cut at 80 characters, got %q", got)
+	}
+
+	s := &StepStatus{Step: Step{ID: "sum"}, State: StateDone, Preview: "Short summary"}
+	m := FlowModel{Expanded: map[string]bool{}}
+	if got := m.previewLine(s); !strings.Contains(got, "Short summary") {
+		t.Errorf("Expected the preview, got %q", got)
+	}
+	m.Expanded["sum"] = true
+	if got := m.previewLine(s); got != "" {
+		t.Errorf("Expected no preview while expanded, got %q", got)
+	}
+	m = FlowModel{NoPreview: true}
+	if got := m.previewLine(s); got != "" {
+		t.Errorf("Expected no preview with --no-preview, got %q", got)
+	}
+}
+
+func TestPreviewLinesCutsRunes(t *testing.T) {
+	got := previewLines(strings.Repeat("é", 300))
+	if !utf8.ValidString(got) {
+		t.Errorf("Expected valid UTF-8, got %q", got)
+	}
+	if !strings.Contains(got, strings.Repeat("é", 200)+"...") {
+		t.Errorf("Expected the preview to be cut at 200 characters, got %q", got)
+	}
+}
+
+func TestRunStepTrimWhitespace(t *testing.T) {
+	originalCallGemini := callGemini
+	defer func() { callGemini = originalCallGemini }()
+	callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+		return "\n  answer \n\n", 0, nil
+	}
+
+	keep := false
+	tests := []struct {
+		name string
+		conf Config
+		step Step
+		want string
+	}{
+		{"default", Config{}, Step{ID: "s"}, "answer"},
+		{"flow off", Config{TrimWhitespace: &keep}, Step{ID: "s"}, "\n  answer \n\n"},
+		{"step off", Config{}, Step{ID: "s", TrimWhitespace: &keep}, "\n  answer \n\n"},
+	}
+	for _, tt := range tests {
+		res, _, err := runStep(context.Background(), newFlowRun(""), tt.conf, tt.step, func(string) {})
+		if err != nil || res != tt.want {
+			t.Errorf("%s: expected %q, got %q (%v)", tt.name, tt.want, res, err)
+		}
+	}
+}
+
+func TestSystemPromptPersona(t *testing.T) {
+	original := globalConfig
+	defer func() { globalConfig = original }()
+	globalConfig.Personas = map[string]string{"editor": "meticulous copy editor who keeps the author's voice"}
+
+	conf := Config{SystemPrompt: "Be concise."}
+	tests := []struct {
+		persona string
+		want    string
+	}{
+		{"", "Be concise."},
+		{"editor", "You are a meticulous copy editor who keeps the author's voice.\n\nBe concise."},
+		{"pirate", "You are a pirate.\n\nBe concise."},
+	}
+	for _, tt := range tests {
+		if got := systemPrompt(conf, Step{Persona: tt.persona}); got != tt.want {
+			t.Errorf("persona %q: expected %q, got %q", tt.persona, tt.want, got)
+		}
+	}
+	if got := systemPrompt(Config{}, Step{Persona: "pirate"}); got != "You are a pirate." {
+		t.Errorf("Expected only the persona without a flow system prompt, got %q", got)
+	}
+}
+
+// recordingSender collects the TUI messages sent during a run.
+type recordingSender struct {
+	mu   sync.Mutex
+	msgs []tea.Msg
+}
+
+func (s *recordingSender) Send(msg tea.Msg) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.msgs = append(s.msgs, msg)
+}
+
+func TestRunFlowMockStep(t *testing.T) {
+	originalCallGemini := callGemini
+	defer func() { callGemini = originalCallGemini }()
+
+	var prompts []string
+	var mu sync.Mutex
+	callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		prompts = append(prompts, prompt)
+		return "polished", 0, nil
+	}
+
+	conf := Config{Steps: []Step{
+		{ID: "draft", Prompt: "Write a draft"},
+		{ID: "polish", Prompt: "Polish: {{draft}}"},
+	}}
+	run := newFlowRun("")
+	run.mock(map[string]string{"draft": "This is a test draft"})
+	sender := &recordingSender{}
+
+	if err := runFlow(context.Background(), run, conf, sender); err != nil {
+		t.Fatal(err)
+	}
+	if len(prompts) != 1 || prompts[0] != "Polish: This is a test draft" {
+		t.Errorf("Expected only polish to call the AI with the mock, got %q", prompts)
+	}
+
+	// The mocked step shows up in the TUI as a step that ran
+	var started, done bool
+	for _, msg := range sender.msgs {
+		switch msg := msg.(type) {
+		case StepStartedMsg:
+			started = started || msg.ID == "draft"
+		case StepDoneMsg:
+			done = done || (msg.ID == "draft" && !msg.Pinned)
+		}
+	}
+	if !started || !done {
+		t.Errorf("Expected draft to be started and done, got %v", sender.msgs)
+	}
+
+	logs := run.collectStepLogs(conf)
+	if len(logs) != 2 || !logs[0].Mocked || logs[1].Mocked {
+		t.Errorf("Expected only draft to be logged as mocked, got %+v", logs)
+	}
+}
+
+func TestRunFlowDependsOn(t *testing.T) {
+	originalCallGemini := callGemini
+	defer func() { callGemini = originalCallGemini }()
+
+	var mu sync.Mutex
+	var order []string
+	callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+		if prompt == "write" {
+			time.Sleep(50 * time.Millisecond)
+		}
+		mu.Lock()
+		order = append(order, prompt)
+		mu.Unlock()
+		return "ok", 0, nil
+	}
+
+	conf := Config{Steps: []Step{
+		{ID: "write", Prompt: "write"},
+		{ID: "announce", Prompt: "announce", DependsOn: []string{"write"}},
+	}}
+	if err := runFlow(context.Background(), newFlowRun(""), conf, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(order, ",") != "write,announce" {
+		t.Errorf("Expected announce to wait for write, got %v", order)
+	}
+
+	if err := validateDependsOn(conf.Steps); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := validateDependsOn([]Step{{ID: "a", DependsOn: []string{"missing"}}}); err == nil {
+		t.Error("Expected error for unknown depends_on step")
+	}
+	if err := validateDependsOn([]Step{{ID: "a", DependsOn: []string{"a"}}}); err == nil {
+		t.Error("Expected error for a step depending on itself")
+	}
+}
+
+func TestRunStepFallbackPrompt(t *testing.T) {
+	originalCallGemini := callGemini
+	defer func() { callGemini = originalCallGemini }()
+	callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+		if prompt == "short version" {
+			return "fallback answer", 0, nil
+		}
+		return "", 0, nil
+	}
+
+	var progress []string
+	s := Step{ID: "s", Prompt: "long version", FallbackPrompt: "short version"}
+	res, log, err := runStep(context.Background(), newFlowRun(""), Config{}, s, func(text string) { progress = append(progress, text) })
+	if err != nil || res != "fallback answer" || !log.Fallback {
+		t.Errorf("Expected the fallback answer, got %q, %+v, %v", res, log, err)
+	}
+	if len(progress) == 0 || progress[0] != "Using fallback prompt…" {
+		t.Errorf("Expected the fallback to be shown, got %q", progress)
+	}
+
+	s.FallbackPrompt = "also empty"
+	if _, _, err := runStep(context.Background(), newFlowRun(""), Config{}, s, func(string) {}); !errors.Is(err, errNoResult) {
+		t.Errorf("Expected the step to fail when the fallback fails too, got %v", err)
+	}
+}
+
+func TestRunStepSaveTo(t *testing.T) {
+	originalCallGemini := callGemini
+	defer func() { callGemini = originalCallGemini }()
+	callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+		return "saved text", 0, nil
+	}
+
+	dir := t.TempDir()
+	run := newFlowRun("report")
+	s := Step{ID: "s", Prompt: "x", SaveTo: filepath.Join(dir, "out", "{{input}}.md")}
+	res, log, err := runStep(context.Background(), run, Config{}, s, func(string) {})
+	if err != nil || res != "saved text" || len(log.Warnings) != 0 {
+		t.Fatalf("runStep() = %q, %+v, %v", res, log, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "out", "report.md")); err != nil || string(data) != "saved text" {
+		t.Errorf("Expected the result in the file, got %q (%v)", data, err)
+	}
+
+	// A file in the way of the directory makes the save fail
+	s.SaveTo = filepath.Join(dir, "out", "report.md", "nested.md")
+	res, log, err = runStep(context.Background(), run, Config{}, s, func(string) {})
+	if err != nil || res != "saved text" || len(log.Warnings) != 1 {
+		t.Errorf("Expected a warning but a good result, got %q, %v, %v", res, log.Warnings, err)
+	}
+}
+
+func TestRunFlowPrependAppend(t *testing.T) {
+	originalCallGemini := callGemini
+	defer func() { callGemini = originalCallGemini }()
+	callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+		return "body", 0, nil
+	}
+
+	run := newFlowRun("")
+	run.flowName = "docs"
+	conf := Config{Steps: []Step{
+		{ID: "title", Prompt: "x"},
+		{ID: "doc", Prompt: "y", Prepend: "# {{title}}\n\n", Append: "\n\n---\nGenerated by {{flow_name}}"},
+	}}
+	if err := runFlow(context.Background(), run, conf, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := run.GetResult("doc"), "# body\n\nbody\n\n---\nGenerated by docs"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if err := validatePostProcess(Step{ID: "doc", Append: "!", StreamingToFile: true}); err == nil {
+		t.Error("Expected error combining append with streaming_to_file")
+	}
+}
+
+func TestDurationColor(t *testing.T) {
+	thresholds := []float64{2, 10, 30}
+	cases := map[time.Duration]lipgloss.Color{
+		500 * time.Millisecond: "42",
+		2 * time.Second:        "226",
+		9 * time.Second:        "226",
+		15 * time.Second:       "214",
+		45 * time.Second:       "196",
+	}
+	for d, want := range cases {
+		if got := durationColor(d, thresholds); got != want {
+			t.Errorf("durationColor(%s) = %s, want %s", d, got, want)
+		}
+	}
+
+	if got := durationLegend(thresholds); !strings.Contains(got, "<2s") || !strings.Contains(got, "10-30s") || !strings.Contains(got, ">30s") {
+		t.Errorf("Unexpected legend %q", got)
+	}
+
+	if durationThresholds(TUIConfig{DurationThresholds: thresholds}) != nil {
+		t.Error("Expected no thresholds while color_by_duration is off")
+	}
+	if validateDurationThresholds([]float64{10, 2, 30}) == nil || validateDurationThresholds([]float64{1, 2}) == nil {
+		t.Error("Expected thresholds that don't increase to be rejected")
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// textMetrics is the result of a "metrics" step.
+type textMetrics struct {
+	Characters int     `json:"character_count"`
+	Words      int     `json:"word_count"`
+	Sentences  int     `json:"sentence_count"`
+	Paragraphs int     `json:"paragraph_count"`
+	GradeLevel float64 `json:"flesch_kincaid_grade"`
+}
+
+// validateMetrics checks the fields of a metrics step.
+func validateMetrics(s Step) error {
+	if s.Type == "metrics" && s.Source == "" {
+		return fmt.Errorf("step '%s': metrics needs a source", s.ID)
+	}
+	return nil
+}
+
+// runMetrics measures text and returns the numbers as a JSON object.
+func runMetrics(text string) (string, error) {
+	out, err := json.Marshal(measureText(text))
+	return string(out), err
+}
+
+func measureText(text string) textMetrics {
+	m := textMetrics{Characters: utf8.RuneCountInString(text)}
+
+	words := strings.Fields(text)
+	syllables := 0
+	for _, w := range words {
+		w = strings.TrimFunc(w, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+		if w == "" {
+			continue
+		}
+		m.Words++
+		syllables += countSyllables(w)
+	}
+
+	// A sentence ends at a run of . ! or ?; trailing text without one
+	// still counts
+	inSentence := false
+	for _, r := range text {
+		switch {
+		case r == '.' || r == '!' || r == '?':
+			if inSentence {
+				m.Sentences++
+			}
+			inSentence = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			inSentence = true
+		}
+	}
+	if inSentence {
+		m.Sentences++
+	}
+
+	for _, p := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n\n") {
+		if strings.TrimSpace(p) != "" {
+			m.Paragraphs++
+		}
+	}
+
+	if m.Words > 0 && m.Sentences > 0 {
+		grade := 0.39*float64(m.Words)/float64(m.Sentences) + 11.8*float64(syllables)/float64(m.Words) - 15.59
+		m.GradeLevel = math.Round(grade*10) / 10
+	}
+	return m
+}
+
+// countSyllables estimates the syllables of an English word by counting
+// groups of vowels, not counting a silent final e. Every word has at least
+// one.
+func countSyllables(word string) int {
+	word = strings.ToLower(word)
+	count := 0
+	prevVowel := false
+	for _, r := range word {
+		vowel := strings.ContainsRune("aeiouy", r)
+		if vowel && !prevVowel {
+			count++
+		}
+		prevVowel = vowel
+	}
+	if strings.HasSuffix(word, "e") && !strings.HasSuffix(word, "le") && count > 1 {
+		count--
+	}
+	return max(count, 1)
+}
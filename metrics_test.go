@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCountSyllables(t *testing.T) {
+	tests := map[string]int{"cat": 1, "table": 2, "make": 1, "readability": 5, "the": 1, "rhythm": 1, "queue": 1}
+	for word, want := range tests {
+		if got := countSyllables(word); got != want {
+			t.Errorf("countSyllables(%q) = %d, want %d", word, got, want)
+		}
+	}
+}
+
+func TestMeasureText(t *testing.T) {
+	text := "The cat sat on the mat. It was happy!\n\nThen it left... Why?"
+	m := measureText(text)
+	want := textMetrics{Characters: 59, Words: 13, Sentences: 4, Paragraphs: 2, GradeLevel: -1.6}
+	if m != want {
+		t.Errorf("measureText() = %+v, want %+v", m, want)
+	}
+
+	if m := measureText(""); m != (textMetrics{}) {
+		t.Errorf("Expected zero metrics for empty text, got %+v", m)
+	}
+	if m := measureText("no punctuation here"); m.Sentences != 1 {
+		t.Errorf("Expected trailing text to count as a sentence, got %+v", m)
+	}
+}
+
+func TestRunFlowMetricsStep(t *testing.T) {
+	run := newFlowRun("")
+	run.pin(map[string]string{"draft": "One two three. Four five."})
+	conf := Config{Steps: []Step{
+		{ID: "draft", Prompt: "x"},
+		{ID: "stats", Type: "metrics", Source: "{{draft}}"},
+	}}
+	if err := runFlow(context.Background(), run, conf, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	run.mu.Lock()
+	words := run.fillJSONTags("{{json:stats.word_count}}/{{json:stats.sentence_count}}")
+	run.mu.Unlock()
+	if words != "5/2" {
+		t.Errorf("Expected 5 words in 2 sentences, got %s", words)
+	}
+	if err := validateMetrics(Step{ID: "stats", Type: "metrics"}); err == nil {
+		t.Error("Expected error without source")
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// modelConfigKey carries the merged model_config of a step in the context of
+// its AI calls, so callGemini and streamGemini can add it to the request.
+type modelConfigKey struct{}
+
+func withModelConfig(ctx context.Context, cfg map[string]interface{}) context.Context {
+	if len(cfg) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, modelConfigKey{}, cfg)
+}
+
+func modelConfigFrom(ctx context.Context) map[string]interface{} {
+	cfg, _ := ctx.Value(modelConfigKey{}).(map[string]interface{})
+	return cfg
+}
+
+// safetySettingsKeys are the model_config keys sent as the request's
+// safety settings rather than as part of its generation config.
+var safetySettingsKeys = map[string]bool{"safety_settings": true, "safetySettings": true}
+
+// validateModelConfig checks that model_config is only used where the AI is
+// called and that safety_settings is a list.
+func validateModelConfig(s Step) error {
+	if len(s.ModelConfig) == 0 {
+		return nil
+	}
+	if s.Type != "" && s.Type != "text" {
+		return fmt.Errorf("step '%s': model_config only applies to text steps", s.ID)
+	}
+	if err := validateSafetySettings(s.ModelConfig); err != nil {
+		return fmt.Errorf("step '%s': %w", s.ID, err)
+	}
+	return nil
+}
+
+// validateSafetySettings checks that the safety_settings of a model_config,
+// the step's or the flow's, is a list.
+func validateSafetySettings(cfg map[string]interface{}) error {
+	for k, v := range cfg {
+		if _, ok := v.([]interface{}); safetySettingsKeys[k] && !ok {
+			return fmt.Errorf("model_config %s must be a list", k)
+		}
+	}
+	return nil
+}
+
+// mergeModelConfig deep-merges a step's model_config over the flow's: nested
+// objects are merged key by key, anything else in override replaces base.
+func mergeModelConfig(base, override map[string]interface{}) map[string]interface{} {
+	if len(base) == 0 && len(override) == 0 {
+		return nil
+	}
+	merged := make(map[string]interface{}, len(base)+len(override))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range override {
+		baseMap, ok1 := merged[k].(map[string]interface{})
+		overrideMap, ok2 := v.(map[string]interface{})
+		if ok1 && ok2 {
+			merged[k] = mergeModelConfig(baseMap, overrideMap)
+		} else {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// applyModelConfig adds a model_config to a generateContent payload. Keys
+// are passed through as Gemini field names, e.g. temperature,
+// stop_sequences or candidate_count; safety_settings goes next to the
+// generation config.
+func applyModelConfig(payload map[string]interface{}, cfg map[string]interface{}) {
+	generation := make(map[string]interface{})
+	for k, v := range cfg {
+		if safetySettingsKeys[k] {
+			payload["safety_settings"] = v
+		} else {
+			generation[k] = v
+		}
+	}
+	if len(generation) > 0 {
+		payload["generation_config"] = generation
+	}
+}
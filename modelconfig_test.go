@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMergeModelConfig(t *testing.T) {
+	flow := map[string]interface{}{
+		"temperature":     0.2,
+		"stop_sequences":  []interface{}{"END"},
+		"thinking_config": map[string]interface{}{"thinking_budget": 1024.0, "include_thoughts": true},
+	}
+	step := map[string]interface{}{
+		"temperature":     0.9,
+		"thinking_config": map[string]interface{}{"thinking_budget": 0.0},
+	}
+	want := map[string]interface{}{
+		"temperature":     0.9,
+		"stop_sequences":  []interface{}{"END"},
+		"thinking_config": map[string]interface{}{"thinking_budget": 0.0, "include_thoughts": true},
+	}
+	if got := mergeModelConfig(flow, step); !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeModelConfig() = %v, want %v", got, want)
+	}
+	if flow["temperature"] != 0.2 {
+		t.Error("Expected the flow config to be left alone")
+	}
+	if mergeModelConfig(nil, nil) != nil {
+		t.Error("Expected no config when neither level sets one")
+	}
+}
+
+func TestGeminiPayloadModelConfig(t *testing.T) {
+	safety := []interface{}{map[string]interface{}{"category": "HARM_CATEGORY_HARASSMENT", "threshold": "BLOCK_NONE"}}
+	cfg := map[string]interface{}{"candidate_count": 1.0, "safety_settings": safety}
+
+	var payload map[string]interface{}
+	if err := json.Unmarshal(geminiPayload("", "hi", nil, cfg, ""), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if gen := payload["generation_config"]; !reflect.DeepEqual(gen, map[string]interface{}{"candidate_count": 1.0}) {
+		t.Errorf("Unexpected generation_config %v", gen)
+	}
+	if !reflect.DeepEqual(payload["safety_settings"], safety) {
+		t.Errorf("Unexpected safety_settings %v", payload["safety_settings"])
+	}
+
+	payload = nil
+	if err := json.Unmarshal(geminiPayload("", "hi", nil, nil, ""), &payload); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := payload["generation_config"]; ok {
+		t.Error("Expected no generation_config without a model_config")
+	}
+}
+
+func TestRunStepModelConfig(t *testing.T) {
+	originalCallGemini := callGemini
+	defer func() { callGemini = originalCallGemini }()
+	var got map[string]interface{}
+	callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+		got = modelConfigFrom(ctx)
+		return "ok", 0, nil
+	}
+
+	conf, err := parseFlow([]byte(`{
+		"model_config": {"temperature": 0.2, "top_p": 0.9},
+		"steps": [{"id": "s1", "prompt": "hi", "model_config": {"temperature": 1}}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := runStep(context.Background(), newFlowRun(""), conf, conf.Steps[0], func(string) {}); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]interface{}{"temperature": 1.0, "top_p": 0.9}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the merged model config, got %v", got)
+	}
+
+	for _, bad := range []string{
+		`{"steps": [{"id": "m", "type": "metrics", "source": "x", "model_config": {"temperature": 1}}]}`,
+		`{"steps": [{"id": "s", "prompt": "hi", "model_config": {"safety_settings": "none"}}]}`,
+		`{"model_config": {"safety_settings": {"category": "x"}}, "steps": [{"id": "s", "prompt": "hi"}]}`,
+	} {
+		if _, err := parseFlow([]byte(bad)); err == nil {
+			t.Errorf("Expected error for %s", bad)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ndjsonToArray turns newline-delimited JSON (one value per line) into a
+// single JSON array. Blank lines are ignored; lines that aren't valid JSON
+// are skipped and counted.
+func ndjsonToArray(s string) (string, int, error) {
+	items := []json.RawMessage{}
+	skipped := 0
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if !json.Valid([]byte(line)) {
+			skipped++
+			continue
+		}
+		items = append(items, json.RawMessage(line))
+	}
+	out, err := json.Marshal(items)
+	if err != nil {
+		return "", skipped, err
+	}
+	return string(out), skipped, nil
+}
+
+// applyOutputFormat converts a step result according to its output_format.
+// It returns a warning for anything that had to be dropped.
+func applyOutputFormat(s Step, res string) (string, string, error) {
+	if s.OutputFormat != "ndjson" {
+		return res, "", nil
+	}
+	out, skipped, err := ndjsonToArray(res)
+	if err != nil {
+		return "", "", err
+	}
+	var warning string
+	if skipped > 0 {
+		warning = fmt.Sprintf("skipped %d invalid NDJSON line(s)", skipped)
+	}
+	return out, warning, nil
+}
+
+// validateOutputFormat checks the output_format of a step.
+func validateOutputFormat(s Step) error {
+	switch s.OutputFormat {
+	case "", "text":
+	case "ndjson":
+		if s.StreamingToFile {
+			return fmt.Errorf("step '%s': output_format ndjson can't be combined with streaming_to_file", s.ID)
+		}
+	case "json_patch":
+		if s.Type != "json_diff" {
+			return fmt.Errorf("step '%s': output_format json_patch only works for json_diff steps", s.ID)
+		}
+	default:
+		return fmt.Errorf("step '%s': unknown output_format %q (expected text, ndjson or json_patch)", s.ID, s.OutputFormat)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestNDJSONToArray(t *testing.T) {
+	in := "{\"id\": 1}\n\n{\"id\": 2, \"tags\": [\"a\"]}\nnot json\n\"text\"\n{broken\n"
+	got, skipped, err := ndjsonToArray(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `[{"id":1},{"id":2,"tags":["a"]},"text"]`; got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+	if skipped != 2 {
+		t.Errorf("Expected 2 skipped lines, got %d", skipped)
+	}
+
+	if got, _, _ := ndjsonToArray("nothing here"); got != "[]" {
+		t.Errorf("Expected an empty array, got %s", got)
+	}
+}
+
+func TestRunStepNDJSON(t *testing.T) {
+	originalCallGemini := callGemini
+	defer func() { callGemini = originalCallGemini }()
+	callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+		return "{\"a\":1}\noops\n{\"a\":2}\n", 0, nil
+	}
+
+	s := Step{ID: "list", OutputFormat: "ndjson"}
+	res, log, err := runStep(context.Background(), newFlowRun(""), Config{}, s, func(string) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != `[{"a":1},{"a":2}]` {
+		t.Errorf("Unexpected result %s", res)
+	}
+	if len(log.Warnings) != 1 || log.Warnings[0] != "skipped 1 invalid NDJSON line(s)" {
+		t.Errorf("Unexpected warnings %q", log.Warnings)
+	}
+
+	if err := validateOutputFormat(Step{ID: "x", OutputFormat: "csv"}); err == nil {
+		t.Error("Expected error for unknown output_format")
+	}
+}
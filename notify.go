@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// notify shows a desktop notification on macOS and Linux. Failures are
+// ignored, a missing notifier shouldn't break a flow.
+var notify = func(title, message string) {
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %q with title %q", message, title)
+		_ = exec.Command("osascript", "-e", script).Run()
+	case "linux":
+		_ = exec.Command("notify-send", title, message).Run()
+	}
+}
+
+// resultPreview squashes a result onto one line of at most 100 characters.
+func resultPreview(result string) string {
+	preview := strings.Join(strings.Fields(result), " ")
+	if r := []rune(preview); len(r) > 100 {
+		preview = string(r[:100]) + "..."
+	}
+	return preview
+}
+
+// notifyOnComplete reports whether a successful run should notify; the
+// flow's setting wins over config.json.
+func (c Config) notifyOnComplete() bool {
+	if c.NotifyOnComplete != nil {
+		return *c.NotifyOnComplete
+	}
+	return globalConfig.NotifyOnComplete
+}
+
+// notifyOnFailure is notifyOnComplete for failed and timed out runs.
+func (c Config) notifyOnFailure() bool {
+	if c.NotifyOnFailure != nil {
+		return *c.NotifyOnFailure
+	}
+	return globalConfig.NotifyOnFailure
+}
+
+// notifyFlowDone sends the notify_on_complete / notify_on_failure
+// notification for a finished run. Runs aborted by the user don't notify.
+func notifyFlowDone(flowName string, conf Config, result string, err error) {
+	switch {
+	case errors.Is(err, context.Canceled):
+	case err != nil:
+		if conf.notifyOnFailure() {
+			if errors.Is(err, context.DeadlineExceeded) {
+				err = errFlowTimeout
+			}
+			notify("Flow Failed: "+flowName, resultPreview(err.Error()))
+		}
+	case conf.notifyOnComplete():
+		notify("Flow Complete: "+flowName, resultPreview(result))
+	}
+}
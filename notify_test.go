@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNotifyFlowDone(t *testing.T) {
+	var got []string
+	oldNotify, oldGlobal := notify, globalConfig
+	notify = func(title, message string) { got = append(got, title+"|"+message) }
+	t.Cleanup(func() { notify, globalConfig = oldNotify, oldGlobal })
+
+	yes, no := true, false
+	globalConfig.NotifyOnComplete = true
+	globalConfig.NotifyOnFailure = false
+
+	long := strings.Repeat("x", 150)
+	notifyFlowDone("summary", Config{}, "  done\n\nnow ", nil)
+	notifyFlowDone("summary", Config{}, long, nil)
+	notifyFlowDone("quiet", Config{NotifyOnComplete: &no}, "done", nil)
+	notifyFlowDone("broken", Config{}, "", errors.New("boom"))
+	notifyFlowDone("broken", Config{NotifyOnFailure: &yes}, "", errors.New("boom"))
+	notifyFlowDone("slow", Config{NotifyOnFailure: &yes}, "", context.DeadlineExceeded)
+	notifyFlowDone("aborted", Config{NotifyOnFailure: &yes}, "", context.Canceled)
+
+	want := []string{
+		"Flow Complete: summary|done now",
+		"Flow Complete: summary|" + strings.Repeat("x", 100) + "...",
+		"Flow Failed: broken|boom",
+		"Flow Failed: slow|" + errFlowTimeout.Error(),
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("notifications = %q, want %q", got, want)
+	}
+}
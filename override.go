@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// envOverridePath returns the override file for FAST_ENV next to a flow,
+// e.g. flows/sum.dev.json for flows/sum.json, or "" if FAST_ENV isn't set.
+func envOverridePath(path string) string {
+	env := os.Getenv("FAST_ENV")
+	if env == "" {
+		return ""
+	}
+	return strings.TrimSuffix(path, ".json") + "." + env + ".json"
+}
+
+// applyEnvOverride merges the FAST_ENV override of the flow at path into
+// its data. Without FAST_ENV or an override file, data is returned as is.
+func applyEnvOverride(path string, data []byte) ([]byte, error) {
+	overridePath := envOverridePath(path)
+	if overridePath == "" {
+		return data, nil
+	}
+	override, err := os.ReadFile(overridePath)
+	if os.IsNotExist(err) {
+		return data, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	merged, err := mergeFlowOverride(data, override)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", overridePath, err)
+	}
+	return merged, nil
+}
+
+// mergeFlowOverride shallow-merges an override flow into a base flow: every
+// top-level field in the override replaces the base one, except steps,
+// which are merged by ID. An override step replaces the base step with the
+// same ID; steps with new IDs are appended.
+func mergeFlowOverride(base, override []byte) ([]byte, error) {
+	var b, o map[string]json.RawMessage
+	if err := json.Unmarshal(base, &b); err != nil {
+		return nil, fmt.Errorf("Failed to parse flow configuration: %v", err)
+	}
+	if err := json.Unmarshal(override, &o); err != nil {
+		return nil, fmt.Errorf("Failed to parse override: %v", err)
+	}
+
+	for key, value := range o {
+		if key != "steps" || b["steps"] == nil {
+			b[key] = value
+			continue
+		}
+		steps, err := mergeSteps(b["steps"], value)
+		if err != nil {
+			return nil, err
+		}
+		b[key] = steps
+	}
+	return json.Marshal(b)
+}
+
+// mergeSteps merges two JSON step arrays by step ID, keeping base order.
+func mergeSteps(base, override json.RawMessage) (json.RawMessage, error) {
+	type stepID struct {
+		ID string `json:"id"`
+	}
+	var bSteps, oSteps []json.RawMessage
+	if err := json.Unmarshal(base, &bSteps); err != nil {
+		return nil, fmt.Errorf("Failed to parse steps: %v", err)
+	}
+	if err := json.Unmarshal(override, &oSteps); err != nil {
+		return nil, fmt.Errorf("Failed to parse override steps: %v", err)
+	}
+
+	index := make(map[string]int)
+	for i, s := range bSteps {
+		var id stepID
+		if err := json.Unmarshal(s, &id); err != nil {
+			return nil, fmt.Errorf("Failed to parse steps: %v", err)
+		}
+		index[id.ID] = i
+	}
+	for _, s := range oSteps {
+		var id stepID
+		if err := json.Unmarshal(s, &id); err != nil {
+			return nil, fmt.Errorf("Failed to parse override steps: %v", err)
+		}
+		if i, ok := index[id.ID]; ok {
+			bSteps[i] = s
+		} else {
+			index[id.ID] = len(bSteps)
+			bSteps = append(bSteps, s)
+		}
+	}
+	return json.Marshal(bSteps)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMergeFlowOverride(t *testing.T) {
+	base := `{
+		"model": "gemini-2.5-pro",
+		"system_prompt": "Be thorough.",
+		"steps": [
+			{"id": "draft", "prompt": "Write {{input}}"},
+			{"id": "review", "prompt": "Review {{draft}}", "model": "gemini-2.5-pro"}
+		]
+	}`
+	override := `{
+		"model": "gemini-2.5-flash",
+		"steps": [
+			{"id": "review", "prompt": "Quick review of {{draft}}"},
+			{"id": "debug", "prompt": "Explain {{review}}"}
+		]
+	}`
+
+	merged, err := mergeFlowOverride([]byte(base), []byte(override))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	conf, err := parseFlow(merged)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if conf.Model != "gemini-2.5-flash" || conf.SystemPrompt != "Be thorough." {
+		t.Errorf("Expected model overridden and system_prompt kept, got %q / %q", conf.Model, conf.SystemPrompt)
+	}
+	if len(conf.Steps) != 3 {
+		t.Fatalf("Expected 3 steps, got %+v", conf.Steps)
+	}
+	if conf.Steps[0].Prompt != "Write {{input}}" {
+		t.Errorf("Expected draft to be kept, got %+v", conf.Steps[0])
+	}
+	if conf.Steps[1].Prompt != "Quick review of {{draft}}" || conf.Steps[1].Model != "" {
+		t.Errorf("Expected review to be replaced as a whole, got %+v", conf.Steps[1])
+	}
+	if conf.Steps[2].ID != "debug" {
+		t.Errorf("Expected debug to be appended, got %+v", conf.Steps[2])
+	}
+
+	if _, err := mergeFlowOverride([]byte(base), []byte(`{"steps": "nope"}`)); err == nil {
+		t.Error("Expected error for malformed override steps")
+	}
+}
+
+func TestApplyEnvOverride(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sum.json")
+	base := []byte(`{"model": "pro", "steps": [{"id": "s", "prompt": "hi"}]}`)
+	if err := os.WriteFile(filepath.Join(dir, "sum.dev.json"), []byte(`{"model": "flash"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("FAST_ENV", "")
+	if data, _ := applyEnvOverride(path, base); string(data) != string(base) {
+		t.Errorf("Expected no override without FAST_ENV, got %s", data)
+	}
+
+	t.Setenv("FAST_ENV", "prod")
+	if data, _ := applyEnvOverride(path, base); string(data) != string(base) {
+		t.Errorf("Expected no override without an override file, got %s", data)
+	}
+
+	t.Setenv("FAST_ENV", "dev")
+	data, err := applyEnvOverride(path, base)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if conf, _ := parseFlow(data); conf.Model != "flash" {
+		t.Errorf("Expected dev override, got %s", data)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// validatePostProcess checks that post_process, prepend, append and
+// split_output get the result itself: streamed results only hold a
+// reference to their temp file.
+func validatePostProcess(s Step) error {
+	if !s.StreamingToFile {
+		return nil
+	}
+	switch {
+	case s.PostProcess != "":
+		return fmt.Errorf("step '%s': post_process can't be combined with streaming_to_file", s.ID)
+	case s.Prepend != "" || s.Append != "":
+		return fmt.Errorf("step '%s': prepend and append can't be combined with streaming_to_file", s.ID)
+	case len(s.SplitOutput) > 0:
+		return fmt.Errorf("step '%s': split_output can't be combined with streaming_to_file", s.ID)
+	}
+	return nil
+}
+
+// postProcess pipes a step result through the shell command of its
+// post_process field and returns what the command prints. With
+// MOCK_FLOW=true the result is passed through unchanged.
+func postProcess(ctx context.Context, command, res string) (string, error) {
+	if os.Getenv("MOCK_FLOW") == "true" {
+		return res, nil
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = strings.NewReader(res)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("post_process %q: %w: %s", command, err, msg)
+		}
+		return "", fmt.Errorf("post_process %q: %w", command, err)
+	}
+	return stdout.String(), nil
+}
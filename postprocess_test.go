@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestPostProcess(t *testing.T) {
+	got, err := postProcess(context.Background(), "tr a-z A-Z", "hello world")
+	if err != nil || got != "HELLO WORLD" {
+		t.Errorf("postProcess() = %q, %v", got, err)
+	}
+
+	_, err = postProcess(context.Background(), "echo broken >&2; exit 3", "x")
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Errorf("Expected the command's stderr in the error, got %v", err)
+	}
+
+	t.Setenv("MOCK_FLOW", "true")
+	if got, _ := postProcess(context.Background(), "tr a-z A-Z", "hello"); got != "hello" {
+		t.Errorf("Expected MOCK_FLOW to skip post-processing, got %q", got)
+	}
+}
+
+func TestRunStepPostProcess(t *testing.T) {
+	originalCallGemini := callGemini
+	defer func() { callGemini = originalCallGemini }()
+	callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+		return "b\na\nc\n", 0, nil
+	}
+
+	run := newFlowRun("")
+	res, _, err := runStep(context.Background(), run, Config{}, Step{ID: "s", Prompt: "x", PostProcess: "sort | tr a-z A-Z"}, func(string) {})
+	if err != nil || res != "A\nB\nC" {
+		t.Errorf("runStep() = %q, %v", res, err)
+	}
+
+	_, _, err = runStep(context.Background(), run, Config{}, Step{ID: "s", Prompt: "x", PostProcess: "false"}, func(string) {})
+	if err == nil {
+		t.Error("Expected a failing post_process to fail the step")
+	}
+
+	if err := validatePostProcess(Step{ID: "s", PostProcess: "cat", StreamingToFile: true}); err == nil {
+		t.Error("Expected error combining post_process with streaming_to_file")
+	}
+	if err := validatePostProcess(Step{ID: "s", SplitOutput: map[string]string{"title": "(.+)"}, StreamingToFile: true}); err == nil {
+		t.Error("Expected error combining split_output with streaming_to_file")
+	}
+}
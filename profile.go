@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"strings"
+	"sync"
+	"time"
+)
+
+// profileName returns the file a --profile run writes to, e.g.
+// scope_cpu_20240102-150405.pprof in the current directory.
+func profileName(kind string, flowNames []string, now time.Time) string {
+	return fmt.Sprintf("%s_%s_%s.pprof", strings.Join(flowNames, "+"), kind, now.Format("20060102-150405"))
+}
+
+// startProfile implements --profile. A CPU profile runs from now until the
+// returned stop function is called; a heap profile is written by stop once
+// the flow is done. stop prints the profile path to stderr and is safe to
+// call more than once.
+func startProfile(kind string, flowNames []string) (stop func(), err error) {
+	path := profileName(kind, flowNames, time.Now())
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create profile: %w", err)
+	}
+	if kind == "cpu" {
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			os.Remove(path)
+			return nil, fmt.Errorf("failed to start CPU profile: %w", err)
+		}
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			if kind == "cpu" {
+				pprof.StopCPUProfile()
+			} else {
+				runtime.GC()
+				if err := pprof.WriteHeapProfile(f); err != nil {
+					fmt.Fprintf(os.Stderr, "❌ Failed to write heap profile: %v\n", err)
+				}
+			}
+			f.Close()
+			fmt.Fprintf(os.Stderr, "📈 %s profile written to %s\n", kind, path)
+		})
+	}, nil
+}
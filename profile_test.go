@@ -0,0 +1,42 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProfileName(t *testing.T) {
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	if got := profileName("cpu", []string{"scope"}, now); got != "scope_cpu_20240102-150405.pprof" {
+		t.Errorf("Unexpected name %q", got)
+	}
+	if got := profileName("mem", []string{"a", "b"}, now); got != "a+b_mem_20240102-150405.pprof" {
+		t.Errorf("Unexpected name %q", got)
+	}
+}
+
+func TestStartProfile(t *testing.T) {
+	t.Chdir(t.TempDir())
+	for _, kind := range []string{"cpu", "mem"} {
+		stop, err := startProfile(kind, []string{"flow"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		stop()
+		stop() // safe to call twice
+
+		entries, _ := os.ReadDir(".")
+		var found bool
+		for _, e := range entries {
+			info, _ := e.Info()
+			if info.Size() > 0 && strings.HasPrefix(e.Name(), "flow_"+kind+"_") {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected a non-empty %s profile, got %v", kind, entries)
+		}
+	}
+}
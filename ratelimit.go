@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// RateLimitConfig caps how fast `fast` calls an AI provider.
+type RateLimitConfig struct {
+	// RequestsPerMinute is shared by all steps and flows in one process
+	// (0 = no limit).
+	RequestsPerMinute int `json:"requests_per_minute"`
+}
+
+// RateLimiter hands out one token bucket per provider, so calls to one API
+// don't slow down another.
+type RateLimiter struct {
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+}
+
+var rateLimiter = &RateLimiter{limiters: make(map[string]*rate.Limiter)}
+
+// limiter returns the bucket for provider, or nil if calls aren't limited.
+func (l *RateLimiter) limiter(provider string) *rate.Limiter {
+	rpm := globalConfig.RateLimit.RequestsPerMinute
+	if rpm <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lim, ok := l.limiters[provider]
+	if !ok {
+		// A minute's worth of calls may go out at once, so parallel steps
+		// only slow down once they really exceed the limit
+		lim = rate.NewLimiter(rate.Every(time.Minute/time.Duration(rpm)), rpm)
+		l.limiters[provider] = lim
+	}
+	return lim
+}
+
+// Wait blocks until provider may be called again. waiting is called first
+// if the call has to be held back.
+func (l *RateLimiter) Wait(ctx context.Context, provider string, waiting func()) error {
+	lim := l.limiter(provider)
+	if lim == nil {
+		return nil
+	}
+	r := lim.Reserve()
+	delay := r.Delay()
+	if delay == 0 {
+		return nil
+	}
+	waiting()
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		r.Cancel()
+		return ctx.Err()
+	}
+}
+
+// waitForGemini applies the rate limit before a Gemini call and shows the
+// wait on the step while it lasts.
+func waitForGemini(ctx context.Context, progress func(string)) error {
+	waited := false
+	err := rateLimiter.Wait(ctx, "gemini", func() {
+		waited = true
+		progress("⏳ Rate limited, waiting…")
+	})
+	if waited {
+		progress("")
+	}
+	return err
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestRateLimiterWait(t *testing.T) {
+	original := globalConfig
+	defer func() { globalConfig = original }()
+	globalConfig.RateLimit.RequestsPerMinute = 1200 // one call every 50ms
+
+	l := &RateLimiter{limiters: make(map[string]*rate.Limiter)}
+	waits := 0
+	waiting := func() { waits++ }
+
+	// A burst up to the limit goes through without waiting
+	for range 1200 {
+		if err := l.Wait(context.Background(), "gemini", waiting); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if waits != 0 {
+		t.Errorf("Expected no waits within the burst, got %d", waits)
+	}
+
+	start := time.Now()
+	for range 2 {
+		if err := l.Wait(context.Background(), "gemini", waiting); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("Expected calls to be spread out, took %s", elapsed)
+	}
+	if waits != 2 {
+		t.Errorf("Expected 2 waits, got %d", waits)
+	}
+
+	// Each provider has its own bucket
+	if err := l.Wait(context.Background(), "other", func() { t.Error("Expected no wait for another provider") }); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(ctx, "gemini", waiting); err == nil {
+		t.Error("Expected an error when the context is cancelled while waiting")
+	}
+
+	globalConfig.RateLimit.RequestsPerMinute = 0
+	if lim := (&RateLimiter{limiters: make(map[string]*rate.Limiter)}).limiter("gemini"); lim != nil {
+		t.Error("Expected no limiter when rate limiting is off")
+	}
+}
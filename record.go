@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/term"
+)
+
+// castHeader is the first line of an asciicast v2 file.
+type castHeader struct {
+	Version   int    `json:"version"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Timestamp int64  `json:"timestamp"`
+	Title     string `json:"title,omitempty"`
+}
+
+// castRecorder is the terminal the TUI draws to. Everything written to it is
+// also appended to an asciicast v2 file as an output event, stamped with the
+// time since recording started, so playback keeps the pauses of the real run.
+type castRecorder struct {
+	// *os.File is the terminal; bubbletea needs its file descriptor to
+	// detect the window size.
+	*os.File
+
+	mu    sync.Mutex
+	cast  io.Writer
+	start time.Time
+	now   func() time.Time
+	err   error
+}
+
+func newCastRecorder(terminal *os.File, cast io.Writer, title string, width, height int, now func() time.Time) (*castRecorder, error) {
+	r := &castRecorder{File: terminal, cast: cast, start: now(), now: now}
+	header, err := json.Marshal(castHeader{Version: 2, Width: width, Height: height, Timestamp: r.start.Unix(), Title: title})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Fprintf(cast, "%s\n", header); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *castRecorder) Write(p []byte) (int, error) {
+	n, err := r.File.Write(p)
+	if n > 0 {
+		r.record(p[:n])
+	}
+	return n, err
+}
+
+// record appends an output event. The first failure is kept for stop and
+// doesn't disturb the TUI.
+func (r *castRecorder) record(p []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	elapsed := r.now().Sub(r.start).Seconds()
+	event, err := json.Marshal([]any{elapsed, "o", string(p)})
+	if err == nil {
+		_, err = fmt.Fprintf(r.cast, "%s\n", event)
+	}
+	r.err = err
+}
+
+// castPath returns the file --record NAME writes to.
+func castPath(name string) string {
+	if strings.HasSuffix(name, ".cast") {
+		return name
+	}
+	return name + ".cast"
+}
+
+// startRecording implements --record: the returned option makes the TUI
+// draw through a castRecorder writing to NAME.cast. stop closes the file
+// and reports where it went.
+func startRecording(name, title string) (opt tea.ProgramOption, stop func() error, err error) {
+	path := castPath(name)
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create recording: %w", err)
+	}
+	width, height, err := term.GetSize(os.Stdout.Fd())
+	if err != nil {
+		width, height = 80, 24
+	}
+	r, err := newCastRecorder(os.Stdout, f, title, width, height, time.Now)
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("failed to write recording: %w", err)
+	}
+
+	var once sync.Once
+	return tea.WithOutput(r), func() error {
+		once.Do(func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if cerr := f.Close(); r.err == nil {
+				r.err = cerr
+			}
+			if r.err == nil {
+				fmt.Fprintf(os.Stderr, "🎬 Recording written to %s (play it with `asciinema play %s`)\n", path, path)
+			}
+		})
+		if r.err != nil {
+			return fmt.Errorf("failed to write recording: %w", r.err)
+		}
+		return nil
+	}, nil
+}
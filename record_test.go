@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCastRecorder(t *testing.T) {
+	terminal, err := os.Create(filepath.Join(t.TempDir(), "tty"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer terminal.Close()
+
+	now := time.Unix(1700000000, 0)
+	clock := func() time.Time { return now }
+	var cast bytes.Buffer
+	r, err := newCastRecorder(terminal, &cast, "fast sum", 100, 30, clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Write([]byte("Running…\n"))
+	now = now.Add(1500 * time.Millisecond)
+	r.Write([]byte("\x1b[32m✓\x1b[0m done"))
+
+	lines := strings.Split(strings.TrimSpace(cast.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected a header and two events, got %q", cast.String())
+	}
+	var header castHeader
+	if err := json.Unmarshal([]byte(lines[0]), &header); err != nil {
+		t.Fatal(err)
+	}
+	if header != (castHeader{Version: 2, Width: 100, Height: 30, Timestamp: 1700000000, Title: "fast sum"}) {
+		t.Errorf("Unexpected header %+v", header)
+	}
+
+	var event []any
+	if err := json.Unmarshal([]byte(lines[2]), &event); err != nil {
+		t.Fatal(err)
+	}
+	if event[0] != 1.5 || event[1] != "o" || event[2] != "\x1b[32m✓\x1b[0m done" {
+		t.Errorf("Unexpected event %v", event)
+	}
+
+	// The terminal still gets everything
+	data, _ := os.ReadFile(terminal.Name())
+	if string(data) != "Running…\n\x1b[32m✓\x1b[0m done" {
+		t.Errorf("Expected output to reach the terminal, got %q", data)
+	}
+}
+
+func TestCastPath(t *testing.T) {
+	if castPath("demo") != "demo.cast" || castPath("demo.cast") != "demo.cast" {
+		t.Errorf("Unexpected cast paths %s, %s", castPath("demo"), castPath("demo.cast"))
+	}
+}
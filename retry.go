@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// retryBackoff is the wait before the first retry; it doubles every time.
+var retryBackoff = time.Second
+
+// validateRetry checks the max_retries and retry_on of a step.
+func validateRetry(s Step) error {
+	if s.MaxRetries < 0 {
+		return fmt.Errorf("step '%s': max_retries must not be negative", s.ID)
+	}
+	if len(s.RetryOn) > 0 && s.MaxRetries == 0 {
+		return fmt.Errorf("step '%s': retry_on needs max_retries", s.ID)
+	}
+	for _, pattern := range s.RetryOn {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("step '%s': retry_on %q: %v", s.ID, pattern, err)
+		}
+	}
+	return nil
+}
+
+// shouldRetry reports whether a failed attempt may be retried: any error
+// without retry_on, otherwise only errors whose message matches one of its
+// patterns.
+func shouldRetry(s Step, err error) bool {
+	if len(s.RetryOn) == 0 {
+		return true
+	}
+	msg := err.Error()
+	for _, pattern := range s.RetryOn {
+		if re, err := regexp.Compile(pattern); err == nil && re.MatchString(msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// runStepWithRetries runs a step up to 1+max_retries times, waiting longer
+// before each retry. The returned log counts the retries and a final
+// *StepError carries the attempt that failed.
+func runStepWithRetries(ctx context.Context, run *FlowRun, conf Config, s Step, progress func(string)) (string, StepLog, error) {
+	backoff := retryBackoff
+	for attempt := 1; ; attempt++ {
+		res, log, err := runStep(ctx, run, conf, s, progress)
+		log.Retries = attempt - 1
+		if err == nil || ctx.Err() != nil || attempt > s.MaxRetries || !shouldRetry(s, err) {
+			if se, ok := err.(*StepError); ok {
+				se.Attempt = attempt
+			}
+			if attempt > 1 {
+				progress("")
+			}
+			return res, log, err
+		}
+
+		progress(fmt.Sprintf("Retrying (%d/%d)…", attempt, s.MaxRetries))
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return res, log, err
+		}
+		backoff *= 2
+	}
+}
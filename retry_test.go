@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestRunStepWithRetries(t *testing.T) {
+	originalCallGemini, originalBackoff := callGemini, retryBackoff
+	defer func() { callGemini, retryBackoff = originalCallGemini, originalBackoff }()
+	retryBackoff = 0
+
+	calls := 0
+	callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+		calls++
+		if calls < 3 {
+			return "", 0, nil
+		}
+		return "finally", 0, nil
+	}
+
+	tests := []struct {
+		name      string
+		step      Step
+		wantCalls int
+		wantErr   bool
+	}{
+		{"no retries", Step{}, 1, true},
+		{"retry any failure", Step{MaxRetries: 2}, 3, false},
+		{"too few retries", Step{MaxRetries: 1}, 2, true},
+		{"matching retry_on", Step{MaxRetries: 5, RetryOn: []string{"rate limit", "no result"}}, 3, false},
+		{"non-matching retry_on", Step{MaxRetries: 5, RetryOn: []string{"rate limit", "503"}}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = 0
+			tt.step.ID, tt.step.Prompt = "s", "hi"
+			res, log, err := runStepWithRetries(context.Background(), newFlowRun(""), Config{}, tt.step, func(string) {})
+			if calls != tt.wantCalls {
+				t.Errorf("Expected %d calls, got %d", tt.wantCalls, calls)
+			}
+			if log.Retries != calls-1 {
+				t.Errorf("Expected %d retries in the log, got %d", calls-1, log.Retries)
+			}
+			if tt.wantErr {
+				var se *StepError
+				if !errors.As(err, &se) || se.Attempt != calls {
+					t.Errorf("Expected a StepError for attempt %d, got %v", calls, err)
+				}
+				return
+			}
+			if err != nil || res != "finally" {
+				t.Errorf("got %q, %v", res, err)
+			}
+		})
+	}
+}
+
+func TestRetryOnAPIErrors(t *testing.T) {
+	originalCallGemini, originalBackoff := callGemini, retryBackoff
+	defer func() { callGemini, retryBackoff = originalCallGemini, originalBackoff }()
+	retryBackoff = 0
+
+	var errs []error
+	calls := 0
+	callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+		calls++
+		if calls <= len(errs) {
+			return "", 0, errs[calls-1]
+		}
+		return "finally", 0, nil
+	}
+	rateLimited := &apiError{StatusCode: 429, Status: "429 Too Many Requests", Message: "Resource has been exhausted"}
+	unavailable := &apiError{StatusCode: 503, Status: "503 Service Unavailable", Message: "The model is overloaded"}
+	denied := &apiError{StatusCode: 403, Status: "403 Forbidden", Message: "API key not valid"}
+
+	tests := []struct {
+		name      string
+		errs      []error
+		wantCalls int
+		wantErr   error
+	}{
+		{"rate limit and 503 are retried", []error{rateLimited, unavailable}, 3, nil},
+		{"auth failure is not retried", []error{denied}, 1, denied},
+		{"retries stop at an auth failure", []error{rateLimited, denied}, 2, denied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs, calls = tt.errs, 0
+			s := Step{ID: "s", Prompt: "hi", MaxRetries: 3, RetryOn: []string{"rate limit", "503"}}
+			res, _, err := runStepWithRetries(context.Background(), newFlowRun(""), Config{}, s, func(string) {})
+			if calls != tt.wantCalls {
+				t.Errorf("Expected %d calls, got %d", tt.wantCalls, calls)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil || res != "finally" {
+				t.Errorf("got %q, %v", res, err)
+			}
+		})
+	}
+}
+
+func TestNewAPIError(t *testing.T) {
+	resp := &http.Response{StatusCode: 429, Status: "429 Too Many Requests"}
+	err := newAPIError(resp, []byte(`{"error": {"code": 429, "message": "Quota exceeded", "status": "RESOURCE_EXHAUSTED"}}`))
+	if want := "API error (429 Too Many Requests): Quota exceeded (rate limit)"; err.Error() != want {
+		t.Errorf("Expected %q, got %q", want, err.Error())
+	}
+	resp = &http.Response{StatusCode: 502, Status: "502 Bad Gateway"}
+	if err := newAPIError(resp, []byte("upstream down\n")); err.Message != "upstream down" {
+		t.Errorf("Expected the raw body as message, got %q", err.Message)
+	}
+}
+
+func TestValidateRetry(t *testing.T) {
+	for _, s := range []Step{
+		{ID: "a", MaxRetries: -1},
+		{ID: "a", RetryOn: []string{"503"}},
+		{ID: "a", MaxRetries: 1, RetryOn: []string{"("}},
+	} {
+		if err := validateRetry(s); err == nil {
+			t.Errorf("Expected error for %+v", s)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// maxServeBody caps the size of a webhook request body.
+const maxServeBody = 1 << 20
+
+// runServeCommand implements `fast serve [--host 127.0.0.1] [--port 8080]`,
+// which runs flows headlessly for POST /run/<flowname> requests.
+func runServeCommand(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	host := fs.String("host", "127.0.0.1", "address to listen on (0.0.0.0 for all interfaces)")
+	port := fs.Int("port", 8080, "port to listen on")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if globalConfig.WebhookToken == "" {
+		return errors.New("set webhook_token in ~/fast-flows/config.json before running fast serve")
+	}
+
+	addr := net.JoinHostPort(*host, strconv.Itoa(*port))
+	fmt.Printf("🚀 Serving flows on %s (POST /run/<flowname>)\n", addr)
+	return http.ListenAndServe(addr, serveHandler(globalConfig.WebhookToken))
+}
+
+// serveHandler routes webhook requests. Every request gets its own FlowRun,
+// so concurrent runs never share results or input.
+func serveHandler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /run/{flow}", func(w http.ResponseWriter, r *http.Request) {
+		want := "Bearer " + token
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(want)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid or missing bearer token"})
+			return
+		}
+
+		// The name ends up in a file path; keep it inside the flows folders
+		flowName := r.PathValue("flow")
+		if flowName == "" || strings.ContainsAny(flowName, `/\`) || strings.Contains(flowName, "..") {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid flow name"})
+			return
+		}
+
+		var body struct {
+			Input string `json:"input"`
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxServeBody)
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeJSON(w, http.StatusRequestEntityTooLarge, map[string]string{"error": fmt.Sprintf("request body is larger than %d bytes", maxServeBody)})
+				return
+			}
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid JSON body: " + err.Error()})
+			return
+		}
+
+		result, err := runHeadless(r.Context(), flowName, body.Input)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+		case err != nil:
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		default:
+			writeJSON(w, http.StatusOK, map[string]string{"result": result})
+		}
+	})
+	return mux
+}
+
+// runHeadless runs a flow without the TUI, saves its session log and returns
+// the final step's result.
+func runHeadless(ctx context.Context, flowName, input string) (string, error) {
+	_, conf, err := loadFlow(flowName, Options{})
+	if err != nil {
+		return "", err
+	}
+
+	fmt.Printf("▶ Running %s\n", flowName)
+	run := newFlowRun(input)
+	run.flowName = flowName
+	if run.streamLog, err = openConfiguredStreamLog(""); err != nil {
+		return "", err
+	}
+	defer run.streamLog.Close()
+	defer run.removeFileResults()
+	err = runFlow(ctx, run, conf, nil)
+	saveSessionLog(flowName, input, "", "", conf, run.Results(), nil, run.collectStepLogs(conf))
+	if err != nil {
+		fmt.Printf("❌ %s: %v\n", flowName, err)
+		return "", err
+	}
+	fmt.Printf("✓ %s finished\n", flowName)
+	return run.GetResult(conf.Steps[len(conf.Steps)-1].ID), nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeHandler(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	originalCallGemini := callGemini
+	defer func() { callGemini = originalCallGemini }()
+	callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+		return "reply", 0, nil
+	}
+
+	srv := httptest.NewServer(serveHandler("secret"))
+	defer srv.Close()
+
+	post := func(path, token, body string) (int, map[string]string) {
+		req, _ := http.NewRequest(http.MethodPost, srv.URL+path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var out map[string]string
+		json.NewDecoder(resp.Body).Decode(&out)
+		return resp.StatusCode, out
+	}
+
+	if code, _ := post("/run/reply", "wrong", `{"input": "hi"}`); code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for a bad token, got %d", code)
+	}
+	if code, _ := post("/run/does-not-exist", "secret", `{}`); code != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unknown flow, got %d", code)
+	}
+	if code, _ := post("/run/reply", "secret", `not json`); code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a bad body, got %d", code)
+	}
+	for _, path := range []string{"/run/..%2F..%2Fsecret", "/run/a%2Fb", "/run/%2E%2E", "/run/a%5Cb"} {
+		if code, _ := post(path, "secret", `{}`); code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s, got %d", path, code)
+		}
+	}
+	big := `{"input": "` + strings.Repeat("x", maxServeBody) + `"}`
+	if code, _ := post("/run/reply", "secret", big); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for an oversized body, got %d", code)
+	}
+	code, out := post("/run/reply", "secret", `{"input": "I am sick"}`)
+	if code != http.StatusOK || out["result"] != "reply" {
+		t.Errorf("Expected 200 with result, got %d %v", code, out)
+	}
+}
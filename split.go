@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// validateSplitOutput checks the split_output patterns of every step. Keys
+// become results of their own, so they must not clash with step IDs, input
+// tags or each other.
+func validateSplitOutput(steps []Step) error {
+	taken := make(map[string]string)
+	for _, s := range steps {
+		taken[s.ID] = "step '" + s.ID + "'"
+	}
+	for _, s := range steps {
+		for _, key := range setFlags(s.SplitOutput).Keys() {
+			if key == "" || isInputTag(key) {
+				return fmt.Errorf("step '%s': split_output key %q is reserved", s.ID, key)
+			}
+			if other, ok := taken[key]; ok {
+				return fmt.Errorf("step '%s': split_output key '%s' is already used by %s", s.ID, key, other)
+			}
+			taken[key] = "step '" + s.ID + "'"
+			if _, err := regexp.Compile(s.SplitOutput[key]); err != nil {
+				return fmt.Errorf("step '%s': split_output '%s': %v", s.ID, key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// splitOutput extracts the split_output results of a step from its result.
+// Each key gets the first capture group of its pattern, or the whole match
+// if the pattern has no group. A pattern that doesn't match, or only
+// matches an empty value, is an error: steps reading that key would
+// otherwise wait for it forever.
+func splitOutput(s Step, res string) (map[string]string, error) {
+	if len(s.SplitOutput) == 0 {
+		return nil, nil
+	}
+	parts := make(map[string]string, len(s.SplitOutput))
+	for _, key := range setFlags(s.SplitOutput).Keys() {
+		re, err := regexp.Compile(s.SplitOutput[key])
+		if err != nil {
+			return nil, fmt.Errorf("split_output '%s': %w", key, err)
+		}
+		m := re.FindStringSubmatch(res)
+		if m == nil {
+			return nil, fmt.Errorf("split_output '%s': pattern %q didn't match the result", key, s.SplitOutput[key])
+		}
+		value := m[0]
+		if len(m) > 1 {
+			value = m[1]
+		}
+		if value == "" {
+			return nil, fmt.Errorf("split_output '%s': pattern %q matched an empty value", key, s.SplitOutput[key])
+		}
+		parts[key] = value
+	}
+	return parts, nil
+}
+
+// splitOwners maps every split_output key to the step that produces it.
+func splitOwners(steps []Step) map[string]string {
+	owners := make(map[string]string)
+	for _, s := range steps {
+		for key := range s.SplitOutput {
+			owners[key] = s.ID
+		}
+	}
+	return owners
+}
+
+// flowDependencies is stepDependencies with split_output keys replaced by
+// the step producing them, for code that needs real step IDs.
+func flowDependencies(s Step, owners map[string]string) []string {
+	deps := stepDependencies(s)
+	for i, dep := range deps {
+		if owner, ok := owners[dep]; ok {
+			deps[i] = owner
+		}
+	}
+	return deps
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSplitOutput(t *testing.T) {
+	res := "Title: Quarterly report\nSummary: Sales went up.\nTags: a, b"
+	tests := []struct {
+		name    string
+		pattern string
+		want    string
+		wantErr string
+	}{
+		{"capture group", `(?m)^Title: (.+)$`, "Quarterly report", ""},
+		{"no group uses whole match", `Sales \w+ up`, "Sales went up", ""},
+		{"first group only", `(?m)^(Tags): (.+)$`, "Tags", ""},
+		{"first match wins", `(?m)^\w+: (\S+)`, "Quarterly", ""},
+		{"dot doesn't cross lines", `Title: (.+)`, "Quarterly report", ""},
+		{"multi-line with (?s)", `(?s)Summary: (.+)`, "Sales went up.\nTags: a, b", ""},
+		{"optional group unmatched", `(?m)^Title: (x)?`, "", "empty value"},
+		{"no match", `Author: (.+)`, "", "didn't match"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts, err := splitOutput(Step{ID: "s", SplitOutput: map[string]string{"part": tt.pattern}}, res)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if parts["part"] != tt.want {
+				t.Errorf("got %q, want %q", parts["part"], tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSplitOutput(t *testing.T) {
+	bad := [][]Step{
+		{{ID: "a", SplitOutput: map[string]string{"b": "x"}}, {ID: "b"}},
+		{{ID: "a", SplitOutput: map[string]string{"t": "x"}}, {ID: "b", SplitOutput: map[string]string{"t": "y"}}},
+		{{ID: "a", SplitOutput: map[string]string{"input": "x"}}},
+		{{ID: "a", SplitOutput: map[string]string{"t": "("}}},
+	}
+	for _, steps := range bad {
+		if err := validateSplitOutput(steps); err == nil {
+			t.Errorf("Expected error for %+v", steps)
+		}
+	}
+}
+
+func TestRunFlowSplitOutput(t *testing.T) {
+	originalCallGemini := callGemini
+	defer func() { callGemini = originalCallGemini }()
+	callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+		if prompt == "write" {
+			return "Title: Hello\n\nBody text", 0, nil
+		}
+		return "got " + prompt, 0, nil
+	}
+
+	conf := Config{Steps: []Step{
+		{ID: "use", Prompt: "{{title}}"},
+		{ID: "draft", Prompt: "write", SplitOutput: map[string]string{"title": `(?m)^Title: (.+)$`}},
+	}}
+	run := newFlowRun("")
+	if err := runFlow(context.Background(), run, conf, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := run.GetResult("use"); got != "got Hello" {
+		t.Errorf("Expected the split result to be used, got %q", got)
+	}
+	if steps := buildSteps(conf); steps[0].ParentID != "draft" {
+		t.Errorf("Expected use to hang off draft in the tree, got %q", steps[0].ParentID)
+	}
+}
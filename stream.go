@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// File results hold the path of a file with the actual step output, written
+// as {{file:/path}}. They keep large outputs out of memory until needed.
+const (
+	fileResultPrefix = "{{file:"
+	fileResultSuffix = "}}"
+)
+
+func fileResult(path string) string {
+	return fileResultPrefix + path + fileResultSuffix
+}
+
+// addFileResult records that step id streamed its output to path and returns
+// the step result pointing at it. A file from an earlier attempt of the step
+// is removed.
+func (r *FlowRun) addFileResult(id, path string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if old, ok := r.fileResults[id]; ok && old != path {
+		os.Remove(old)
+	}
+	r.fileResults[id] = path
+	return fileResult(path)
+}
+
+// resolve returns the text of res, a result of step id. Only files this run
+// streamed itself are read; a result that merely looks like {{file:/path}},
+// e.g. a model answer or a --set value, is returned as is. The caller holds
+// r.mu.
+func (r *FlowRun) resolve(id, res string) string {
+	path, ok := r.fileResults[id]
+	if !ok || res != fileResult(path) {
+		return res
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return res
+	}
+	return string(data)
+}
+
+// resultText returns the text of step id's result. The caller holds r.mu.
+func (r *FlowRun) resultText(id string) string {
+	return r.resolve(id, r.results[id])
+}
+
+// resolveStepResult is resolve for callers that don't hold r.mu.
+func (r *FlowRun) resolveStepResult(id, res string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.resolve(id, res)
+}
+
+// removeFileResults deletes the files of streaming_to_file steps once the
+// run no longer needs them.
+func (r *FlowRun) removeFileResults() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clearFileResults()
+}
+
+// clearFileResults is removeFileResults for callers that hold r.mu.
+func (r *FlowRun) clearFileResults() {
+	for _, path := range r.fileResults {
+		os.Remove(path)
+	}
+	r.fileResults = make(map[string]string)
+}
+
+// streamToFile runs a streaming_to_file step: the response is written to a
+// temp file as it arrives and the path of that file is returned. Every chunk
+// is also copied to tee.
+func streamToFile(ctx context.Context, model, sys, prompt string, images []InlineImage, stepID string, tee io.Writer) (string, int, error) {
+	f, err := os.CreateTemp("", "fast-"+stepID+"-*.txt")
+	if err != nil {
+		return "", 0, err
+	}
+	tokens, err := streamGemini(ctx, model, sys, prompt, images, io.MultiWriter(f, tee))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		if info, statErr := os.Stat(f.Name()); statErr == nil && info.Size() == 0 {
+			err = errNoResult
+		}
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", tokens, err
+	}
+	return f.Name(), tokens, nil
+}
+
+// streamGemini calls streamGenerateContent and writes the text of every
+// chunk to w. It returns the total token count reported by the API.
+var streamGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage, w io.Writer) (int, error) {
+	if os.Getenv("MOCK_FLOW") == "true" {
+		_, err := io.WriteString(w, "Mocked response for: "+prompt)
+		return 0, err
+	}
+	apiKey := requireAPIKey()
+	url := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:streamGenerateContent?alt=sse&key=%s", model, apiKey)
+
+	sys, cached := promptCaches.use(ctx, model, sys, apiKey)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(geminiPayload(sys, prompt, images, modelConfigFrom(ctx), cached)))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests {
+		apiKeys.markRateLimited(apiKey)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return 0, newAPIError(resp, body)
+	}
+	return readGeminiStream(resp.Body, w)
+}
+
+// readGeminiStream parses server-sent events from streamGenerateContent.
+func readGeminiStream(r io.Reader, w io.Writer) (int, error) {
+	var chunk struct {
+		Candidates []struct {
+			Content struct {
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"content"`
+		} `json:"candidates"`
+		UsageMetadata struct {
+			TotalTokenCount int `json:"totalTokenCount"`
+		} `json:"usageMetadata"`
+	}
+
+	tokens := 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		chunk.Candidates = nil
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return tokens, fmt.Errorf("failed to parse stream chunk: %w", err)
+		}
+		for _, c := range chunk.Candidates {
+			for _, p := range c.Content.Parts {
+				if _, err := io.WriteString(w, p.Text); err != nil {
+					return tokens, err
+				}
+			}
+		}
+		if chunk.UsageMetadata.TotalTokenCount > 0 {
+			tokens = chunk.UsageMetadata.TotalTokenCount
+		}
+	}
+	return tokens, scanner.Err()
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReadGeminiStream(t *testing.T) {
+	stream := `data: {"candidates": [{"content": {"parts": [{"text": "Hello, "}]}}]}
+
+data: {"candidates": [{"content": {"parts": [{"text": "world"}]}}], "usageMetadata": {"totalTokenCount": 12}}
+
+`
+	var b strings.Builder
+	tokens, err := readGeminiStream(strings.NewReader(stream), &b)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if b.String() != "Hello, world" || tokens != 12 {
+		t.Errorf("Expected 'Hello, world' / 12 tokens, got %q / %d", b.String(), tokens)
+	}
+}
+
+func TestRunFlowStreamingToFile(t *testing.T) {
+	originalCallGemini := callGemini
+	originalStreamGemini := streamGemini
+	defer func() {
+		callGemini = originalCallGemini
+		streamGemini = originalStreamGemini
+	}()
+	streamGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage, w io.Writer) (int, error) {
+		_, err := io.WriteString(w, "a very long document")
+		return 0, err
+	}
+	var gotPrompt string
+	callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+		gotPrompt = prompt
+		return "done", 0, nil
+	}
+
+	run := newFlowRun("")
+	conf := Config{Steps: []Step{
+		{ID: "big", Prompt: "Write a lot", StreamingToFile: true},
+		{ID: "next", Prompt: "Read {{big}}"},
+	}}
+	if err := runFlow(context.Background(), run, conf, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	path := run.fileResults["big"]
+	if path == "" || run.results["big"] != fileResult(path) {
+		t.Fatalf("Expected a file result, got %q", run.results["big"])
+	}
+	defer os.Remove(path)
+
+	if gotPrompt != "Read a very long document" {
+		t.Errorf("Expected downstream step to read the file, got %q", gotPrompt)
+	}
+	if run.GetResult("big") != "a very long document" {
+		t.Errorf("Expected GetResult to resolve the file, got %q", run.GetResult("big"))
+	}
+	if run.Results()["big"] != "a very long document" {
+		t.Errorf("Expected Results to resolve the file, got %q", run.Results()["big"])
+	}
+
+	run.removeFileResults()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the streamed file to be removed, got %v", err)
+	}
+}
+
+func TestFileLookingResultsAreNotRead(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "secret-*.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("top secret")
+	f.Close()
+
+	run := newFlowRun("")
+	run.pin(map[string]string{"answer": fileResult(f.Name())})
+	if got := run.fillTags("Got {{answer}}"); got != "Got "+fileResult(f.Name()) {
+		t.Errorf("Expected a file tag from --set to stay as is, got %q", got)
+	}
+	if got := run.GetResult("answer"); got != fileResult(f.Name()) {
+		t.Errorf("Expected GetResult not to read the file, got %q", got)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// StreamLog appends step events to a file as NDJSON, one line per event, so
+// headless runs can be followed with `tail -f`. A nil *StreamLog logs
+// nothing.
+type StreamLog struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// streamEvent is one line of the stream log. Type is start, token, done or
+// error; Ts is in Unix milliseconds.
+type streamEvent struct {
+	Type string `json:"type"`
+	Step string `json:"step"`
+	Text string `json:"text,omitempty"`
+	Ts   int64  `json:"ts"`
+}
+
+// openStreamLog opens path for appending, creating it and its directory.
+func openStreamLog(path string) (*StreamLog, error) {
+	path = expandHome(path)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &StreamLog{f: f}, nil
+}
+
+// openConfiguredStreamLog opens the --stream-log file, falling back to
+// streaming_log_file from config.json. It returns nil if neither is set.
+func openConfiguredStreamLog(flagPath string) (*StreamLog, error) {
+	path := flagPath
+	if path == "" {
+		path = globalConfig.StreamingLogFile
+	}
+	if path == "" {
+		return nil, nil
+	}
+	return openStreamLog(path)
+}
+
+// Event writes one event straight to the file, without buffering. Write
+// errors are ignored; monitoring must not break the flow.
+func (l *StreamLog) Event(typ, step, text string) {
+	if l == nil {
+		return
+	}
+	line, _ := json.Marshal(streamEvent{Type: typ, Step: step, Text: text, Ts: time.Now().UnixMilli()})
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.f.Write(append(line, '\n'))
+}
+
+// tokens returns a writer that logs every write as a token event of step.
+func (l *StreamLog) tokens(step string) io.Writer {
+	if l == nil {
+		return io.Discard
+	}
+	return tokenWriter{l, step}
+}
+
+func (l *StreamLog) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.f.Close()
+}
+
+type tokenWriter struct {
+	log  *StreamLog
+	step string
+}
+
+func (w tokenWriter) Write(p []byte) (int, error) {
+	w.log.Event("token", w.step, string(p))
+	return len(p), nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStreamLog(t *testing.T) {
+	originalCallGemini, originalStreamGemini := callGemini, streamGemini
+	defer func() { callGemini, streamGemini = originalCallGemini, originalStreamGemini }()
+	callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+		return "plain answer", 0, nil
+	}
+	streamGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage, w io.Writer) (int, error) {
+		io.WriteString(w, "hel")
+		io.WriteString(w, "lo")
+		return 0, nil
+	}
+
+	path := filepath.Join(t.TempDir(), "logs", "events.ndjson")
+	log, err := openStreamLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := newFlowRun("")
+	run.streamLog = log
+	conf := Config{Steps: []Step{
+		{ID: "a", Prompt: "hi"},
+		{ID: "b", Prompt: "{{a}}", StreamingToFile: true},
+	}}
+	if err := runFlow(context.Background(), run, conf, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	log.Close()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var got []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e streamEvent
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		if e.Ts == 0 {
+			t.Errorf("Expected a timestamp in %q", scanner.Text())
+		}
+		got = append(got, e.Type+":"+e.Step+":"+e.Text)
+	}
+	want := "start:a:|token:a:plain answer|done:a:|start:b:|token:b:hel|token:b:lo|done:b:"
+	if strings.Join(got, "|") != want {
+		t.Errorf("events = %s, want %s", strings.Join(got, "|"), want)
+	}
+
+	var nilLog *StreamLog
+	nilLog.Event("start", "a", "") // must not panic
+}
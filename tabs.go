@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	tabStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Padding(0, 1)
+	activeTabStyle = lipgloss.NewStyle().Bold(true).Reverse(true).Padding(0, 1)
+)
+
+// TabMsg routes a message to the flow shown in tab Tab.
+type TabMsg struct {
+	Tab int
+	Msg tea.Msg
+}
+
+// tabSender delivers messages from a flow's goroutine to its own tab.
+type tabSender struct {
+	p   *tea.Program
+	tab int
+}
+
+func (s tabSender) Send(msg tea.Msg) {
+	s.p.Send(TabMsg{Tab: s.tab, Msg: msg})
+}
+
+// TabModel shows several flows at once, one per tab.
+type TabModel struct {
+	Tabs   []FlowModel
+	Active int
+}
+
+func (m TabModel) Init() tea.Cmd {
+	var cmds []tea.Cmd
+	for i, t := range m.Tabs {
+		cmds = append(cmds, wrapTabCmd(i, t.Init()))
+	}
+	return tea.Batch(cmds...)
+}
+
+func (m TabModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		// Leave room for the tab bar
+		sized := tea.WindowSizeMsg{Width: msg.Width, Height: msg.Height - 2}
+		for i := range m.Tabs {
+			m.updateTab(i, sized)
+		}
+		return m, nil
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c":
+			return m, tea.Quit
+		case "q":
+			if !m.Tabs[m.Active].ShowHelp {
+				return m, tea.Quit
+			}
+		case "tab":
+			m.Active = (m.Active + 1) % len(m.Tabs)
+			return m, nil
+		case "shift+tab":
+			m.Active = (m.Active + len(m.Tabs) - 1) % len(m.Tabs)
+			return m, nil
+		}
+		return m, m.updateTab(m.Active, msg)
+	case TabMsg:
+		if msg.Tab < 0 || msg.Tab >= len(m.Tabs) {
+			return m, nil
+		}
+		cmd := m.updateTab(msg.Tab, msg.Msg)
+		if m.allFinished() {
+			return m, tea.Quit
+		}
+		return m, cmd
+	}
+	return m, nil
+}
+
+// updateTab passes msg to one flow. A flow that finishes would normally quit
+// the program, so its command is dropped and the tab stays on screen until
+// every flow is done.
+func (m *TabModel) updateTab(i int, msg tea.Msg) tea.Cmd {
+	updated, cmd := m.Tabs[i].Update(msg)
+	m.Tabs[i] = updated.(FlowModel)
+	if m.Tabs[i].Quitting {
+		return nil
+	}
+	return wrapTabCmd(i, cmd)
+}
+
+func (m TabModel) allFinished() bool {
+	for _, t := range m.Tabs {
+		if !t.Quitting {
+			return false
+		}
+	}
+	return true
+}
+
+// wrapTabCmd tags the messages produced by cmd with the tab they belong to.
+func wrapTabCmd(tab int, cmd tea.Cmd) tea.Cmd {
+	if cmd == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		msg := cmd()
+		switch msg := msg.(type) {
+		case nil:
+			return nil
+		case tea.BatchMsg:
+			cmds := make([]tea.Cmd, len(msg))
+			for i, c := range msg {
+				cmds[i] = wrapTabCmd(tab, c)
+			}
+			return tea.BatchMsg(cmds)
+		}
+		return TabMsg{Tab: tab, Msg: msg}
+	}
+}
+
+func (m TabModel) View() string {
+	var tabs []string
+	for i, t := range m.Tabs {
+		var icon string
+		switch {
+		case t.Err != nil || t.Aborted || t.TimedOut:
+			icon = crossMark.String()
+		case t.Result != "":
+			icon = checkMark.String()
+		default:
+			icon = t.Spinner.View()
+		}
+		label := fmt.Sprintf("%s %s", icon, t.FlowName)
+		if i == m.Active {
+			tabs = append(tabs, activeTabStyle.Render(label))
+		} else {
+			tabs = append(tabs, tabStyle.Render(label))
+		}
+	}
+	bar := lipgloss.JoinHorizontal(lipgloss.Top, tabs...) + subtleStyle.Render("  tab/shift+tab switch flows")
+	return bar + "\n" + strings.TrimPrefix(m.Tabs[m.Active].View(), "\n")
+}
+
+// runMultipleFlows implements `fast run <flow1> <flow2> ...`: every flow runs
+// in its own goroutine and gets its own tab and session log.
+func runMultipleFlows(opts Options, input string, theme ThemeConfig) error {
+	type flowJob struct {
+		name string
+		conf Config
+		run  *FlowRun
+	}
+
+	streamLog, err := openConfiguredStreamLog(opts.StreamLog)
+	if err != nil {
+		return err
+	}
+	defer streamLog.Close()
+
+	var jobs []flowJob
+	for _, name := range opts.FlowNames {
+		_, conf, err := loadFlow(name, opts)
+		if err != nil {
+			return err
+		}
+		run := newFlowRun(input)
+		run.flowName = name
+		run.streamLog = streamLog
+		run.pin(opts.Set)
+		run.mock(opts.Mock)
+		if opts.ParallelLimit > 0 {
+			run.ParallelLimit = opts.ParallelLimit
+		}
+		jobs = append(jobs, flowJob{name, conf, run})
+		defer run.removeFileResults()
+	}
+
+	out, _ := exec.Command("pbpaste").Output()
+	clipboardContent := string(out)
+
+	ctx, cancel := flowContext(opts)
+	defer cancel()
+
+	var model TabModel
+	var dones []chan struct{}
+	for _, j := range jobs {
+		fm := InitialModel(j.conf, j.name, clipboardContent, input, theme)
+		fm.NoPreview = opts.NoPreview
+		fm.Run = j.run
+		fm.Done = make(chan struct{})
+		if opts.Timeout > 0 {
+			fm.Deadline = time.Now().Add(opts.Timeout)
+		}
+		dones = append(dones, fm.Done)
+		model.Tabs = append(model.Tabs, fm)
+	}
+
+	var programOpts []tea.ProgramOption
+	if opts.Record != "" {
+		opt, stop, err := startRecording(opts.Record, "fast run "+strings.Join(opts.FlowNames, " "))
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := stop(); err != nil {
+				fmt.Fprintf(os.Stderr, "❌ %v\n", err)
+			}
+		}()
+		programOpts = append(programOpts, opt)
+	}
+	p := tea.NewProgram(model, programOpts...)
+
+	for i, j := range jobs {
+		// Pressing `a` aborts only the flow in the active tab
+		flowCtx, flowCancel := context.WithCancel(ctx)
+		go func(done chan struct{}) {
+			<-done
+			flowCancel()
+		}(dones[i])
+
+		go func(tab int, j flowJob) {
+			sender := tabSender{p: p, tab: tab}
+			saveLog := func() {
+				logInput := input
+				if opts.InputFile != "" {
+					logInput = ""
+				}
+				saveSessionLog(j.name, logInput, opts.InputFile, clipboardContent, j.conf, j.run.Results(), opts.Set.Keys(), j.run.collectStepLogs(j.conf))
+			}
+
+			if err := runFlow(flowCtx, j.run, j.conf, sender); err != nil {
+				saveLog()
+				notifyFlowDone(j.name, j.conf, "", err)
+				if errors.Is(err, context.DeadlineExceeded) {
+					sender.Send(FlowTimeoutMsg{})
+				} else {
+					sender.Send(FlowFinishedMsg{})
+				}
+				return
+			}
+			finalResult := j.run.GetResult(j.conf.Steps[len(j.conf.Steps)-1].ID)
+			saveLog()
+			notifyFlowDone(j.name, j.conf, finalResult, nil)
+			sender.Send(FlowFinishedMsg{Result: finalResult})
+		}(i, j)
+	}
+
+	holdLogNoticesDuringTUI()
+	final, err := p.Run()
+	flushLogNotices()
+	if err != nil {
+		return fmt.Errorf("Alas, there's been an error: %v", err)
+	}
+	// Only the tab on screen at the end is copied, not whichever flow
+	// happened to finish last
+	tm := final.(TabModel)
+	if result := tm.Tabs[tm.Active].Result; result != "" {
+		copyToClipboard(result)
+	}
+	for _, t := range tm.Tabs {
+		if t.TimedOut {
+			return fmt.Errorf("flow '%s': %w after %s", t.FlowName, errFlowTimeout, opts.Timeout)
+		}
+		if t.Err != nil {
+			return fmt.Errorf("flow '%s' failed: %v", t.FlowName, t.Err)
+		}
+	}
+	return nil
+}
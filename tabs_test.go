@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestTabModel(t *testing.T) {
+	conf := Config{Steps: []Step{{ID: "step1", Prompt: "Hello"}}}
+	m := TabModel{Tabs: []FlowModel{
+		InitialModel(conf, "one", "", "", themes["dark"]),
+		InitialModel(conf, "two", "", "", themes["dark"]),
+	}}
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyTab})
+	m = updated.(TabModel)
+	if m.Active != 1 {
+		t.Errorf("Expected tab 1 after tab, got %d", m.Active)
+	}
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyShiftTab})
+	m = updated.(TabModel)
+	if m.Active != 0 {
+		t.Errorf("Expected tab 0 after shift+tab, got %d", m.Active)
+	}
+
+	// A finished flow must not quit while another is still running
+	updated, cmd := m.Update(TabMsg{Tab: 0, Msg: FlowFinishedMsg{Result: "done"}})
+	m = updated.(TabModel)
+	if cmd != nil {
+		t.Error("Expected no quit while tab 1 is still running")
+	}
+	if m.Tabs[0].Result != "done" || m.Tabs[1].Result != "" {
+		t.Errorf("Expected result routed to tab 0 only, got %q / %q", m.Tabs[0].Result, m.Tabs[1].Result)
+	}
+
+	_, cmd = m.Update(TabMsg{Tab: 1, Msg: FlowFinishedMsg{Result: "done"}})
+	if cmd == nil {
+		t.Fatal("Expected quit once every flow finished")
+	}
+	if _, ok := cmd().(tea.QuitMsg); !ok {
+		t.Error("Expected tea.QuitMsg once every flow finished")
+	}
+}
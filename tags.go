@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// jsonTagPattern matches {{json:stepID.path}} tags.
+	jsonTagPattern = regexp.MustCompile(`{{json:([^}]+)}}`)
+	// metricTagPattern matches {{len:stepID}} and {{words:stepID}} tags.
+	metricTagPattern = regexp.MustCompile(`{{(len|words):([^}]+)}}`)
+)
+
+// isBuiltinTag reports whether tag is filled in by fast itself rather than
+// from a step result or an input.
+func isBuiltinTag(tag string) bool {
+	return tag == "flow_name" || tag == "model" || tag == "step_id"
+}
+
+// fillStepTags fills the tags of text read by step s: {{step_id}}, {{model}}
+// (the step's effective model) and everything fillTags knows.
+func (r *FlowRun) fillStepTags(text string, s Step, model string) string {
+	text = strings.NewReplacer("{{step_id}}", s.ID, "{{model}}", model).Replace(text)
+	return r.fillTags(text)
+}
+
+// tagStep returns the step a {{tag}} reads from. Plain tags are the step ID
+// itself; {{json:step1.items[0]}} and {{len:step1}} read from step1.
+func tagStep(tag string) string {
+	if rest, ok := strings.CutPrefix(tag, "json:"); ok {
+		id, _ := splitJSONPath(rest)
+		return id
+	}
+	for _, prefix := range []string{"len:", "words:"} {
+		if id, ok := strings.CutPrefix(tag, prefix); ok {
+			return id
+		}
+	}
+	return tag
+}
+
+// renameTagRefs rewrites every tag in prompt that reads from step old,
+// including {{json:old.path}}, {{len:old}} and {{words:old}}.
+func renameTagRefs(prompt, old, new string) string {
+	return strings.NewReplacer(
+		"{{"+old+"}}", "{{"+new+"}}",
+		"{{json:"+old+".", "{{json:"+new+".",
+		"{{json:"+old+"[", "{{json:"+new+"[",
+		"{{json:"+old+"}}", "{{json:"+new+"}}",
+		"{{len:"+old+"}}", "{{len:"+new+"}}",
+		"{{words:"+old+"}}", "{{words:"+new+"}}",
+	).Replace(prompt)
+}
+
+// fillMetricTags replaces {{len:stepID}} with the number of characters in the
+// step's result and {{words:stepID}} with its number of words. The caller
+// holds r.mu.
+func (r *FlowRun) fillMetricTags(prompt string) string {
+	return metricTagPattern.ReplaceAllStringFunc(prompt, func(tag string) string {
+		m := metricTagPattern.FindStringSubmatch(tag)
+		res := r.resultText(m[2])
+		if m[1] == "words" {
+			return strconv.Itoa(len(strings.Fields(res)))
+		}
+		return strconv.Itoa(utf8.RuneCountInString(res))
+	})
+}
+
+// splitJSONPath splits "step1.items[0].title" into the step ID and the
+// path that follows it.
+func splitJSONPath(s string) (id, path string) {
+	if i := strings.IndexAny(s, ".["); i >= 0 {
+		return s[:i], s[i:]
+	}
+	return s, ""
+}
+
+// fillJSONTags replaces every {{json:stepID.path}} with the value at path in
+// the step's JSON result, or "" if the result isn't JSON or has no such
+// path. The caller holds r.mu.
+func (r *FlowRun) fillJSONTags(prompt string) string {
+	return jsonTagPattern.ReplaceAllStringFunc(prompt, func(tag string) string {
+		id, path := splitJSONPath(jsonTagPattern.FindStringSubmatch(tag)[1])
+		return extractJSONPath(r.resultText(id), path)
+	})
+}
+
+// extractJSONPath looks up a path like ".items[0].title" in a JSON document.
+// Strings are returned as-is, other values as compact JSON. A surrounding
+// ```json code fence, as models often add, is ignored.
+func extractJSONPath(doc, path string) string {
+	var v any
+	if err := json.Unmarshal([]byte(stripCodeFence(doc)), &v); err != nil {
+		return ""
+	}
+
+	for path != "" {
+		switch path[0] {
+		case '.':
+			end := strings.IndexAny(path[1:], ".[") + 1
+			if end == 0 {
+				end = len(path)
+			}
+			obj, ok := v.(map[string]any)
+			if !ok {
+				return ""
+			}
+			if v, ok = obj[path[1:end]]; !ok {
+				return ""
+			}
+			path = path[end:]
+		case '[':
+			end := strings.IndexByte(path, ']')
+			if end < 0 {
+				return ""
+			}
+			i, err := strconv.Atoi(path[1:end])
+			arr, ok := v.([]any)
+			if err != nil || !ok || i < 0 || i >= len(arr) {
+				return ""
+			}
+			v = arr[i]
+			path = path[end+1:]
+		default:
+			return ""
+		}
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(out)
+}
+
+// stripCodeFence removes a markdown code fence wrapped around the whole text.
+func stripCodeFence(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "```") || !strings.HasSuffix(s, "```") || len(s) < 6 {
+		return s
+	}
+	s = strings.TrimSuffix(s, "```")
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[i+1:]
+	}
+	return ""
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestExtractJSONPath(t *testing.T) {
+	doc := `{"title": "Report", "count": 3, "ok": true, "none": null,
+		"items": [{"title": "first", "tags": ["a", "b"]}, {"title": "second"}],
+		"meta": {"author": {"name": "Ada"}}}`
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{".title", "Report"},
+		{".count", "3"},
+		{".ok", "true"},
+		{".none", ""},
+		{".items[0].title", "first"},
+		{".items[1].title", "second"},
+		{".items[0].tags[1]", "b"},
+		{".items[0].tags", `["a","b"]`},
+		{".meta.author.name", "Ada"},
+		{".meta.author", `{"name":"Ada"}`},
+		{".missing", ""},
+		{".items[2].title", ""},
+		{".items[-1]", ""},
+		{".items[x]", ""},
+		{".items[0", ""},
+		{".title.length", ""},
+		{"[0]", ""},
+	}
+	for _, tt := range tests {
+		if got := extractJSONPath(doc, tt.path); got != tt.want {
+			t.Errorf("extractJSONPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	if got := extractJSONPath(`[{"id": 7}]`, "[0].id"); got != "7" {
+		t.Errorf("Expected a top-level array index to work, got %q", got)
+	}
+	if got := extractJSONPath("not json", ".title"); got != "" {
+		t.Errorf("Expected empty string for non-JSON, got %q", got)
+	}
+	if got := extractJSONPath("```json\n{\"title\": \"fenced\"}\n```", ".title"); got != "fenced" {
+		t.Errorf("Expected a code fence to be ignored, got %q", got)
+	}
+	if got := extractJSONPath(`{"a": 1}`, ""); got != `{"a":1}` {
+		t.Errorf("Expected the whole document for an empty path, got %q", got)
+	}
+}
+
+func TestFillJSONTags(t *testing.T) {
+	run := newFlowRun("")
+	run.setResult("step1", `{"items": [{"title": "Hello"}]}`)
+	run.setResult("plain", "just text")
+
+	got := run.fillTags("T: {{json:step1.items[0].title}} | {{json:plain.x}} | {{json:step1.items[3].title}}")
+	if want := "T: Hello |  | "; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestJSONTagDependencies(t *testing.T) {
+	s := Step{ID: "b", Prompt: "{{json:step1.items[0].title}} {{json:other}} {{input}}"}
+	deps := stepDependencies(s)
+	if len(deps) != 2 || deps[0] != "step1" || deps[1] != "other" {
+		t.Errorf("Expected step1 and other, got %v", deps)
+	}
+
+	run := newFlowRun("")
+	if run.depsReady(s) {
+		t.Error("Expected deps to be pending")
+	}
+	run.setResult("step1", "{}")
+	run.setResult("other", "{}")
+	if !run.depsReady(s) {
+		t.Error("Expected deps to be ready")
+	}
+}
+
+func TestFillMetricTags(t *testing.T) {
+	run := newFlowRun("")
+	run.setResult("draft", "Héllo there,\n  big   world")
+
+	got := run.fillTags("{{len:draft}} chars, {{words:draft}} words, {{words:missing}}")
+	if want := "26 chars, 4 words, 0"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+
+	s := Step{ID: "expand", Prompt: "Draft has {{words:draft}} words ({{len:notes}} chars)"}
+	if deps := stepDependencies(s); len(deps) != 2 || deps[0] != "draft" || deps[1] != "notes" {
+		t.Errorf("Expected draft and notes, got %v", deps)
+	}
+}
+
+func TestRenameTagRefs(t *testing.T) {
+	got := renameTagRefs("{{a}} {{json:a.x}} {{json:a[0]}} {{len:a}} {{words:a}} {{ab}} {{json:ab.x}}", "a", "b")
+	if want := "{{b}} {{json:b.x}} {{json:b[0]}} {{len:b}} {{words:b}} {{ab}} {{json:ab.x}}"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestBuiltinTags(t *testing.T) {
+	originalCallGemini := callGemini
+	defer func() { callGemini = originalCallGemini }()
+	var got string
+	callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+		got = prompt
+		return "ok", 0, nil
+	}
+
+	step := Step{ID: "intro", Model: "gemini-pro", Prompt: "{{flow_name}}/{{step_id}}/{{model}}"}
+	if deps := stepDependencies(step); len(deps) != 0 {
+		t.Errorf("Expected built-in tags not to be dependencies, got %v", deps)
+	}
+
+	run := newFlowRun("")
+	run.flowName = "onboarding"
+	if err := runFlow(context.Background(), run, Config{Model: "default", Steps: []Step{step}}, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "onboarding/intro/gemini-pro" {
+		t.Errorf("prompt = %q", got)
+	}
+}
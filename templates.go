@@ -0,0 +1,87 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// flowTemplates holds the example flows shipped with the binary.
+//
+//go:embed templates/*.json
+var flowTemplates embed.FS
+
+// templateNames returns the names of all embedded templates, sorted.
+func templateNames() []string {
+	files, _ := fs.Glob(flowTemplates, "templates/*.json")
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = strings.TrimSuffix(filepath.Base(f), ".json")
+	}
+	sort.Strings(names)
+	return names
+}
+
+// readTemplate returns the flow JSON of a template.
+func readTemplate(name string) ([]byte, error) {
+	data, err := flowTemplates.ReadFile("templates/" + name + ".json")
+	if err != nil {
+		return nil, fmt.Errorf("unknown template '%s' (run `fast templates` to list them)", name)
+	}
+	return data, nil
+}
+
+// runTemplatesCommand implements `fast templates [--show NAME | --copy NAME [DIR]]`.
+func runTemplatesCommand(args []string) error {
+	fset := flag.NewFlagSet("templates", flag.ContinueOnError)
+	show := fset.String("show", "", "print a template's flow JSON")
+	copyName := fset.String("copy", "", "copy a template into DIR (default ./flows)")
+	if err := fset.Parse(args); err != nil {
+		return err
+	}
+
+	switch {
+	case *show != "" && *copyName != "":
+		return fmt.Errorf("--show cannot be combined with --copy")
+	case *show != "":
+		data, err := readTemplate(*show)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(data))
+		return nil
+	case *copyName != "":
+		data, err := readTemplate(*copyName)
+		if err != nil {
+			return err
+		}
+		dir := "flows"
+		if fset.NArg() > 0 {
+			dir = expandHome(fset.Arg(0))
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		return createFile(filepath.Join(dir, *copyName+".json"), data)
+	}
+
+	fmt.Println("Templates (copy one with `fast templates --copy NAME`):")
+	for _, name := range templateNames() {
+		data, _ := readTemplate(name)
+		// The first step's comment doubles as the description
+		var conf Config
+		_ = json.Unmarshal(data, &conf)
+		if len(conf.Steps) > 0 && conf.Steps[0].Comment != "" {
+			fmt.Printf("  - %s: %s\n", name, conf.Steps[0].Comment)
+		} else {
+			fmt.Printf("  - %s\n", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTemplatesAreValidFlows(t *testing.T) {
+	names := templateNames()
+	if len(names) < 5 {
+		t.Fatalf("Expected at least 5 templates, got %v", names)
+	}
+	for _, name := range names {
+		data, err := readTemplate(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := parseFlow(data); err != nil {
+			t.Errorf("template %s: %v", name, err)
+		}
+	}
+	if _, err := readTemplate("missing"); err == nil {
+		t.Error("Expected error for unknown template")
+	}
+}
+
+func TestTemplatesCopy(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "flows")
+	if err := runTemplatesCommand([]string{"--copy", "summarize", dir}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "summarize.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := readTemplate("summarize")
+	if string(got) != string(want) {
+		t.Errorf("Copied template differs from the embedded one")
+	}
+}
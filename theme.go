@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// ThemeConfig holds the colors used by the TUI. Values are anything
+// lipgloss.Color accepts: ANSI codes like "214" or hex like "#FD971F".
+type ThemeConfig struct {
+	Spinner string `json:"spinner"`
+	Running string `json:"running"`
+	Done    string `json:"done"`
+	Failed  string `json:"failed"`
+	Waiting string `json:"waiting"`
+	Title   string `json:"title"`
+	Hint    string `json:"hint"`
+}
+
+var themes = map[string]ThemeConfig{
+	"dark": {
+		Spinner: "214",
+		Running: "214",
+		Done:    "42",
+		Failed:  "196",
+		Waiting: "241",
+		Hint:    "241",
+	},
+	"light": {
+		Spinner: "166",
+		Running: "166",
+		Done:    "28",
+		Failed:  "160",
+		Waiting: "245",
+		Title:   "16",
+		Hint:    "244",
+	},
+	"monokai": {
+		Spinner: "#FD971F",
+		Running: "#FD971F",
+		Done:    "#A6E22E",
+		Failed:  "#F92672",
+		Waiting: "#75715E",
+		Title:   "#66D9EF",
+		Hint:    "#75715E",
+	},
+}
+
+// themeByName looks up a preset, defaulting to "dark" when name is empty.
+func themeByName(name string) (ThemeConfig, error) {
+	if name == "" {
+		name = "dark"
+	}
+	if t, ok := themes[name]; ok {
+		return t, nil
+	}
+	names := make([]string, 0, len(themes))
+	for n := range themes {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return ThemeConfig{}, fmt.Errorf("unknown theme %q (available: %s)", name, strings.Join(names, ", "))
+}
+
+// noColor reports whether FAST_NO_COLOR or NO_COLOR (see no-color.org) is
+// set to a non-empty value.
+func noColor() bool {
+	return os.Getenv("FAST_NO_COLOR") != "" || os.Getenv("NO_COLOR") != ""
+}
+
+// applyNoColor makes every style render without colors when noColor asks
+// for it. Layout, borders and symbols stay the same.
+func applyNoColor() {
+	if noColor() {
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+}
+
+// applyTheme recolors the package styles, keeping their layout properties.
+func applyTheme(t ThemeConfig) {
+	hint := lipgloss.Color(t.Hint)
+	done := lipgloss.Color(t.Done)
+	failed := lipgloss.Color(t.Failed)
+	running := lipgloss.Color(t.Running)
+
+	subtleStyle = subtleStyle.Foreground(hint)
+	if t.Title != "" {
+		titleStyle = titleStyle.Foreground(lipgloss.Color(t.Title))
+	}
+	checkMark = checkMark.Foreground(done)
+	crossMark = crossMark.Foreground(failed)
+	waitMark = waitMark.Foreground(lipgloss.Color(t.Waiting))
+	runningStyle = runningStyle.Foreground(running)
+	enumeratorStyle = enumeratorStyle.Foreground(hint)
+	rootStyle = rootStyle.Foreground(hint)
+	timerStyle = timerStyle.Foreground(hint)
+	barFilledStyle = barFilledStyle.Foreground(done)
+	previewStyle = previewStyle.Foreground(hint)
+	viewportStyle = viewportStyle.BorderForeground(hint)
+	helpStyle = helpStyle.BorderForeground(running)
+	helpKeyStyle = helpKeyStyle.Foreground(running)
+	errorStyle = errorStyle.Foreground(failed)
+
+	nodeStyle = nodeStyle.BorderForeground(hint)
+	nodeRunningStyle = nodeRunningStyle.BorderForeground(running).Foreground(running)
+	nodeDoneStyle = nodeDoneStyle.BorderForeground(done).Background(done)
+	nodeFailedStyle = nodeFailedStyle.BorderForeground(failed).Foreground(failed)
+	edgeStyle = edgeStyle.Foreground(hint)
+
+	diffAddStyle = diffAddStyle.Foreground(done)
+	diffRemoveStyle = diffRemoveStyle.Foreground(failed)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+func TestApplyNoColor(t *testing.T) {
+	original := lipgloss.ColorProfile()
+	defer lipgloss.SetColorProfile(original)
+	style := lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
+
+	t.Setenv("FAST_NO_COLOR", "")
+	t.Setenv("NO_COLOR", "")
+	lipgloss.SetColorProfile(termenv.ANSI256)
+	applyNoColor()
+	if got := style.Render("x"); got == "x" {
+		t.Fatal("Expected colors without FAST_NO_COLOR")
+	}
+
+	for _, env := range []string{"FAST_NO_COLOR", "NO_COLOR"} {
+		t.Run(env, func(t *testing.T) {
+			t.Setenv(env, "1")
+			lipgloss.SetColorProfile(termenv.ANSI256)
+			applyNoColor()
+			if got := style.Render("x"); got != "x" {
+				t.Errorf("Expected plain text with %s set, got %q", env, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// tracePrefix starts every line of a --trace log.
+const tracePrefix = "[TRACE] "
+
+// openTrace implements --trace. Without the TUI the trace goes to stderr;
+// with it, it goes to <timestamp>_trace.log in the logs folder so it doesn't
+// garble the screen. file is nil for stderr.
+func openTrace(toFile bool, now time.Time) (trace *log.Logger, file *os.File, err error) {
+	if !toFile {
+		return log.New(os.Stderr, tracePrefix, 0), nil, nil
+	}
+	logDir := logsDir()
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return nil, nil, err
+	}
+	f, err := os.Create(filepath.Join(logDir, now.Format("2006-01-02_15-04-05")+"_trace.log"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create trace log: %w", err)
+	}
+	return log.New(f, tracePrefix, 0), f, nil
+}
+
+// traceValue keeps a substituted value on one trace line.
+func traceValue(v string) string {
+	return strings.ReplaceAll(v, "\n", `\n`)
+}
+
+// traceFill logs one substitution rule of fillTags that changed the text.
+// It does nothing unless --trace is on.
+func (r *FlowRun) traceFill(before, after, tag, value string) {
+	if r.trace == nil || before == after {
+		return
+	}
+	r.trace.Printf("fillTags input=%q -> %q (%s=%s)", before, after, tag, traceValue(value))
+}
+
+// traceFillPattern logs a rule that fills every match of pattern, such as
+// {{json:...}} tags, listing the value of each tag. The caller holds r.mu.
+func (r *FlowRun) traceFillPattern(before, after string, pattern *regexp.Regexp, fill func(string) string) {
+	if r.trace == nil || before == after {
+		return
+	}
+	var subs []string
+	seen := make(map[string]bool)
+	for _, tag := range pattern.FindAllString(before, -1) {
+		if !seen[tag] {
+			seen[tag] = true
+			subs = append(subs, strings.Trim(tag, "{}")+"="+traceValue(fill(tag)))
+		}
+	}
+	r.trace.Printf("fillTags input=%q -> %q (%s)", before, after, strings.Join(subs, ", "))
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestFillTagsTrace(t *testing.T) {
+	var buf bytes.Buffer
+	run := newFlowRun("hi")
+	run.trace = log.New(&buf, tracePrefix, 0)
+	run.setResult("step1", "Mocked response")
+	run.setResult("data", `{"n": 3}`)
+
+	if got := run.fillTags("Hello {{step1}}"); got != "Hello Mocked response" {
+		t.Fatalf("Unexpected fill: %q", got)
+	}
+	run.fillTags("{{input}}: {{json:data.n}}")
+	run.fillTags("Plain text")
+
+	want := `[TRACE] fillTags input="Hello {{step1}}" -> "Hello Mocked response" (step1=Mocked response)
+[TRACE] fillTags input="{{input}}: {{json:data.n}}" -> "hi: {{json:data.n}}" (input=hi)
+[TRACE] fillTags input="hi: {{json:data.n}}" -> "hi: 3" (json:data.n=3)
+[TRACE] fillTags input="Plain text" (no tags)
+`
+	if buf.String() != want {
+		t.Errorf("Unexpected trace:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestFillTagsWithoutTrace(t *testing.T) {
+	run := newFlowRun("multi\nline")
+	if got := run.fillTags("{{input}}"); got != "multi\nline" {
+		t.Errorf("Unexpected fill: %q", got)
+	}
+	if traceValue("a\nb") != `a\nb` || strings.Contains(traceValue("x\ny"), "\n") {
+		t.Error("Expected traceValue to keep values on one line")
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// truncatedMarker is added where output_max_length cut a result.
+const truncatedMarker = "... [truncated]"
+
+// validateTruncation checks the output_max_length and truncate_from of a step.
+func validateTruncation(s Step) error {
+	switch {
+	case s.OutputMaxLength < 0:
+		return fmt.Errorf("step '%s': output_max_length must not be negative", s.ID)
+	case s.TruncateFrom != "" && s.TruncateFrom != "end" && s.TruncateFrom != "start":
+		return fmt.Errorf("step '%s': unknown truncate_from %q (expected end or start)", s.ID, s.TruncateFrom)
+	case s.TruncateFrom != "" && s.OutputMaxLength == 0:
+		return fmt.Errorf("step '%s': truncate_from needs an output_max_length", s.ID)
+	case s.OutputMaxLength > 0 && s.StreamingToFile:
+		return fmt.Errorf("step '%s': output_max_length can't be combined with streaming_to_file", s.ID)
+	}
+	return nil
+}
+
+// truncateResult shortens res to about max characters, cutting at a word
+// boundary. With from "start" the end of res is kept instead of the
+// beginning. It reports whether anything was cut.
+func truncateResult(res string, max int, from string) (string, bool) {
+	runes := []rune(res)
+	if max <= 0 || len(runes) <= max {
+		return res, false
+	}
+
+	if from == "start" {
+		kept := runes[len(runes)-max:]
+		// Drop the partial word at the cut, unless that would drop
+		// more than half of what's left
+		for i, r := range kept[:len(kept)/2] {
+			if unicode.IsSpace(r) {
+				kept = kept[i:]
+				break
+			}
+		}
+		return "[truncated] ..." + strings.TrimLeftFunc(string(kept), unicode.IsSpace), true
+	}
+
+	kept := runes[:max]
+	if !unicode.IsSpace(runes[max]) {
+		for i := len(kept) - 1; i >= len(kept)/2; i-- {
+			if unicode.IsSpace(kept[i]) {
+				kept = kept[:i]
+				break
+			}
+		}
+	}
+	return strings.TrimRightFunc(string(kept), unicode.IsSpace) + truncatedMarker, true
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestTruncateResult(t *testing.T) {
+	tests := []struct {
+		name, res, from string
+		max             int
+		want            string
+		cut             bool
+	}{
+		{"short enough", "one two", "", 20, "one two", false},
+		{"word boundary", "one two three four", "", 10, "one two" + truncatedMarker, true},
+		{"cut on space", "one two three", "", 7, "one two" + truncatedMarker, true},
+		{"no spaces", "abcdefghij", "", 4, "abcd" + truncatedMarker, true},
+		{"from start", "one two three four", "start", 12, "[truncated] ...three four", true},
+		{"runes", "äöü äöü äöü", "end", 5, "äöü" + truncatedMarker, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, cut := truncateResult(tt.res, tt.max, tt.from)
+			if got != tt.want || cut != tt.cut {
+				t.Errorf("truncateResult() = %q, %v; want %q, %v", got, cut, tt.want, tt.cut)
+			}
+		})
+	}
+}
+
+func TestValidateTruncation(t *testing.T) {
+	bad := []Step{
+		{ID: "a", OutputMaxLength: -1},
+		{ID: "a", OutputMaxLength: 10, TruncateFrom: "middle"},
+		{ID: "a", TruncateFrom: "start"},
+		{ID: "a", OutputMaxLength: 10, StreamingToFile: true},
+	}
+	for _, s := range bad {
+		if err := validateTruncation(s); err == nil {
+			t.Errorf("Expected error for %+v", s)
+		}
+	}
+	if err := validateTruncation(Step{ID: "a", OutputMaxLength: 10, TruncateFrom: "start"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestRunStepTruncates(t *testing.T) {
+	originalCallGemini := callGemini
+	defer func() { callGemini = originalCallGemini }()
+	callGemini = func(ctx context.Context, model, sys, prompt string, images []InlineImage) (string, int, error) {
+		return strings.Repeat("word ", 50), 0, nil
+	}
+
+	res, log, err := runStep(context.Background(), newFlowRun(""), Config{}, Step{ID: "a", Prompt: "x", OutputMaxLength: 20}, func(string) {})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res != "word word word word"+truncatedMarker || log.TruncatedFrom != 249 {
+		t.Errorf("Got %q (truncated from %d)", res, log.TruncatedFrom)
+	}
+}
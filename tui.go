@@ -1,12 +1,13 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"regexp"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/lipgloss/tree"
@@ -25,8 +26,46 @@ var (
 	rootStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
 	itemStyle       = lipgloss.NewStyle()
 	timerStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("241")).MarginLeft(1)
+	barFilledStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+	barEmptyStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("237"))
+	previewStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Italic(true)
+	viewportStyle   = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("241"))
+	helpStyle       = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("214")).Padding(1, 2)
+	helpKeyStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("214")).Width(14)
+	errorStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
 )
 
+// helpSections lists every key binding, grouped by the mode it applies to.
+var helpSections = []struct {
+	Title string
+	Keys  [][2]string
+}{
+	{"Global", [][2]string{
+		{"?", "toggle this help"},
+		{"q / ctrl+c", "quit"},
+		{"tab", "next flow (fast run)"},
+		{"shift+tab", "previous flow (fast run)"},
+	}},
+	{"Step tree", [][2]string{
+		{"↑/↓ or k/j", "select a step"},
+		{"enter / space", "expand or collapse the output preview"},
+		{"v", "view the full output"},
+		{"g", "toggle the dependency graph"},
+		{"a", "abort the flow"},
+	}},
+	{"Output view", [][2]string{
+		{"↑/↓ pgup/pgdn", "scroll"},
+		{"esc / v", "close"},
+	}},
+}
+
+const (
+	previewMaxLines = 3
+	previewMaxChars = 200
+)
+
+const progressBarWidth = 30
+
 // --- Model ---
 
 type StepState int
@@ -45,6 +84,11 @@ type StepStatus struct {
 	ParentID  string
 	StartTime time.Time
 	Duration  time.Duration
+	Pinned    bool
+	Progress  string
+	Warnings  []string
+	Preview   string
+	Truncated int // result length before output_max_length, 0 if not cut
 }
 
 type FlowModel struct {
@@ -57,42 +101,130 @@ type FlowModel struct {
 	Quitting         bool
 	Result           string
 	Err              error
+	WatchFiles       []string
+	WatchErr         error
+	LastRun          time.Time
+	Cursor           int
+	Expanded         map[string]bool
+	Viewing          string
+	Viewport         viewport.Model
+	Width, Height    int
+	OutputFile       string
+	OutputErr        error
+	ShowGraph        bool
+	ShowHelp         bool
+	Aborted          bool
+	Done             chan struct{}
+	Run              *FlowRun
+	Deadline         time.Time
+	TimedOut         bool
+	NoPreview        bool
+	Layout           string    // tui.layout: tree, flat or auto
+	DurationLimits   []float64 // tui.duration_thresholds, nil unless color_by_duration
 }
 
 // Messages
 type StepStartedMsg struct{ ID string }
-type StepDoneMsg struct{ ID string }
-type StepFailedMsg struct{ ID string; Err error }
-type FlowFinishedMsg struct{ Result string }
+type StepDoneMsg struct {
+	ID       string
+	Pinned   bool
+	Warnings []string
+	Preview  string // see stepPreview
+}
+type StepFailedMsg struct {
+	ID  string
+	Err *StepError
+}
+
+// StepTruncatedMsg reports that output_max_length cut a step result of
+// Length characters.
+type StepTruncatedMsg struct {
+	ID     string
+	Length int
+}
+
+// StepProgressMsg updates the progress text of a running step.
+type StepProgressMsg struct{ ID, Text string }
+type FlowFinishedMsg struct {
+	Result    string
+	OutputErr error
+}
+type FlowRestartMsg struct {
+	Config           Config
+	Clipboard, Input string
+}
+type WatchErrorMsg struct{ Err error }
+type FlowAbortMsg struct{}
+type FlowTimeoutMsg struct{}
+
+// spinnerStyles are the spinners tui.spinner_style can pick.
+var spinnerStyles = map[string]spinner.Spinner{
+	"dot":    spinner.Dot,
+	"line":   spinner.Line,
+	"globe":  spinner.Globe,
+	"moon":   spinner.Moon,
+	"bounce": spinner.Points, // a dot bouncing along three positions
+}
+
+// newSpinner builds the spinner configured in config.json, falling back to
+// the dot style for unknown settings.
+func newSpinner(conf TUIConfig) spinner.Spinner {
+	sp, ok := spinnerStyles[conf.SpinnerStyle]
+	if !ok {
+		sp = spinner.Dot
+	}
+	if conf.SpinnerFPS > 0 {
+		sp.FPS = time.Second / time.Duration(conf.SpinnerFPS)
+	}
+	return sp
+}
+
+func InitialModel(conf Config, flowName, clipboard, input string, theme ThemeConfig) FlowModel {
+	applyTheme(theme)
+	applyNoColor()
 
-func InitialModel(conf Config, flowName, clipboard, input string) FlowModel {
 	s := spinner.New()
-	s.Spinner = spinner.Dot
-	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+	s.Spinner = newSpinner(globalConfig.TUI)
+	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color(theme.Spinner))
+
+	return FlowModel{
+		Config:           conf,
+		FlowName:         flowName,
+		ClipboardContent: clipboard,
+		InputContent:     input,
+		Steps:            buildSteps(conf),
+		Spinner:          s,
+		Expanded:         make(map[string]bool),
+		Layout:           globalConfig.TUI.Layout,
+		DurationLimits:   durationThresholds(globalConfig.TUI),
+		Width:            80,
+		Height:           24,
+	}
+}
 
+// buildSteps creates a pending status for every step and links it to the
+// node it hangs off in the tree view.
+func buildSteps(conf Config) []*StepStatus {
 	steps := make([]*StepStatus, len(conf.Steps))
+	owners := splitOwners(conf.Steps)
 	for i, step := range conf.Steps {
 		// Find parent
-		tags := regexp.MustCompile(`{{(.*?)}}`).FindAllStringSubmatch(step.Prompt, -1)
+		tags := stepTags(step)
 		parent := "root"
-		
+
 		// 1. Check for step dependencies (strongest link)
-		for _, tag := range tags {
-			dep := tag[1]
-			if dep != "clipboard" && dep != "input" {
-				parent = dep
-				break
-			}
+		if deps := flowDependencies(step, owners); len(deps) > 0 {
+			parent = deps[0]
 		}
 
 		// 2. If no step dependency, check for inputs
 		if parent == "root" {
 			for _, tag := range tags {
-				if tag[1] == "clipboard" {
+				if tag == "clipboard" || tag == "clipboard_image" {
 					parent = "clipboard"
 					break
 				}
-				if tag[1] == "input" {
+				if tag == "input" {
 					parent = "input"
 					break
 				}
@@ -101,15 +233,7 @@ func InitialModel(conf Config, flowName, clipboard, input string) FlowModel {
 
 		steps[i] = &StepStatus{Step: step, State: StatePending, ParentID: parent}
 	}
-
-	return FlowModel{
-		Config:           conf,
-		FlowName:         flowName,
-		ClipboardContent: clipboard,
-		InputContent:     input,
-		Steps:            steps,
-		Spinner:          s,
-	}
+	return steps
 }
 
 func (m FlowModel) Init() tea.Cmd {
@@ -118,11 +242,70 @@ func (m FlowModel) Init() tea.Cmd {
 
 func (m FlowModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.Width, m.Height = msg.Width, msg.Height
+		m.Viewport.Width, m.Viewport.Height = m.viewportSize()
 	case tea.KeyMsg:
+		if m.ShowHelp {
+			switch msg.String() {
+			case "?", "q", "esc":
+				m.ShowHelp = false
+			case "ctrl+c":
+				m.Quitting = true
+				return m, tea.Quit
+			}
+			return m, nil
+		}
+		if msg.String() == "?" {
+			m.ShowHelp = true
+			return m, nil
+		}
 		if msg.String() == "q" || msg.String() == "ctrl+c" {
 			m.Quitting = true
 			return m, tea.Quit
 		}
+		if m.Viewing != "" {
+			if msg.String() == "esc" || msg.String() == "v" {
+				m.Viewing = ""
+				return m, nil
+			}
+			var cmd tea.Cmd
+			m.Viewport, cmd = m.Viewport.Update(msg)
+			return m, cmd
+		}
+
+		ordered := m.orderedSteps()
+		switch msg.String() {
+		case "g":
+			m.ShowGraph = !m.ShowGraph
+		case "a":
+			if m.Result == "" && !m.Aborted {
+				return m, func() tea.Msg { return FlowAbortMsg{} }
+			}
+		case "up", "k":
+			if m.Cursor > 0 {
+				m.Cursor--
+			}
+		case "down", "j":
+			if m.Cursor < len(ordered)-1 {
+				m.Cursor++
+			}
+		case "enter", " ":
+			if sel := m.selected(ordered); sel != nil && sel.State == StateDone {
+				m.Expanded[sel.Step.ID] = !m.Expanded[sel.Step.ID]
+			}
+		case "v":
+			if sel := m.selected(ordered); sel != nil && sel.State == StateDone {
+				m.Viewing = sel.Step.ID
+				w, h := m.viewportSize()
+				m.Viewport = viewport.New(w, h)
+				result := m.Run.GetResult(sel.Step.ID)
+				if isImageResult(result) {
+					result = imagePreview + " (write it to a file with save_to)"
+				}
+				m.Viewport.SetContent(lipgloss.NewStyle().Width(w).Render(result))
+			}
+		}
 	case spinner.TickMsg:
 		var cmd tea.Cmd
 		m.Spinner, cmd = m.Spinner.Update(msg)
@@ -134,11 +317,29 @@ func (m FlowModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				s.StartTime = time.Now()
 			}
 		}
+	case StepTruncatedMsg:
+		for _, s := range m.Steps {
+			if s.Step.ID == msg.ID {
+				s.Truncated = msg.Length
+			}
+		}
+	case StepProgressMsg:
+		for _, s := range m.Steps {
+			if s.Step.ID == msg.ID {
+				s.Progress = msg.Text
+			}
+		}
 	case StepDoneMsg:
 		for _, s := range m.Steps {
 			if s.Step.ID == msg.ID {
 				s.State = StateDone
-				s.Duration = time.Since(s.StartTime)
+				s.Pinned = msg.Pinned
+				s.Progress = ""
+				s.Warnings = msg.Warnings
+				s.Preview = msg.Preview
+				if !msg.Pinned {
+					s.Duration = time.Since(s.StartTime)
+				}
 			}
 		}
 	case StepFailedMsg:
@@ -149,22 +350,168 @@ func (m FlowModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				s.Duration = time.Since(s.StartTime)
 			}
 		}
-		m.Err = msg.Err
+		// The runner sends FlowFinishedMsg once the log is saved and the
+		// notification sent; quitting here would race with both
+		if m.Err == nil {
+			m.Err = msg.Err
+		}
+	case FlowAbortMsg:
+		if !m.Aborted {
+			m.Aborted = true
+			if m.Done != nil {
+				close(m.Done)
+			}
+		}
+	case FlowTimeoutMsg:
+		for _, s := range m.Steps {
+			if s.State == StateDone {
+				continue
+			}
+			if s.State == StateRunning {
+				s.Duration = time.Since(s.StartTime)
+			}
+			s.State = StateFailed
+			s.Err = errFlowTimeout
+		}
+		m.TimedOut = true
 		m.Quitting = true
 		return m, tea.Quit
 	case FlowFinishedMsg:
+		if m.Aborted || m.Err != nil {
+			m.Quitting = true
+			return m, tea.Quit
+		}
 		m.Result = msg.Result
+		m.OutputErr = msg.OutputErr
+		if len(m.WatchFiles) > 0 {
+			m.LastRun = time.Now()
+			return m, nil
+		}
 		m.Quitting = true
 		return m, tea.Quit
+	case FlowRestartMsg:
+		m.Config = msg.Config
+		m.ClipboardContent = msg.Clipboard
+		m.InputContent = msg.Input
+		m.Steps = buildSteps(msg.Config)
+		m.Cursor = 0
+		m.Expanded = make(map[string]bool)
+		m.Viewing = ""
+		m.Result = ""
+		m.WatchErr = nil
+	case WatchErrorMsg:
+		m.WatchErr = msg.Err
 	}
 	return m, nil
 }
 
+// orderedSteps returns the steps in the order they appear in the tree view,
+// which is the order the selection cursor moves through.
+func (m FlowModel) orderedSteps() []*StepStatus {
+	var ordered []*StepStatus
+	var visit func(parentID string)
+	visit = func(parentID string) {
+		for _, s := range m.Steps {
+			if s.ParentID == parentID {
+				ordered = append(ordered, s)
+				visit(s.Step.ID)
+			}
+		}
+	}
+	visit("clipboard")
+	visit("input")
+	visit("root")
+	return ordered
+}
+
+func (m FlowModel) selected(ordered []*StepStatus) *StepStatus {
+	if m.Cursor < 0 || m.Cursor >= len(ordered) {
+		return nil
+	}
+	return ordered[m.Cursor]
+}
+
+func (m FlowModel) viewportSize() (int, int) {
+	return max(m.Width-2, 20), max(m.Height-8, 5)
+}
+
+// previewLines returns the first few lines of a step result for inline display.
+func previewLines(result string) string {
+	if isImageResult(result) {
+		return previewStyle.Render(imagePreview)
+	}
+	if r := []rune(result); len(r) > previewMaxChars {
+		result = string(r[:previewMaxChars]) + "..."
+	}
+	lines := strings.Split(strings.TrimSpace(result), "\n")
+	if len(lines) > previewMaxLines {
+		lines = append(lines[:previewMaxLines], "...")
+	}
+	for i, l := range lines {
+		lines[i] = previewStyle.Render(l)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// stepPreviewChars is how much of a result the tree shows under a done step.
+const stepPreviewChars = 80
+
+// stepPreview returns the start of a step result on a single line.
+func stepPreview(result string) string {
+	if isImageResult(result) {
+		return imagePreview
+	}
+	preview := strings.Join(strings.Fields(result), " ")
+	if r := []rune(preview); len(r) > stepPreviewChars {
+		preview = string(r[:stepPreviewChars]) + "…"
+	}
+	return preview
+}
+
+// minCommentWidth is the narrowest terminal that still shows step comments
+// and result previews.
+const minCommentWidth = 40
+
+// commentLine renders a step comment for the tree, cut to the terminal width.
+// It is empty when there is no comment or the terminal is too narrow.
+func (m FlowModel) commentLine(comment string) string {
+	if comment == "" || (m.Width > 0 && m.Width < minCommentWidth) {
+		return ""
+	}
+	if r := []rune(comment); m.Width > 0 && len(r) > m.Width-12 {
+		comment = string(r[:m.Width-13]) + "…"
+	}
+	return subtleStyle.Render(comment)
+}
+
+// previewLine renders the result preview of a done step like a comment. It
+// is empty with --no-preview and while the full result is expanded.
+func (m FlowModel) previewLine(s *StepStatus) string {
+	if m.NoPreview || s.State != StateDone || m.Expanded[s.Step.ID] {
+		return ""
+	}
+	return m.commentLine(s.Preview)
+}
+
 func (m FlowModel) View() string {
 	if m.Err != nil {
+		var se *StepError
+		if errors.As(m.Err, &se) {
+			return "\n" + renderStepError(se) + "\n"
+		}
 		return fmt.Sprintf("\n%s Error: %v\n", crossMark, m.Err)
 	}
 
+	if m.ShowHelp {
+		return m.renderHelp()
+	}
+
+	if m.Viewing != "" {
+		header := titleStyle.Render(fmt.Sprintf("Output: %s", m.Viewing))
+		footer := subtleStyle.Render("↑/↓ scroll • esc close • ? help • q quit")
+		return "\n" + header + "\n" + viewportStyle.Render(m.Viewport.View()) + "\n" + footer + "\n"
+	}
+
 	var headerIcon string
 	if m.Result != "" {
 		headerIcon = checkMark.String()
@@ -224,36 +571,19 @@ func (m FlowModel) View() string {
 			ItemStyle(itemStyle)
 	}
 
+	var selectedID string
+	if sel := m.selected(m.orderedSteps()); sel != nil {
+		selectedID = sel.Step.ID
+	}
+
 	// Helper to recursively add children
 	var addChildren func(parentID string, currentTree *tree.Tree)
 	addChildren = func(parentID string, currentTree *tree.Tree) {
 		for _, s := range m.Steps {
 			if s.ParentID == parentID {
-				// Determine icon and style
-				var icon string
-				var style lipgloss.Style
-				var timer string
-
-				switch s.State {
-				case StateRunning:
-					icon = ""
-					style = runningStyle
-					timer = timerStyle.Render(fmt.Sprintf("%.1fs", time.Since(s.StartTime).Seconds()))
-				case StateDone:
-					icon = "" // No checkmark in tree
-					style = itemStyle
-					timer = timerStyle.Render(fmt.Sprintf("%.1fs", s.Duration.Seconds()))
-				case StateFailed:
-					icon = crossMark.String()
-					style = itemStyle
-					timer = timerStyle.Render(fmt.Sprintf("%.1fs", s.Duration.Seconds()))
-				default:
-					icon = waitMark.String()
-					style = subtleStyle
-				}
+				// No checkmark in tree
+				label := m.stepLabel(s, s.Step.ID == selectedID, "")
 
-				label := fmt.Sprintf("%s %s%s", icon, style.Render(s.Step.ID), timer)
-				
 				// Check if this node has children
 				hasChildren := false
 				for _, check := range m.Steps {
@@ -296,10 +626,222 @@ func (m FlowModel) View() string {
 		finalTree = t.String()
 	}
 
-	footer := subtleStyle.Render("Press q to quit")
+	footer := subtleStyle.Render("↑/↓ select • enter preview • v view output • g graph • a abort • ? help • q quit")
+	if m.DurationLimits != nil {
+		footer += "\n" + durationLegend(m.DurationLimits)
+	}
+	if !m.Deadline.IsZero() && m.Result == "" && !m.Aborted {
+		left := max(time.Until(m.Deadline), 0).Round(time.Second)
+		footer += "\n" + subtleStyle.Render(fmt.Sprintf("⏱  %s left", left))
+	}
+	if m.Aborted {
+		footer = fmt.Sprintf("%s %s", crossMark, subtleStyle.Render("Aborted (completed results saved to the session log)"))
+	}
+	if m.TimedOut {
+		footer = fmt.Sprintf("%s %s", crossMark, subtleStyle.Render("Flow timeout (completed results saved to the session log)"))
+	}
 	if m.Result != "" {
 		footer = fmt.Sprintf("%s %s", checkMark.String(), subtleStyle.Render("Flow Complete! (Result copied to clipboard)"))
+		if m.OutputFile != "" && m.OutputErr == nil {
+			footer = fmt.Sprintf("%s %s", checkMark.String(), subtleStyle.Render(fmt.Sprintf("Flow Complete! (Result copied to clipboard and saved to %s)", m.OutputFile)))
+		}
+		if m.OutputErr != nil {
+			footer += "\n" + fmt.Sprintf("%s Output failed: %v", crossMark, m.OutputErr)
+		}
+	}
+	if len(m.WatchFiles) > 0 && m.Result != "" {
+		footer += "\n" + subtleStyle.Render(fmt.Sprintf("👀 Watching %s | Last run %s | Press ctrl+c to quit",
+			strings.Join(m.WatchFiles, ", "), m.LastRun.Format("15:04:05")))
+		if m.WatchErr != nil {
+			footer += "\n" + fmt.Sprintf("%s %v", crossMark, m.WatchErr)
+		}
+	}
+
+	body := finalTree
+	if m.flatLayout() {
+		body = m.renderFlat(selectedID)
+	}
+	if m.ShowGraph {
+		body = m.renderGraph()
+	}
+
+	return "\n" + header + "\n\n" + m.renderProgress() + "\n\n" + body + "\n\n" + footer + "\n"
+}
+
+// stepLabel is a step's entry in the tree or list: its state icon, ID and
+// timing, followed by its comment and result preview. Done steps get
+// doneIcon.
+func (m FlowModel) stepLabel(s *StepStatus, selected bool, doneIcon string) string {
+	var icon string
+	var style lipgloss.Style
+	var timer string
+
+	switch s.State {
+	case StateRunning:
+		icon = ""
+		style = runningStyle
+		timer = timerStyle.Render(fmt.Sprintf("%.1fs", time.Since(s.StartTime).Seconds()))
+		if s.Progress != "" {
+			timer += subtleStyle.Render(" " + s.Progress)
+		}
+	case StateDone:
+		icon = doneIcon
+		style = itemStyle
+		timer = timerStyle.Render(fmt.Sprintf("%.1fs", s.Duration.Seconds()))
+		if m.DurationLimits != nil && !s.Pinned {
+			color := durationColor(s.Duration, m.DurationLimits)
+			style = style.Foreground(color)
+			timer = timerStyle.Foreground(color).Render(fmt.Sprintf("%.1fs", s.Duration.Seconds()))
+		}
+		if s.Pinned {
+			timer = timerStyle.Render("pinned")
+		}
+		if s.Truncated > 0 {
+			timer += subtleStyle.Render(fmt.Sprintf(" ✂️  truncated from %d chars", s.Truncated))
+		}
+		for _, w := range s.Warnings {
+			timer += subtleStyle.Render(" ⚠️  " + w)
+		}
+	case StateFailed:
+		icon = crossMark.String()
+		style = itemStyle
+		timer = timerStyle.Render(fmt.Sprintf("%.1fs", s.Duration.Seconds()))
+	default:
+		icon = waitMark.String()
+		style = subtleStyle
+	}
+
+	if selected {
+		style = style.Reverse(true)
+	}
+
+	label := fmt.Sprintf("%s %s%s", icon, style.Render(s.Step.ID), timer)
+	if c := m.commentLine(s.Step.Comment); c != "" {
+		label += "\n" + c
+	}
+	if p := m.previewLine(s); p != "" {
+		label += "\n" + p
+	}
+	if m.Expanded[s.Step.ID] {
+		label += "\n" + previewLines(m.Run.GetResult(s.Step.ID))
+	}
+	return label
+}
+
+// durationColors are the colors of tui.color_by_duration, from fast to slow.
+var durationColors = []lipgloss.Color{"42", "226", "214", "196"}
+
+// durationThresholds returns the thresholds to color steps by, or nil if
+// color_by_duration is off.
+func durationThresholds(conf TUIConfig) []float64 {
+	if !conf.ColorByDuration {
+		return nil
+	}
+	return conf.DurationThresholds
+}
+
+// durationColor picks the color for a step that took d: green below the
+// first threshold, then yellow, orange and red above the last one.
+func durationColor(d time.Duration, thresholds []float64) lipgloss.Color {
+	i := 0
+	for i < len(thresholds) && d.Seconds() >= thresholds[i] {
+		i++
+	}
+	return durationColors[min(i, len(durationColors)-1)]
+}
+
+// durationLegend explains the duration colors, e.g. "● <2s ● 2-10s …".
+func durationLegend(thresholds []float64) string {
+	labels := []string{fmt.Sprintf("<%gs", thresholds[0])}
+	for i := 1; i < len(thresholds); i++ {
+		labels = append(labels, fmt.Sprintf("%g-%gs", thresholds[i-1], thresholds[i]))
+	}
+	labels = append(labels, fmt.Sprintf(">%gs", thresholds[len(thresholds)-1]))
+
+	var parts []string
+	for i, l := range labels {
+		dot := lipgloss.NewStyle().Foreground(durationColors[min(i, len(durationColors)-1)]).Render("●")
+		parts = append(parts, dot+" "+subtleStyle.Render(l))
+	}
+	return strings.Join(parts, " ")
+}
+
+// flatLayout reports whether the steps are shown as a numbered list instead
+// of a tree: always for layout "flat", and for "auto" when no steps run in
+// parallel.
+func (m FlowModel) flatLayout() bool {
+	switch m.Layout {
+	case "flat":
+		return true
+	case "tree":
+		return false
 	}
+	var steps []Step
+	for _, s := range m.Steps {
+		steps = append(steps, s.Step)
+	}
+	return isLinear(steps)
+}
+
+// renderFlat draws the steps as a numbered list in tree order, e.g.
+// "1. ✓ step1 1.2s".
+func (m FlowModel) renderFlat(selectedID string) string {
+	var b strings.Builder
+	for i, s := range m.orderedSteps() {
+		number := enumeratorStyle.Render(fmt.Sprintf("%d.", i+1))
+		indent := strings.Repeat(" ", lipgloss.Width(number))
+		label := m.stepLabel(s, s.Step.ID == selectedID, checkMark.String())
+		b.WriteString(number + strings.ReplaceAll(label, "\n", "\n"+indent) + "\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// renderStepError shows which step failed, why, and how long it ran.
+func renderStepError(e *StepError) string {
+	s := fmt.Sprintf("%s %s %s", crossMark, titleStyle.Render(e.StepID), subtleStyle.Render("("+e.StepType+")"))
+	s += "\n  " + errorStyle.Render(e.Cause.Error())
+	details := fmt.Sprintf("after %.1fs", e.Duration.Seconds())
+	if e.Attempt > 1 {
+		details += fmt.Sprintf(", attempt %d", e.Attempt)
+	}
+	return s + "\n  " + subtleStyle.Render(details)
+}
+
+// renderHelp draws the key binding reference centered on screen.
+func (m FlowModel) renderHelp() string {
+	var b strings.Builder
+	for i, section := range helpSections {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(titleStyle.Render(section.Title) + "\n")
+		for _, k := range section.Keys {
+			b.WriteString(helpKeyStyle.Render(k[0]) + " " + subtleStyle.Render(k[1]) + "\n")
+		}
+	}
+	b.WriteString("\n" + subtleStyle.Render("Press ?, q or esc to close"))
+
+	return lipgloss.Place(m.Width, m.Height, lipgloss.Center, lipgloss.Center, helpStyle.Render(b.String()))
+}
+
+// renderProgress draws the overall completion bar shown above the tree.
+func (m FlowModel) renderProgress() string {
+	done := 0
+	for _, s := range m.Steps {
+		if s.State == StateDone {
+			done++
+		}
+	}
+
+	total := len(m.Steps)
+	pct := 0.0
+	if total > 0 {
+		pct = float64(done) / float64(total)
+	}
+
+	filled := int(pct * progressBarWidth)
+	bar := barFilledStyle.Render(strings.Repeat("█", filled)) +
+		barEmptyStyle.Render(strings.Repeat("░", progressBarWidth-filled))
 
-	return "\n" + header + "\n\n" + finalTree + "\n\n" + footer + "\n"
+	return fmt.Sprintf("%s %3.0f%% %s", bar, pct*100, subtleStyle.Render(fmt.Sprintf("%d of %d steps completed", done, total)))
 }
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// releasesURL is the GitHub API endpoint for the latest release.
+var releasesURL = "https://api.github.com/repos/ProggePal/flow/releases/latest"
+
+// checksumsAsset lists "<sha256>  <asset name>" for every binary of a release.
+const checksumsAsset = "checksums.txt"
+
+type release struct {
+	TagName string         `json:"tag_name"`
+	Assets  []releaseAsset `json:"assets"`
+}
+
+type releaseAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+func (r release) assetURL(name string) string {
+	for _, a := range r.Assets {
+		if a.Name == name {
+			return a.URL
+		}
+	}
+	return ""
+}
+
+// binaryAssetName is the release asset for this platform, e.g. fast_darwin_arm64.
+func binaryAssetName() string {
+	return fmt.Sprintf("fast_%s_%s", runtime.GOOS, runtime.GOARCH)
+}
+
+// runVersionCommand implements `fast version`.
+func runVersionCommand() error {
+	fmt.Printf("fast %s\n", Version)
+	rel, err := latestRelease()
+	if err != nil {
+		return fmt.Errorf("failed to check for updates: %w", err)
+	}
+	if compareVersions(rel.TagName, Version) > 0 {
+		fmt.Printf("⬆️  %s is available, run `fast upgrade` to install it\n", rel.TagName)
+	} else {
+		fmt.Println("✓ You're on the latest version")
+	}
+	return nil
+}
+
+// runUpgradeCommand implements `fast upgrade`.
+func runUpgradeCommand() error {
+	rel, err := latestRelease()
+	if err != nil {
+		return fmt.Errorf("failed to check for updates: %w", err)
+	}
+	if compareVersions(rel.TagName, Version) <= 0 {
+		fmt.Printf("✓ fast %s is the latest version\n", Version)
+		return nil
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+	fmt.Printf("⬇️  Downloading %s...\n", rel.TagName)
+	if err := installRelease(rel, exe); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Upgraded fast %s → %s\n", Version, rel.TagName)
+	return nil
+}
+
+func latestRelease() (release, error) {
+	var rel release
+	resp, err := http.Get(releasesURL)
+	if err != nil {
+		return rel, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return rel, fmt.Errorf("GitHub returned %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&rel); err != nil {
+		return rel, fmt.Errorf("failed to parse release: %w", err)
+	}
+	return rel, nil
+}
+
+// installRelease downloads this platform's binary, checks it against the
+// release checksums and atomically replaces exe with it.
+func installRelease(rel release, exe string) error {
+	name := binaryAssetName()
+	binURL := rel.assetURL(name)
+	if binURL == "" {
+		return fmt.Errorf("release %s has no binary for %s/%s", rel.TagName, runtime.GOOS, runtime.GOARCH)
+	}
+	sumsURL := rel.assetURL(checksumsAsset)
+	if sumsURL == "" {
+		return fmt.Errorf("release %s has no %s", rel.TagName, checksumsAsset)
+	}
+	want, err := expectedChecksum(sumsURL, name)
+	if err != nil {
+		return err
+	}
+
+	// Download next to the executable so the final rename stays on one filesystem
+	tmp, err := os.CreateTemp(filepath.Dir(exe), ".fast-upgrade-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	resp, err := http.Get(binURL)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		tmp.Close()
+		return fmt.Errorf("download failed: %s", resp.Status)
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), resp.Body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if got := hex.EncodeToString(hash.Sum(nil)); got != want {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", name, want, got)
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), exe)
+}
+
+// expectedChecksum finds the SHA-256 of asset in a checksums file.
+func expectedChecksum(url, asset string) (string, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download %s: %s", checksumsAsset, resp.Status)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == asset {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", errors.New("no checksum listed for " + asset)
+}
+
+// compareVersions compares dotted versions like "v1.2.10" and "1.3.0",
+// returning -1, 0 or 1. Missing or non-numeric parts count as 0.
+func compareVersions(a, b string) int {
+	pa := strings.Split(strings.TrimPrefix(a, "v"), ".")
+	pb := strings.Split(strings.TrimPrefix(b, "v"), ".")
+	for i := 0; i < max(len(pa), len(pb)); i++ {
+		var x, y int
+		if i < len(pa) {
+			x, _ = strconv.Atoi(pa[i])
+		}
+		if i < len(pb) {
+			y, _ = strconv.Atoi(pb[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
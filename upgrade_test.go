@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"v1.2.0", "1.2.0", 0},
+		{"v1.10.0", "v1.9.9", 1},
+		{"0.1.0", "v0.2", -1},
+		{"v2", "1.99.99", 1},
+	}
+	for _, c := range cases {
+		if got := compareVersions(c.a, c.b); got != c.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestInstallRelease(t *testing.T) {
+	binary := []byte("new fast binary")
+	sum := sha256.Sum256(binary)
+	checksum := hex.EncodeToString(sum[:])
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/bin", func(w http.ResponseWriter, r *http.Request) { w.Write(binary) })
+	mux.HandleFunc("/sums", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s  %s\n", checksum, binaryAssetName())
+	})
+	mux.HandleFunc("/bad-sums", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%064d  %s\n", 0, binaryAssetName())
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	newRelease := func(sums string) release {
+		return release{TagName: "v9.9.9", Assets: []releaseAsset{
+			{Name: binaryAssetName(), URL: srv.URL + "/bin"},
+			{Name: checksumsAsset, URL: srv.URL + sums},
+		}}
+	}
+
+	exe := filepath.Join(t.TempDir(), "fast")
+	if err := os.WriteFile(exe, []byte("old"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := installRelease(newRelease("/bad-sums"), exe); err == nil {
+		t.Error("Expected checksum mismatch error")
+	}
+	if data, _ := os.ReadFile(exe); string(data) != "old" {
+		t.Errorf("Expected executable untouched after a failed upgrade, got %q", data)
+	}
+
+	if err := installRelease(newRelease("/sums"), exe); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, _ := os.ReadFile(exe); string(data) != string(binary) {
+		t.Errorf("Expected executable replaced, got %q", data)
+	}
+}
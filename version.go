@@ -0,0 +1,5 @@
+package main
+
+// Version is the released version of fast, compared against GitHub releases
+// by `fast version` and `fast upgrade`.
+const Version = "0.1.0"
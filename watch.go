@@ -0,0 +1,66 @@
+package main
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+const watchDebounce = 500 * time.Millisecond
+
+// watchFiles blocks and signals on changed whenever one of files is written,
+// created, or replaced. Events are debounced so a burst of writes from an
+// editor triggers a single re-run.
+func watchFiles(files []string, changed chan<- struct{}) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	// Watch parent directories rather than the files themselves so that
+	// editors which save by renaming a temp file over the original still fire.
+	targets := make(map[string]bool)
+	dirs := make(map[string]bool)
+	for _, f := range files {
+		abs, err := filepath.Abs(f)
+		if err != nil {
+			return err
+		}
+		targets[abs] = true
+		dirs[filepath.Dir(abs)] = true
+	}
+	for dir := range dirs {
+		if err := w.Add(dir); err != nil {
+			return err
+		}
+	}
+
+	var timer *time.Timer
+	for {
+		select {
+		case ev, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			if !targets[filepath.Clean(ev.Name)] || ev.Op == fsnotify.Chmod {
+				continue
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.AfterFunc(watchDebounce, func() {
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			})
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+			return err
+		}
+	}
+}